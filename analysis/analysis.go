@@ -0,0 +1,493 @@
+// Package analysis finds likely mistakes in Buggy programs without running
+// them: names that are never defined, bindings that are never read, calls
+// with too few arguments and code that can't be reached.
+//
+// Names are resolved the way the evaluator resolves them. Only functions
+// have scopes of their own. Code in a function sees a let or import of it
+// only once the statement has run, so it must come first; the functions
+// nested in it see all of its names, since they run when they are called,
+// which lets functions call each other in any order.
+package analysis
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/smiksha1701/buggy/ast"
+	"github.com/smiksha1701/buggy/evaluator"
+	"github.com/smiksha1701/buggy/token"
+)
+
+// Rule identifies a kind of finding. Rules can be turned off through
+// Config.Disabled.
+type Rule string
+
+const (
+	Undefined       Rule = "undefined"        // a name that is neither declared nor a builtin
+	UnusedLet       Rule = "unused-let"       // a let binding in a function that is never read
+	UnusedParam     Rule = "unused-param"     // a parameter no later parameter is read after
+	ShadowedBuiltin Rule = "shadowed-builtin" // a declaration that hides a builtin
+	Arity           Rule = "arity"            // a call to a known function with too few arguments
+	DeadCode        Rule = "dead-code"        // a statement after one that always leaves its block
+)
+
+// Rules lists every rule.
+var Rules = []Rule{Undefined, UnusedLet, UnusedParam, ShadowedBuiltin, Arity, DeadCode}
+
+// Finding is a problem found in a program.
+type Finding struct {
+	Pos     token.Position
+	Rule    Rule
+	Message string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s [%s]", f.Pos, f.Message, f.Rule)
+}
+
+// Config adjusts what Check reports.
+type Config struct {
+	// Disabled holds the rules whose findings are left out.
+	Disabled map[Rule]bool
+	// Globals names the variables the host defines for the program besides
+	// the builtins, such as the `args` of scripts.
+	Globals []string
+}
+
+// Check analyzes program and returns its findings in the order of their
+// positions.
+func Check(program *ast.Program, config Config) []Finding {
+	c := &checker{config: config, globals: map[string]bool{}}
+	for _, name := range config.Globals {
+		c.globals[name] = true
+	}
+	// Top-level bindings are globals a host or an importer may read, so
+	// unlike those in functions they are not reported when unused.
+	top := c.newScope(nil)
+	c.declareStatements(top, program.Statements)
+	c.statements(top, program.Statements)
+	c.checkCalls()
+	sort.SliceStable(c.findings, func(i, j int) bool {
+		return c.findings[i].Pos.Offset < c.findings[j].Pos.Offset
+	})
+	return c.findings
+}
+
+type bindingKind int
+
+const (
+	paramBinding bindingKind = iota
+	letBinding
+	loopBinding
+	catchBinding
+	importBinding
+)
+
+// binding is a name declared in a scope. A name declared more than once in
+// the same function is a single binding, as it is when the program runs.
+type binding struct {
+	kind     bindingKind
+	pos      token.Position
+	used     bool
+	assigned bool
+	// defined tells whether the statement binding the name has been passed
+	// on the way through its function.
+	defined bool
+	// lets counts the let statements declaring the name and fn is the
+	// function literal the first of them binds it to, if any.
+	lets int
+	fn   *ast.FunctionLiteral
+}
+
+type scope struct {
+	parent   *scope
+	bindings map[string]*binding
+}
+
+func (s *scope) lookup(name string) *binding {
+	for ; s != nil; s = s.parent {
+		if b, ok := s.bindings[name]; ok {
+			return b
+		}
+	}
+	return nil
+}
+
+// call is a call to a name, checked against the function the name is bound
+// to once every assignment to it has been seen.
+type call struct {
+	binding *binding
+	name    string
+	args    int
+	pos     token.Position
+}
+
+type checker struct {
+	config   Config
+	globals  map[string]bool
+	calls    []call
+	findings []Finding
+}
+
+func (c *checker) report(pos token.Position, rule Rule, format string, a ...interface{}) {
+	if c.config.Disabled[rule] {
+		return
+	}
+	c.findings = append(c.findings, Finding{Pos: pos, Rule: rule, Message: fmt.Sprintf(format, a...)})
+}
+
+func (c *checker) newScope(parent *scope) *scope {
+	return &scope{parent: parent, bindings: map[string]*binding{}}
+}
+
+func (c *checker) declare(s *scope, name *ast.Identifier, kind bindingKind) *binding {
+	if _, ok := evaluator.LookupBuiltin(name.Value); ok {
+		c.report(name.Pos(), ShadowedBuiltin, "%s shadows the builtin %s", name.Value, name.Value)
+	}
+	b, ok := s.bindings[name.Value]
+	if !ok {
+		b = &binding{kind: kind, pos: name.Pos(), defined: kind != letBinding && kind != importBinding}
+		s.bindings[name.Value] = b
+	}
+	return b
+}
+
+// declareStatements declares the names the statements bind in s, looking
+// into blocks but not into function literals, which have scopes of their
+// own.
+func (c *checker) declareStatements(s *scope, statements []ast.Statement) {
+	for _, statement := range statements {
+		c.declareStatement(s, statement)
+	}
+}
+
+func (c *checker) declareStatement(s *scope, statement ast.Statement) {
+	switch st := statement.(type) {
+	case *ast.LetStatement:
+		c.declareLet(s, st)
+	case *ast.ExportStatement:
+		c.declareLet(s, st.Statement)
+	case *ast.ImportStatement:
+		c.declare(s, st.Name, importBinding)
+	case *ast.ReturnStatement:
+		c.declareExpression(s, st.Return)
+	case *ast.ThrowStatement:
+		c.declareExpression(s, st.Value)
+	case *ast.AssignStatement:
+		c.declareExpression(s, st.Target)
+		c.declareExpression(s, st.Value)
+	case *ast.ExpressionStatement:
+		c.declareExpression(s, st.Expression)
+	case *ast.BlockStatement:
+		c.declareBlock(s, st)
+	case *ast.WhileStatement:
+		c.declareExpression(s, st.Condition)
+		c.declareBlock(s, st.Body)
+	case *ast.ForStatement:
+		if st.Key != nil {
+			c.declare(s, st.Key, loopBinding)
+		}
+		c.declare(s, st.Value, loopBinding)
+		c.declareExpression(s, st.Iterable)
+		c.declareBlock(s, st.Body)
+	}
+}
+
+func (c *checker) declareLet(s *scope, let *ast.LetStatement) {
+	b := c.declare(s, let.Name, letBinding)
+	b.lets++
+	if fn, ok := let.Value.(*ast.FunctionLiteral); ok && b.lets == 1 && b.kind == letBinding {
+		b.fn = fn
+	}
+	c.declareExpression(s, let.Value)
+}
+
+func (c *checker) declareBlock(s *scope, block *ast.BlockStatement) {
+	if block != nil {
+		c.declareStatements(s, block.Statements)
+	}
+}
+
+func (c *checker) declareExpression(s *scope, e ast.Expression) {
+	switch e := e.(type) {
+	case *ast.IfExpression:
+		c.declareExpression(s, e.Condition)
+		c.declareBlock(s, e.Consequence)
+		c.declareBlock(s, e.Alternative)
+	case *ast.TryExpression:
+		c.declareBlock(s, e.Body)
+		if e.CatchParam != nil {
+			c.declare(s, e.CatchParam, catchBinding)
+		}
+		c.declareBlock(s, e.Catch)
+		c.declareBlock(s, e.Finally)
+	case *ast.PrefixExpression:
+		c.declareExpression(s, e.Right)
+	case *ast.InfixExpression:
+		c.declareExpression(s, e.Left)
+		c.declareExpression(s, e.Right)
+	case *ast.CallExpression:
+		c.declareExpression(s, e.Function)
+		for _, arg := range e.Arguments {
+			c.declareExpression(s, arg)
+		}
+	case *ast.ArrayLiteral:
+		for _, el := range e.Elements {
+			c.declareExpression(s, el)
+		}
+	case *ast.HashLiteral:
+		for _, key := range e.Keys {
+			c.declareExpression(s, key)
+			c.declareExpression(s, e.Pairs[key])
+		}
+	case *ast.IndexExpression:
+		c.declareExpression(s, e.Left)
+		c.declareExpression(s, e.Index)
+	case *ast.MemberExpression:
+		c.declareExpression(s, e.Left)
+	case *ast.SliceExpression:
+		c.declareExpression(s, e.Left)
+		c.declareExpression(s, e.Low)
+		c.declareExpression(s, e.High)
+	}
+}
+
+// statements resolves the names the statements use and reports the first
+// of them that follows a statement that always leaves the block.
+func (c *checker) statements(s *scope, statements []ast.Statement) {
+	for i, statement := range statements {
+		if i > 0 && terminates(statements[i-1]) {
+			c.report(ast.Start(statement), DeadCode, "unreachable code")
+			// The rest is just as unreachable.
+			for _, rest := range statements[i:] {
+				c.statement(s, rest)
+			}
+			return
+		}
+		c.statement(s, statement)
+	}
+}
+
+func (c *checker) statement(s *scope, statement ast.Statement) {
+	switch st := statement.(type) {
+	case *ast.LetStatement:
+		c.expression(s, st.Value)
+		s.bindings[st.Name.Value].defined = true
+	case *ast.ExportStatement:
+		c.expression(s, st.Statement.Value)
+		s.bindings[st.Statement.Name.Value].defined = true
+	case *ast.ImportStatement:
+		s.bindings[st.Name.Value].defined = true
+	case *ast.ReturnStatement:
+		c.expression(s, st.Return)
+	case *ast.ThrowStatement:
+		c.expression(s, st.Value)
+	case *ast.AssignStatement:
+		c.assignment(s, st)
+	case *ast.ExpressionStatement:
+		c.expression(s, st.Expression)
+	case *ast.BlockStatement:
+		c.block(s, st)
+	case *ast.WhileStatement:
+		c.expression(s, st.Condition)
+		c.block(s, st.Body)
+	case *ast.ForStatement:
+		c.expression(s, st.Iterable)
+		c.block(s, st.Body)
+	}
+}
+
+func (c *checker) block(s *scope, block *ast.BlockStatement) {
+	if block != nil {
+		c.statements(s, block.Statements)
+	}
+}
+
+func (c *checker) assignment(s *scope, assign *ast.AssignStatement) {
+	target, ok := assign.Target.(*ast.Identifier)
+	if !ok {
+		c.expression(s, assign.Target)
+		c.expression(s, assign.Value)
+		return
+	}
+	c.expression(s, assign.Value)
+	b, early := c.resolve(s, target.Value)
+	if b == nil {
+		// Assignments only rebind names declared in the program, never
+		// builtins or host globals.
+		if early != nil {
+			c.report(target.Pos(), Undefined, "assignment to %s before its %s", target.Value, statementOf(early))
+			return
+		}
+		c.report(target.Pos(), Undefined, "assignment to undeclared name %s", target.Value)
+		return
+	}
+	b.assigned = true
+	if assign.Operator != "=" {
+		b.used = true
+	}
+}
+
+func (c *checker) identifier(s *scope, ident *ast.Identifier) *binding {
+	b, early := c.resolve(s, ident.Value)
+	if b != nil {
+		b.used = true
+		return b
+	}
+	if _, ok := evaluator.LookupBuiltin(ident.Value); ok || c.globals[ident.Value] {
+		return nil
+	}
+	if early != nil {
+		c.report(ident.Pos(), Undefined, "%s is used before its %s", ident.Value, statementOf(early))
+		return nil
+	}
+	c.report(ident.Pos(), Undefined, "undefined name %s", ident.Value)
+	return nil
+}
+
+// resolve returns the binding name refers to in code directly in s. A let
+// or import in s that hasn't been passed yet has not run when that code
+// does, so name refers to an outer binding instead, and early is the
+// binding that is still to come.
+func (c *checker) resolve(s *scope, name string) (b *binding, early *binding) {
+	if b, ok := s.bindings[name]; ok {
+		if b.defined {
+			return b, nil
+		}
+		early = b
+	}
+	return s.parent.lookup(name), early
+}
+
+func statementOf(b *binding) string {
+	if b.kind == importBinding {
+		return "import"
+	}
+	return "let"
+}
+
+func (c *checker) expression(s *scope, e ast.Expression) {
+	switch e := e.(type) {
+	case *ast.Identifier:
+		c.identifier(s, e)
+	case *ast.FunctionLiteral:
+		c.function(s, e)
+	case *ast.CallExpression:
+		switch fn := e.Function.(type) {
+		case *ast.Identifier:
+			if b := c.identifier(s, fn); b != nil {
+				c.calls = append(c.calls, call{binding: b, name: fn.Value, args: len(e.Arguments), pos: e.Pos()})
+			}
+		case *ast.FunctionLiteral:
+			c.function(s, fn)
+			c.checkArity("function literal", fn, len(e.Arguments), e.Pos())
+		default:
+			c.expression(s, e.Function)
+		}
+		for _, arg := range e.Arguments {
+			c.expression(s, arg)
+		}
+	case *ast.IfExpression:
+		c.expression(s, e.Condition)
+		c.block(s, e.Consequence)
+		c.block(s, e.Alternative)
+	case *ast.TryExpression:
+		c.block(s, e.Body)
+		c.block(s, e.Catch)
+		c.block(s, e.Finally)
+	case *ast.PrefixExpression:
+		c.expression(s, e.Right)
+	case *ast.InfixExpression:
+		c.expression(s, e.Left)
+		c.expression(s, e.Right)
+	case *ast.ArrayLiteral:
+		for _, el := range e.Elements {
+			c.expression(s, el)
+		}
+	case *ast.HashLiteral:
+		for _, key := range e.Keys {
+			c.expression(s, key)
+			c.expression(s, e.Pairs[key])
+		}
+	case *ast.IndexExpression:
+		c.expression(s, e.Left)
+		c.expression(s, e.Index)
+	case *ast.MemberExpression:
+		c.expression(s, e.Left)
+	case *ast.SliceExpression:
+		c.expression(s, e.Left)
+		c.expression(s, e.Low)
+		c.expression(s, e.High)
+	}
+}
+
+// function checks the body of fn in a scope of its own, then reports the
+// bindings in it that are never read. A name starting with an underscore
+// is meant to go unused.
+func (c *checker) function(parent *scope, fn *ast.FunctionLiteral) {
+	s := c.newScope(parent)
+	params := make([]*binding, len(fn.Parameters))
+	for i, param := range fn.Parameters {
+		params[i] = c.declare(s, param, paramBinding)
+	}
+	c.declareBlock(s, fn.Body)
+	c.block(s, fn.Body)
+
+	// Parameters before one that is read can't be left out, so only those
+	// after the last one read are reported.
+	for i := len(params) - 1; i >= 0 && !params[i].used; i-- {
+		if name := fn.Parameters[i].Value; !strings.HasPrefix(name, "_") {
+			c.report(fn.Parameters[i].Pos(), UnusedParam, "unused parameter %s", name)
+		}
+	}
+	for name, b := range s.bindings {
+		if b.kind == letBinding && !b.used && !strings.HasPrefix(name, "_") {
+			c.report(b.pos, UnusedLet, "unused variable %s", name)
+		}
+	}
+}
+
+func (c *checker) checkCalls() {
+	for _, call := range c.calls {
+		b := call.binding
+		if b.fn != nil && b.lets == 1 && !b.assigned {
+			c.checkArity(call.name, b.fn, call.args, call.pos)
+		}
+	}
+}
+
+// checkArity reports a call that passes fewer arguments than fn has
+// parameters. Both engines fail such a call but ignore extra arguments.
+func (c *checker) checkArity(name string, fn *ast.FunctionLiteral, args int, pos token.Position) {
+	if want := len(fn.Parameters); args < want {
+		c.report(pos, Arity, "wrong number of arguments to %s: want=%d, got=%d", name, want, args)
+	}
+}
+
+// terminates tells whether running statement always leaves the block it is
+// in, so that nothing after it runs.
+func terminates(statement ast.Statement) bool {
+	switch st := statement.(type) {
+	case *ast.ReturnStatement, *ast.ThrowStatement, *ast.BreakStatement, *ast.ContinueStatement:
+		return true
+	case *ast.BlockStatement:
+		return blockTerminates(st)
+	case *ast.ExpressionStatement:
+		switch e := st.Expression.(type) {
+		case *ast.IfExpression:
+			return e.Alternative != nil && blockTerminates(e.Consequence) && blockTerminates(e.Alternative)
+		case *ast.TryExpression:
+			if e.Finally != nil && blockTerminates(e.Finally) {
+				return true
+			}
+			return blockTerminates(e.Body) && (e.Catch == nil || blockTerminates(e.Catch))
+		}
+	}
+	return false
+}
+
+func blockTerminates(block *ast.BlockStatement) bool {
+	n := len(block.Statements)
+	return n > 0 && terminates(block.Statements[n-1])
+}
@@ -0,0 +1,137 @@
+package analysis_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/smiksha1701/buggy/analysis"
+	"github.com/smiksha1701/buggy/lexer"
+	"github.com/smiksha1701/buggy/parser"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"let x = 1; say(x + len([]));", nil},
+		{"say(y);", []string{"1:5: undefined name y [undefined]"}},
+		{"y = 1;", []string{"1:1: assignment to undeclared name y [undefined]"}},
+		{"len = 1;", []string{"1:1: assignment to undeclared name len [undefined]"}},
+		{"let x = 0; x += 1;", nil},
+		{"say(args);", nil},
+		// Nested functions see every name of the function declaring them,
+		// so functions can call each other in any order.
+		{"let f = fn() { g() }; let g = fn() { f() }; f();", nil},
+		{"say(x); let x = 1;", []string{"1:5: x is used before its let [undefined]"}},
+		{"x = 2; let x = 1;", []string{"1:1: assignment to x before its let [undefined]"}},
+		{"say(lib); import \"lib.bg\" as lib;", []string{"1:5: lib is used before its import [undefined]"}},
+		{"let f = fn() { let y = x; let x = 1; y + x };", []string{"1:24: x is used before its let [undefined]"}},
+		// Until its let runs, a name refers to the outer binding.
+		{"let x = 1; let f = fn() { let y = x; let x = 2; y + x };", nil},
+		{"let f = fn() { let len = len([1]); len };", []string{"1:20: len shadows the builtin len [shadowed-builtin]"}},
+		{"let f = fn(n) { if (n > 0) { let m = n - 1; } m };", nil},
+		{"let f = fn(xs) { for (k, v in xs) { say(v) } };", nil},
+		{"let f = fn() { try { 1 } catch (e) { say(e) } };", nil},
+		{"let outer = fn(a) { fn() { a } };", nil},
+		{"import \"lib.bg\" as lib; say(lib.anything);", nil},
+		{"export let x = 1;", nil},
+		{
+			"let f = fn() { let x = 1; let _y = 2; 0 };",
+			[]string{"1:20: unused variable x [unused-let]"},
+		},
+		{"let f = fn() { let x = 1; x = 2; 0 };", []string{"1:20: unused variable x [unused-let]"}},
+		{"let unused = 1;", nil},
+		{
+			"let f = fn(a, b, c) { b };",
+			[]string{"1:18: unused parameter c [unused-param]"},
+		},
+		{"let f = fn(a, _b) { 0 };", []string{"1:12: unused parameter a [unused-param]"}},
+		{"let f = fn(a) { fn() { a } };", nil},
+		{
+			"let len = 1; let f = fn(first) { let say = first; say };",
+			[]string{
+				"1:5: len shadows the builtin len [shadowed-builtin]",
+				"1:25: first shadows the builtin first [shadowed-builtin]",
+				"1:38: say shadows the builtin say [shadowed-builtin]",
+			},
+		},
+		{
+			"for (keys in []) { say(keys) }",
+			[]string{"1:6: keys shadows the builtin keys [shadowed-builtin]"},
+		},
+		{
+			"let add = fn(a, b) { a + b }; add(1); add(1, 2); add(1, 2, 3);",
+			[]string{"1:34: wrong number of arguments to add: want=2, got=1 [arity]"},
+		},
+		// Extra arguments are ignored when the function is called.
+		{"fn(x) { x }(1, 2);", nil},
+		{"fn(x, y) { x + y }(1);", []string{"1:19: wrong number of arguments to function literal: want=2, got=1 [arity]"}},
+		// The function a name is bound to is only known if nothing else is
+		// ever assigned to it.
+		{"let f = fn(a) { a }; f = fn() { 0 }; f();", nil},
+		{"let f = fn(a) { a }; let f = fn() { 0 }; f();", nil},
+		{"let f = fn(a) { a }; say(f, map([1], f));", nil},
+		{
+			"let f = fn() { return 1; say(2); say(3) };",
+			[]string{"1:26: unreachable code [dead-code]"},
+		},
+		{
+			"while (true) { break; say(1) }",
+			[]string{"1:23: unreachable code [dead-code]"},
+		},
+		{
+			"let f = fn(x) { if (x) { return 1 } else { throw \"no\" }; x = 2; x };",
+			[]string{"1:58: unreachable code [dead-code]"},
+		},
+		{"let f = fn(x) { if (x) { return 1 }; 2 };", nil},
+		{"let f = fn() { try { return 1 } catch (e) { say(e) }; 2 };", nil},
+		{
+			"let f = fn() { try { 1 } finally { return 2 }; 3 };",
+			[]string{"1:48: unreachable code [dead-code]"},
+		},
+		{
+			"let f = fn() { return 1; nope };",
+			[]string{"1:26: unreachable code [dead-code]", "1:26: undefined name nope [undefined]"},
+		},
+	}
+
+	for _, tt := range tests {
+		findings := check(t, tt.input, analysis.Config{Globals: []string{"args"}})
+		if strings.Join(findings, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("wrong findings for %q.\nexpected=\n%s\ngot=\n%s",
+				tt.input, strings.Join(tt.expected, "\n"), strings.Join(findings, "\n"))
+		}
+	}
+}
+
+func TestCheckDisabledRules(t *testing.T) {
+	input := "let f = fn(a) { let x = 1; return 0; say(y) }; f();"
+	config := analysis.Config{Disabled: map[analysis.Rule]bool{
+		analysis.UnusedLet:   true,
+		analysis.UnusedParam: true,
+		analysis.DeadCode:    true,
+	}}
+	expected := []string{
+		"1:42: undefined name y [undefined]",
+		"1:49: wrong number of arguments to f: want=1, got=0 [arity]",
+	}
+	findings := check(t, input, config)
+	if strings.Join(findings, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("wrong findings.\nexpected=\n%s\ngot=\n%s", strings.Join(expected, "\n"), strings.Join(findings, "\n"))
+	}
+}
+
+func check(t *testing.T, input string, config analysis.Config) []string {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+	findings := []string{}
+	for _, finding := range analysis.Check(program, config) {
+		findings = append(findings, finding.String())
+	}
+	return findings
+}
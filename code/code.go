@@ -0,0 +1,255 @@
+package code
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	"github.com/smiksha1701/buggy/token"
+)
+
+type Instructions []byte
+
+func (ins Instructions) String() string {
+	var out bytes.Buffer
+
+	i := 0
+	for i < len(ins) {
+		def, err := Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			i++
+			continue
+		}
+		operands, read := ReadOperands(def, ins[i+1:])
+		fmt.Fprintf(&out, "%04d %s\n", i, fmtInstruction(def, operands))
+		i += 1 + read
+	}
+	return out.String()
+}
+
+func fmtInstruction(def *Definition, operands []int) string {
+	if len(operands) != len(def.OperandWidths) {
+		return fmt.Sprintf("ERROR: operand len %d does not match defined %d\n", len(operands), len(def.OperandWidths))
+	}
+	switch len(operands) {
+	case 0:
+		return def.Name
+	case 1:
+		return fmt.Sprintf("%s %d", def.Name, operands[0])
+	case 2:
+		return fmt.Sprintf("%s %d %d", def.Name, operands[0], operands[1])
+	}
+	return fmt.Sprintf("ERROR: unhandled operand count for %s\n", def.Name)
+}
+
+type Opcode byte
+
+const (
+	OpConstant Opcode = iota
+	OpPop
+	OpTrue
+	OpFalse
+	OpNull
+	OpAdd
+	OpSub
+	OpMul
+	OpDiv
+	OpEqual
+	OpNotEqual
+	OpLessThan
+	OpGreaterThan
+	OpMinus
+	OpBang
+	OpJump
+	OpJumpNotTruthy
+	OpGetGlobal
+	OpSetGlobal
+	OpGetLocal
+	OpSetLocal
+	OpGetOuter
+	OpArray
+	OpHash
+	OpIndex
+	OpCall
+	OpReturnValue
+	OpReturn
+	OpClosure
+	OpGetIter
+	OpIterNext
+	OpAssignGlobal
+	OpAssignLocal
+	OpAssignOuter
+	OpSetIndex
+	OpMod
+	OpLessEqual
+	OpGreaterEqual
+	OpSlice
+	OpTry
+	OpEndTry
+	OpThrow
+	OpImport
+	OpMember
+	OpTailCall
+)
+
+// SourcePos records that the instructions from Offset on were compiled from
+// source code at Pos.
+type SourcePos struct {
+	Offset int
+	Pos    token.Position
+}
+
+// SourceMap lists SourcePos entries in increasing Offset order, so the vm
+// can tell where in the source an instruction came from.
+type SourceMap []SourcePos
+
+// Lookup returns the source position of the instruction at offset.
+func (m SourceMap) Lookup(offset int) token.Position {
+	i := sort.Search(len(m), func(i int) bool { return m[i].Offset > offset })
+	if i == 0 {
+		return token.Position{}
+	}
+	return m[i-1].Pos
+}
+
+// Definition describes an opcode: its readable name and how many bytes
+// each of its operands takes.
+type Definition struct {
+	Name          string
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant:      {"OpConstant", []int{2}},
+	OpPop:           {"OpPop", []int{}},
+	OpTrue:          {"OpTrue", []int{}},
+	OpFalse:         {"OpFalse", []int{}},
+	OpNull:          {"OpNull", []int{}},
+	OpAdd:           {"OpAdd", []int{}},
+	OpSub:           {"OpSub", []int{}},
+	OpMul:           {"OpMul", []int{}},
+	OpDiv:           {"OpDiv", []int{}},
+	OpEqual:         {"OpEqual", []int{}},
+	OpNotEqual:      {"OpNotEqual", []int{}},
+	OpLessThan:      {"OpLessThan", []int{}},
+	OpGreaterThan:   {"OpGreaterThan", []int{}},
+	OpMinus:         {"OpMinus", []int{}},
+	OpBang:          {"OpBang", []int{}},
+	OpJump:          {"OpJump", []int{2}},
+	OpJumpNotTruthy: {"OpJumpNotTruthy", []int{2}},
+	OpGetGlobal:     {"OpGetGlobal", []int{2}},
+	OpSetGlobal:     {"OpSetGlobal", []int{2}},
+	OpGetLocal:      {"OpGetLocal", []int{1}},
+	OpSetLocal:      {"OpSetLocal", []int{1}},
+	// OpGetOuter reads a local of an enclosing function: the first operand
+	// says how many scopes to walk up, the second is the slot there.
+	OpGetOuter:    {"OpGetOuter", []int{1, 1}},
+	OpArray:       {"OpArray", []int{2}},
+	OpHash:        {"OpHash", []int{2}},
+	OpIndex:       {"OpIndex", []int{}},
+	OpCall:        {"OpCall", []int{1}},
+	OpReturnValue: {"OpReturnValue", []int{}},
+	OpReturn:      {"OpReturn", []int{}},
+	OpClosure:     {"OpClosure", []int{2}},
+	OpGetIter:     {"OpGetIter", []int{}},
+	// OpIterNext pushes the next item of the iterator on top of the stack,
+	// or jumps to the first operand once it is exhausted. The second operand
+	// is 1 to push a single item or 2 for key and value.
+	OpIterNext: {"OpIterNext", []int{2, 1}},
+	// The assign opcodes store into a variable like their Set and Get
+	// counterparts, but fail if it has not been declared yet.
+	OpAssignGlobal: {"OpAssignGlobal", []int{2}},
+	OpAssignLocal:  {"OpAssignLocal", []int{1}},
+	OpAssignOuter:  {"OpAssignOuter", []int{1, 1}},
+	// OpSetIndex pops a value, an index and a collection and stores the
+	// value there. A non-zero operand is the opcode of a compound operator
+	// to apply to the current element and the value first.
+	OpSetIndex:     {"OpSetIndex", []int{1}},
+	OpMod:          {"OpMod", []int{}},
+	OpLessEqual:    {"OpLessEqual", []int{}},
+	OpGreaterEqual: {"OpGreaterEqual", []int{}},
+	// OpSlice pops the high and low bounds, either of which may be null,
+	// and the value to slice.
+	OpSlice: {"OpSlice", []int{}},
+	// OpTry installs an error handler at the first operand until the
+	// matching OpEndTry. When an error unwinds to it, the handler gets
+	// the error converted to a hash if the second operand is 1, for a
+	// catch clause, or the error itself to rethrow after a finally block.
+	OpTry:    {"OpTry", []int{2, 1}},
+	OpEndTry: {"OpEndTry", []int{}},
+	// OpThrow pops a value and raises it as an error. An *object.Error is
+	// rethrown as it is.
+	OpThrow: {"OpThrow", []int{}},
+	// OpImport pushes the module in the file named by the string constant
+	// of its operand, running the file on its first import.
+	OpImport: {"OpImport", []int{2}},
+	// OpMember pops a module and pushes its export named by the string
+	// constant of its operand.
+	OpMember: {"OpMember", []int{2}},
+	// OpTailCall is OpCall for a call whose value the function returns.
+	// A closure called this way takes over the frame of the caller. For
+	// anything else it is a normal call, which the OpReturnValue after it
+	// returns from.
+	OpTailCall: {"OpTailCall", []int{1}},
+}
+
+func Lookup(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+	return def, nil
+}
+
+// Make encodes an instruction. Operands are big-endian.
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+
+	instructionLen := 1
+	for _, w := range def.OperandWidths {
+		instructionLen += w
+	}
+
+	instruction := make([]byte, instructionLen)
+	instruction[0] = byte(op)
+
+	offset := 1
+	for i, o := range operands {
+		width := def.OperandWidths[i]
+		switch width {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 1:
+			instruction[offset] = byte(o)
+		}
+		offset += width
+	}
+	return instruction
+}
+
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+
+	for i, width := range def.OperandWidths {
+		switch width {
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		}
+		offset += width
+	}
+	return operands, offset
+}
+
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+func ReadUint8(ins Instructions) uint8 { return uint8(ins[0]) }
@@ -0,0 +1,834 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/smiksha1701/buggy/ast"
+	"github.com/smiksha1701/buggy/code"
+	"github.com/smiksha1701/buggy/object"
+	"github.com/smiksha1701/buggy/token"
+)
+
+type EmittedInstruction struct {
+	Opcode   code.Opcode
+	Position int
+}
+
+type CompilationScope struct {
+	instructions        code.Instructions
+	sourceMap           code.SourceMap
+	lastInstruction     EmittedInstruction
+	previousInstruction EmittedInstruction
+	loops               []*loop
+	tries               []*tryBlock
+}
+
+// loop tracks the jumps of the innermost loop being compiled: continue
+// jumps back to start, break jumps are patched once the end is known.
+// tries is how many try blocks were open when the loop started.
+type loop struct {
+	start  int
+	breaks []int
+	tries  int
+}
+
+// tryBlock is a try or catch block being compiled under an error handler.
+// Leaving it early with return, break or continue removes the handler and
+// runs finally, if there is one, on the way out.
+type tryBlock struct {
+	finally *ast.BlockStatement
+}
+
+type Compiler struct {
+	constants   []object.Object
+	symbolTable *SymbolTable
+
+	scopes     []CompilationScope
+	scopeIndex int
+
+	// pos is the position of the node being compiled, recorded in the
+	// source map of every instruction emitted for it.
+	pos token.Position
+}
+
+// Bytecode is what the compiler hands to the vm. GlobalNames lists the name
+// of every global slot so the vm can report unknown identifiers, and
+// ScopedGlobals the slots whose names were only visible in a block.
+// SourceMap locates the main program's instructions in the source.
+type Bytecode struct {
+	Instructions  code.Instructions
+	Constants     []object.Object
+	GlobalNames   []string
+	ScopedGlobals map[int]bool
+	SourceMap     code.SourceMap
+}
+
+func New() *Compiler {
+	mainScope := CompilationScope{instructions: code.Instructions{}}
+	return &Compiler{
+		constants:   []object.Object{},
+		symbolTable: NewSymbolTable(),
+		scopes:      []CompilationScope{mainScope},
+	}
+}
+
+// NewWithState creates a compiler that keeps adding to the globals and
+// constants of an earlier one, as the REPL does line by line.
+func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
+	c := New()
+	c.symbolTable = s
+	c.constants = constants
+	return c
+}
+
+// Compile is a wrapper around compile that tracks the position of the node
+// being compiled for the source map.
+func (c *Compiler) Compile(node ast.Node) error {
+	saved := c.pos
+	c.pos = node.Pos()
+	err := c.compile(node)
+	c.pos = saved
+	return err
+}
+
+func (c *Compiler) compile(node ast.Node) error {
+	switch node := node.(type) {
+	case *ast.Program:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+
+	case *ast.ExpressionStatement:
+		if err := c.Compile(node.Expression); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+
+	case *ast.BlockStatement:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+
+	case *ast.LetStatement:
+		// A function literal may refer to itself, so its name is bound
+		// before the body is compiled. Any other value is compiled first:
+		// `let x = x + 1` reads the x of an enclosing scope.
+		if _, ok := node.Value.(*ast.FunctionLiteral); ok {
+			symbol := c.symbolTable.Define(node.Name.Value)
+			if err := c.Compile(node.Value); err != nil {
+				return err
+			}
+			return c.storeSymbol(symbol)
+		}
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		return c.storeSymbol(c.symbolTable.Define(node.Name.Value))
+
+	case *ast.ReturnStatement:
+		// A function's own try blocks have to see the outcome of the call,
+		// and the main program has no caller to return to.
+		var err error
+		if c.scopeIndex > 0 && len(c.scopes[c.scopeIndex].tries) == 0 {
+			err = c.compileTail(node.Return)
+		} else {
+			err = c.Compile(node.Return)
+		}
+		if err != nil {
+			return err
+		}
+		if err := c.leaveTries(0); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+
+	case *ast.ThrowStatement:
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		c.emit(code.OpThrow)
+
+	case *ast.Identifier:
+		symbol, ok := c.symbolTable.Resolve(node.Value)
+		if !ok {
+			symbol, ok = c.symbolTable.ResolveDeclared(node.Value)
+		}
+		if !ok {
+			// Globals may be defined after the functions that use them
+			// and builtins are looked up by name, so an unknown name gets
+			// a global slot that the vm checks when it is read.
+			symbol = c.symbolTable.Global().Define(node.Value)
+		}
+		return c.loadSymbol(symbol)
+
+	case *ast.AssignStatement:
+		return c.compileAssignment(node)
+
+	case *ast.ImportStatement:
+		c.emit(code.OpImport, c.addConstant(&object.String{Value: node.Path.Value}))
+		return c.storeSymbol(c.symbolTable.Define(node.Name.Value))
+
+	case *ast.ExportStatement:
+		return c.Compile(node.Statement)
+
+	case *ast.WhileStatement:
+		start := len(c.currentInstructions())
+		if err := c.Compile(node.Condition); err != nil {
+			return err
+		}
+		jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+		if err := c.compileLoopBody(start, node.Body); err != nil {
+			return err
+		}
+		c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+		c.leaveLoop()
+
+	case *ast.ForStatement:
+		if err := c.Compile(node.Iterable); err != nil {
+			return err
+		}
+		c.emit(code.OpGetIter)
+		start := len(c.currentInstructions())
+		items := 1
+		if node.Key != nil {
+			items = 2
+		}
+		iterNextPos := c.emit(code.OpIterNext, 9999, items)
+		if err := c.storeSymbol(c.symbolTable.Define(node.Value.Value)); err != nil {
+			return err
+		}
+		if node.Key != nil {
+			if err := c.storeSymbol(c.symbolTable.Define(node.Key.Value)); err != nil {
+				return err
+			}
+		}
+		if err := c.compileLoopBody(start, node.Body); err != nil {
+			return err
+		}
+		// Breaks land on the OpPop that discards the iterator, just like
+		// OpIterNext does when it is exhausted.
+		c.replaceInstruction(iterNextPos, code.Make(code.OpIterNext, len(c.currentInstructions()), items))
+		c.leaveLoop()
+		c.emit(code.OpPop)
+
+	case *ast.BreakStatement:
+		loops := c.scopes[c.scopeIndex].loops
+		if len(loops) == 0 {
+			return fmt.Errorf("%s: break outside loop", node.Pos())
+		}
+		current := loops[len(loops)-1]
+		if err := c.leaveTries(current.tries); err != nil {
+			return err
+		}
+		current.breaks = append(current.breaks, c.emit(code.OpJump, 9999))
+
+	case *ast.ContinueStatement:
+		loops := c.scopes[c.scopeIndex].loops
+		if len(loops) == 0 {
+			return fmt.Errorf("%s: continue outside loop", node.Pos())
+		}
+		current := loops[len(loops)-1]
+		if err := c.leaveTries(current.tries); err != nil {
+			return err
+		}
+		c.emit(code.OpJump, current.start)
+
+	case *ast.IntegerLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Integer{Value: node.Value}))
+
+	case *ast.FloatLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Float{Value: node.Value}))
+
+	case *ast.StringLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.String{Value: node.Value}))
+
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+
+	case *ast.PrefixExpression:
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		switch node.Operator {
+		case "!":
+			c.emit(code.OpBang)
+		case "-":
+			c.emit(code.OpMinus)
+		default:
+			return fmt.Errorf("%s: unknown operator %s", node.Pos(), node.Operator)
+		}
+
+	case *ast.InfixExpression:
+		if node.Operator == "&&" || node.Operator == "||" {
+			return c.compileLogical(node)
+		}
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		op, ok := infixOpcodes[node.Operator]
+		if !ok {
+			return fmt.Errorf("%s: unknown operator %s", node.Pos(), node.Operator)
+		}
+		c.emit(op)
+
+	case *ast.IfExpression:
+		return c.compileIf(node, c.compileBlockValue)
+
+	case *ast.TryExpression:
+		return c.compileTry(node)
+
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			if err := c.Compile(el); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpArray, len(node.Elements))
+
+	case *ast.HashLiteral:
+		for _, k := range node.Keys {
+			if err := c.Compile(k); err != nil {
+				return err
+			}
+			if err := c.Compile(node.Pairs[k]); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpHash, len(node.Pairs)*2)
+
+	case *ast.IndexExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Index); err != nil {
+			return err
+		}
+		c.emit(code.OpIndex)
+
+	case *ast.MemberExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		c.emit(code.OpMember, c.addConstant(&object.String{Value: node.Name.Value}))
+
+	case *ast.SliceExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		for _, bound := range []ast.Expression{node.Low, node.High} {
+			if bound == nil {
+				c.emit(code.OpNull)
+			} else if err := c.Compile(bound); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpSlice)
+
+	case *ast.FunctionLiteral:
+		c.enterScope()
+		for _, p := range node.Parameters {
+			c.symbolTable.Define(p.Value)
+		}
+		c.symbolTable.Declare(letNames(node.Body))
+		if err := c.compileBody(node.Body); err != nil {
+			return err
+		}
+		if !c.lastInstructionIs(code.OpReturnValue) {
+			c.emit(code.OpReturn)
+		}
+		names := c.symbolTable.Names()
+		sourceMap := c.scopes[c.scopeIndex].sourceMap
+		instructions := c.leaveScope()
+		if len(names) > 256 {
+			return fmt.Errorf("%s: too many local variables in function", node.Pos())
+		}
+		fn := &object.CompiledFunction{
+			Instructions:  instructions,
+			NumLocals:     len(names),
+			NumParameters: len(node.Parameters),
+			LocalNames:    names,
+			Name:          node.Name,
+			SourceMap:     sourceMap,
+		}
+		c.emit(code.OpClosure, c.addConstant(fn))
+
+	case *ast.CallExpression:
+		return c.compileCall(node, code.OpCall)
+
+	default:
+		return fmt.Errorf("%s: cannot compile %T", node.Pos(), node)
+	}
+	return nil
+}
+
+var infixOpcodes = map[string]code.Opcode{
+	"+":  code.OpAdd,
+	"-":  code.OpSub,
+	"*":  code.OpMul,
+	"/":  code.OpDiv,
+	"%":  code.OpMod,
+	"==": code.OpEqual,
+	"!=": code.OpNotEqual,
+	"<":  code.OpLessThan,
+	">":  code.OpGreaterThan,
+	"<=": code.OpLessEqual,
+	">=": code.OpGreaterEqual,
+}
+
+// compileLogical compiles && and || with jumps so the right operand only
+// runs when the left one does not decide the result. Both leave true or
+// false on the stack, as in the evaluator.
+func (c *Compiler) compileLogical(node *ast.InfixExpression) error {
+	if err := c.Compile(node.Left); err != nil {
+		return err
+	}
+	// For && a falsy left operand jumps straight to false. For || a truthy
+	// one falls through to an early true that jumps to the end.
+	leftJump := c.emit(code.OpJumpNotTruthy, 9999)
+	if node.Operator == "||" {
+		c.emit(code.OpTrue)
+		toRight := leftJump
+		leftJump = c.emit(code.OpJump, 9999)
+		c.changeOperand(toRight, len(c.currentInstructions()))
+	}
+	if err := c.Compile(node.Right); err != nil {
+		return err
+	}
+	rightJump := c.emit(code.OpJumpNotTruthy, 9999)
+	c.emit(code.OpTrue)
+	endJump := c.emit(code.OpJump, 9999)
+	falsePos := c.emit(code.OpFalse)
+	end := len(c.currentInstructions())
+
+	c.changeOperand(rightJump, falsePos)
+	c.changeOperand(endJump, end)
+	if node.Operator == "&&" {
+		c.changeOperand(leftJump, falsePos)
+	} else {
+		c.changeOperand(leftJump, end)
+	}
+	return nil
+}
+
+// compileAssignment compiles `target = value` and its compound forms. The
+// operator of a compound assignment on an index is applied by OpSetIndex so
+// the collection and index are evaluated only once.
+func (c *Compiler) compileAssignment(node *ast.AssignStatement) error {
+	compound := node.Operator != "="
+	var op code.Opcode
+	if compound {
+		var ok bool
+		op, ok = infixOpcodes[strings.TrimSuffix(node.Operator, "=")]
+		if !ok {
+			return fmt.Errorf("%s: unknown operator %s", node.Pos(), node.Operator)
+		}
+	}
+
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		if compound {
+			if err := c.Compile(target); err != nil {
+				return err
+			}
+		}
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		if compound {
+			c.emit(op)
+		}
+		symbol, ok := c.symbolTable.Resolve(target.Value)
+		if !ok {
+			symbol, ok = c.symbolTable.ResolveDeclared(target.Value)
+		}
+		if !ok {
+			symbol = c.symbolTable.Global().Define(target.Value)
+		}
+		return c.assignSymbol(symbol)
+
+	case *ast.IndexExpression:
+		if err := c.Compile(target.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(target.Index); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		c.emit(code.OpSetIndex, int(op))
+
+	default:
+		return fmt.Errorf("%s: cannot assign to %s", node.Pos(), node.Target.String())
+	}
+	return nil
+}
+
+// compileBlockValue compiles a block that is used as an expression, leaving
+// the value of its last statement, or null, on the stack.
+func (c *Compiler) compileBlockValue(block *ast.BlockStatement) error {
+	if err := c.Compile(block); err != nil {
+		return err
+	}
+	if endsWithExpression(block) && c.lastInstructionIs(code.OpPop) {
+		c.removeLastPop()
+	} else {
+		c.emit(code.OpNull)
+	}
+	return nil
+}
+
+// compileIf compiles an if expression, the branches with compileBranch.
+func (c *Compiler) compileIf(node *ast.IfExpression, compileBranch func(*ast.BlockStatement) error) error {
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	if err := compileBranch(node.Consequence); err != nil {
+		return err
+	}
+	jumpPos := c.emit(code.OpJump, 9999)
+	c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+
+	if node.Alternative == nil {
+		c.emit(code.OpNull)
+	} else if err := compileBranch(node.Alternative); err != nil {
+		return err
+	}
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+	return nil
+}
+
+// compileCall compiles a call with op, OpCall or OpTailCall.
+func (c *Compiler) compileCall(node *ast.CallExpression, op code.Opcode) error {
+	if err := c.Compile(node.Function); err != nil {
+		return err
+	}
+	for _, a := range node.Arguments {
+		if err := c.Compile(a); err != nil {
+			return err
+		}
+	}
+	if len(node.Arguments) > 255 {
+		return fmt.Errorf("%s: too many arguments in call", node.Pos())
+	}
+	c.emit(op, len(node.Arguments))
+	return nil
+}
+
+// compileBody compiles the body of a function, returning the value of its
+// last statement if that is an expression.
+func (c *Compiler) compileBody(body *ast.BlockStatement) error {
+	if !endsWithExpression(body) {
+		return c.Compile(body)
+	}
+	if err := c.compileTailBlock(body); err != nil {
+		return err
+	}
+	c.emit(code.OpReturnValue)
+	return nil
+}
+
+// compileTailBlock is compileBlockValue for a block whose value the
+// function returns: its last expression is compiled with compileTail.
+func (c *Compiler) compileTailBlock(block *ast.BlockStatement) error {
+	if !endsWithExpression(block) {
+		return c.compileBlockValue(block)
+	}
+	last := len(block.Statements) - 1
+	for _, s := range block.Statements[:last] {
+		if err := c.Compile(s); err != nil {
+			return err
+		}
+	}
+	stmt := block.Statements[last].(*ast.ExpressionStatement)
+	saved := c.pos
+	c.pos = stmt.Pos()
+	err := c.compileTail(stmt.Expression)
+	c.pos = saved
+	return err
+}
+
+// compileTail compiles an expression whose value the function returns,
+// like the evaluator's evalTailExpression: a call becomes a tail call, and
+// the branches of an if expression are in tail position in turn.
+func (c *Compiler) compileTail(node ast.Expression) error {
+	saved := c.pos
+	c.pos = node.Pos()
+	defer func() { c.pos = saved }()
+	switch node := node.(type) {
+	case *ast.CallExpression:
+		return c.compileCall(node, code.OpTailCall)
+	case *ast.IfExpression:
+		return c.compileIf(node, c.compileTailBlock)
+	}
+	return c.compile(node)
+}
+
+// endsWithExpression reports whether the last statement of block is an
+// expression statement, whose OpPop then ends the block. Other statements,
+// such as loops, may end with an OpPop of their own.
+func endsWithExpression(block *ast.BlockStatement) bool {
+	if len(block.Statements) == 0 {
+		return false
+	}
+	_, ok := block.Statements[len(block.Statements)-1].(*ast.ExpressionStatement)
+	return ok
+}
+
+// compileTry compiles a try expression. The try block runs under a handler
+// that jumps to the catch clause, or to a copy of the finally block that
+// rethrows the error when there is no catch. With both, the catch block
+// runs under a handler of its own so that finally still runs if it fails.
+func (c *Compiler) compileTry(node *ast.TryExpression) error {
+	catch := 0
+	if node.Catch != nil {
+		catch = 1
+	}
+	handlerPos := c.emit(code.OpTry, 9999, catch)
+	if err := c.compileGuarded(node.Body, node.Finally); err != nil {
+		return err
+	}
+	jumps := []int{c.emit(code.OpJump, 9999)}
+
+	if node.Catch != nil {
+		c.replaceInstruction(handlerPos, code.Make(code.OpTry, len(c.currentInstructions()), 1))
+		// The catch block is a scope of its own, like the environment the
+		// evaluator runs it in.
+		block := c.symbolTable.OpenBlock(append([]string{node.CatchParam.Value}, letNames(node.Catch)...))
+		// The handler leaves the error, as a hash, on the stack.
+		if err := c.storeSymbol(c.symbolTable.Define(node.CatchParam.Value)); err != nil {
+			return err
+		}
+		if node.Finally == nil {
+			if err := c.compileBlockValue(node.Catch); err != nil {
+				return err
+			}
+		} else {
+			handlerPos = c.emit(code.OpTry, 9999, 0)
+			if err := c.compileGuarded(node.Catch, node.Finally); err != nil {
+				return err
+			}
+			jumps = append(jumps, c.emit(code.OpJump, 9999))
+		}
+		c.symbolTable.CloseBlock(block)
+	}
+
+	if node.Finally != nil {
+		c.replaceInstruction(handlerPos, code.Make(code.OpTry, len(c.currentInstructions()), 0))
+		if err := c.Compile(node.Finally); err != nil {
+			return err
+		}
+		c.emit(code.OpThrow)
+	}
+	for _, pos := range jumps {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+	if node.Finally != nil {
+		return c.Compile(node.Finally)
+	}
+	return nil
+}
+
+// compileGuarded compiles block for its value under the handler installed
+// just before it and removes the handler at the end.
+func (c *Compiler) compileGuarded(block, finally *ast.BlockStatement) error {
+	scope := &c.scopes[c.scopeIndex]
+	scope.tries = append(scope.tries, &tryBlock{finally: finally})
+	err := c.compileBlockValue(block)
+	scope = &c.scopes[c.scopeIndex]
+	scope.tries = scope.tries[:len(scope.tries)-1]
+	if err != nil {
+		return err
+	}
+	c.emit(code.OpEndTry)
+	return nil
+}
+
+// leaveTries removes the handlers of the try blocks opened after the first
+// depth ones and runs their finally blocks, innermost first, before a jump
+// out of them.
+func (c *Compiler) leaveTries(depth int) error {
+	tries := c.scopes[c.scopeIndex].tries
+	for i := len(tries) - 1; i >= depth; i-- {
+		c.emit(code.OpEndTry)
+		if tries[i].finally == nil {
+			continue
+		}
+		// The finally block runs outside of its own try block; the
+		// capacity limit keeps tries intact if it opens another one.
+		c.scopes[c.scopeIndex].tries = tries[:i:i]
+		err := c.Compile(tries[i].finally)
+		c.scopes[c.scopeIndex].tries = tries
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compileLoopBody compiles the statements of a loop followed by the jump
+// back to start. The loop stays open until leaveLoop patches its breaks.
+func (c *Compiler) compileLoopBody(start int, body *ast.BlockStatement) error {
+	scope := &c.scopes[c.scopeIndex]
+	scope.loops = append(scope.loops, &loop{start: start, tries: len(scope.tries)})
+	if err := c.Compile(body); err != nil {
+		return err
+	}
+	c.emit(code.OpJump, start)
+	return nil
+}
+
+func (c *Compiler) leaveLoop() {
+	scope := &c.scopes[c.scopeIndex]
+	current := scope.loops[len(scope.loops)-1]
+	scope.loops = scope.loops[:len(scope.loops)-1]
+	end := len(c.currentInstructions())
+	for _, pos := range current.breaks {
+		c.changeOperand(pos, end)
+	}
+}
+
+func (c *Compiler) loadSymbol(s Symbol) error {
+	switch {
+	case s.Scope == GlobalScope:
+		c.emit(code.OpGetGlobal, s.Index)
+	case s.Depth == 0:
+		c.emit(code.OpGetLocal, s.Index)
+	case s.Depth < 256:
+		c.emit(code.OpGetOuter, s.Depth, s.Index)
+	default:
+		return fmt.Errorf("functions nested too deeply to reach %s", s.Name)
+	}
+	return nil
+}
+
+func (c *Compiler) storeSymbol(s Symbol) error {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpSetGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpSetLocal, s.Index)
+	}
+	return nil
+}
+
+func (c *Compiler) assignSymbol(s Symbol) error {
+	switch {
+	case s.Scope == GlobalScope:
+		c.emit(code.OpAssignGlobal, s.Index)
+	case s.Depth == 0:
+		c.emit(code.OpAssignLocal, s.Index)
+	case s.Depth < 256:
+		c.emit(code.OpAssignOuter, s.Depth, s.Index)
+	default:
+		return fmt.Errorf("functions nested too deeply to reach %s", s.Name)
+	}
+	return nil
+}
+
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions:  c.currentInstructions(),
+		Constants:     c.constants,
+		GlobalNames:   c.symbolTable.Global().Names(),
+		ScopedGlobals: c.symbolTable.Global().Scoped(),
+		SourceMap:     c.scopes[c.scopeIndex].sourceMap,
+	}
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	ins := code.Make(op, operands...)
+	pos := c.addInstruction(ins)
+	c.setLastInstruction(op, pos)
+	return pos
+}
+
+func (c *Compiler) addInstruction(ins []byte) int {
+	posNewInstruction := len(c.currentInstructions())
+	scope := &c.scopes[c.scopeIndex]
+	if n := len(scope.sourceMap); c.pos.IsValid() && (n == 0 || scope.sourceMap[n-1].Pos != c.pos) {
+		scope.sourceMap = append(scope.sourceMap, code.SourcePos{Offset: posNewInstruction, Pos: c.pos})
+	}
+	scope.instructions = append(c.currentInstructions(), ins...)
+	return posNewInstruction
+}
+
+func (c *Compiler) setLastInstruction(op code.Opcode, pos int) {
+	previous := c.scopes[c.scopeIndex].lastInstruction
+	c.scopes[c.scopeIndex].previousInstruction = previous
+	c.scopes[c.scopeIndex].lastInstruction = EmittedInstruction{Opcode: op, Position: pos}
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
+	if len(c.currentInstructions()) == 0 {
+		return false
+	}
+	return c.scopes[c.scopeIndex].lastInstruction.Opcode == op
+}
+
+func (c *Compiler) removeLastPop() {
+	last := c.scopes[c.scopeIndex].lastInstruction
+	previous := c.scopes[c.scopeIndex].previousInstruction
+
+	c.scopes[c.scopeIndex].instructions = c.currentInstructions()[:last.Position]
+	c.scopes[c.scopeIndex].lastInstruction = previous
+	c.truncateSourceMap(last.Position)
+}
+
+// truncateSourceMap drops the source map entries of instructions removed
+// from offset on.
+func (c *Compiler) truncateSourceMap(offset int) {
+	scope := &c.scopes[c.scopeIndex]
+	for len(scope.sourceMap) > 0 && scope.sourceMap[len(scope.sourceMap)-1].Offset >= offset {
+		scope.sourceMap = scope.sourceMap[:len(scope.sourceMap)-1]
+	}
+}
+
+func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
+	ins := c.currentInstructions()
+	for i := 0; i < len(newInstruction); i++ {
+		ins[pos+i] = newInstruction[i]
+	}
+}
+
+func (c *Compiler) changeOperand(opPos int, operand int) {
+	op := code.Opcode(c.currentInstructions()[opPos])
+	c.replaceInstruction(opPos, code.Make(op, operand))
+}
+
+func (c *Compiler) enterScope() {
+	c.scopes = append(c.scopes, CompilationScope{instructions: code.Instructions{}})
+	c.scopeIndex++
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() code.Instructions {
+	instructions := c.currentInstructions()
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+	c.symbolTable = c.symbolTable.Outer
+	return instructions
+}
@@ -0,0 +1,94 @@
+package compiler
+
+import "github.com/smiksha1701/buggy/ast"
+
+// letNames lists the names bound by let statements that run in the same
+// function as block, including those inside if branches. Bodies of nested
+// function literals belong to other functions and are skipped.
+func letNames(block *ast.BlockStatement) []string {
+	names := []string{}
+	collectBlock(block, &names)
+	return names
+}
+
+func collectBlock(block *ast.BlockStatement, names *[]string) {
+	if block == nil {
+		return
+	}
+	for _, s := range block.Statements {
+		collectStatement(s, names)
+	}
+}
+
+func collectStatement(s ast.Statement, names *[]string) {
+	switch s := s.(type) {
+	case *ast.LetStatement:
+		*names = append(*names, s.Name.Value)
+		collectExpression(s.Value, names)
+	case *ast.ReturnStatement:
+		collectExpression(s.Return, names)
+	case *ast.ThrowStatement:
+		collectExpression(s.Value, names)
+	case *ast.AssignStatement:
+		collectExpression(s.Target, names)
+		collectExpression(s.Value, names)
+	case *ast.ExpressionStatement:
+		collectExpression(s.Expression, names)
+	case *ast.BlockStatement:
+		collectBlock(s, names)
+	case *ast.WhileStatement:
+		collectExpression(s.Condition, names)
+		collectBlock(s.Body, names)
+	case *ast.ForStatement:
+		if s.Key != nil {
+			*names = append(*names, s.Key.Value)
+		}
+		*names = append(*names, s.Value.Value)
+		collectExpression(s.Iterable, names)
+		collectBlock(s.Body, names)
+	}
+}
+
+func collectExpression(e ast.Expression, names *[]string) {
+	switch e := e.(type) {
+	case *ast.IfExpression:
+		collectExpression(e.Condition, names)
+		collectBlock(e.Consequence, names)
+		collectBlock(e.Alternative, names)
+	case *ast.TryExpression:
+		collectBlock(e.Body, names)
+		if e.CatchParam != nil {
+			*names = append(*names, e.CatchParam.Value)
+		}
+		collectBlock(e.Catch, names)
+		collectBlock(e.Finally, names)
+	case *ast.PrefixExpression:
+		collectExpression(e.Right, names)
+	case *ast.InfixExpression:
+		collectExpression(e.Left, names)
+		collectExpression(e.Right, names)
+	case *ast.CallExpression:
+		collectExpression(e.Function, names)
+		for _, a := range e.Arguments {
+			collectExpression(a, names)
+		}
+	case *ast.ArrayLiteral:
+		for _, el := range e.Elements {
+			collectExpression(el, names)
+		}
+	case *ast.HashLiteral:
+		for _, k := range e.Keys {
+			collectExpression(k, names)
+			collectExpression(e.Pairs[k], names)
+		}
+	case *ast.IndexExpression:
+		collectExpression(e.Left, names)
+		collectExpression(e.Index, names)
+	case *ast.MemberExpression:
+		collectExpression(e.Left, names)
+	case *ast.SliceExpression:
+		collectExpression(e.Left, names)
+		collectExpression(e.Low, names)
+		collectExpression(e.High, names)
+	}
+}
@@ -0,0 +1,163 @@
+package compiler
+
+type SymbolScope string
+
+const (
+	GlobalScope SymbolScope = "GLOBAL"
+	LocalScope  SymbolScope = "LOCAL"
+)
+
+// Symbol is a resolved name. For locals Depth tells how many function
+// scopes up from the current one the variable lives; 0 is the current call.
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+	Depth int
+}
+
+// SymbolTable maps names to slots. The outermost table holds globals, every
+// function literal gets its own enclosed table for its locals.
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	store map[string]Symbol
+	names []string
+	// pending holds names that a let further down this function defines.
+	pending map[string]bool
+	// scoped holds the slots of names that were only visible in a block.
+	scoped map[int]bool
+}
+
+// BlockScope is what OpenBlock saves for CloseBlock: the symbols that the
+// block's names shadow, with an Index of -1 for names that were undefined.
+type BlockScope map[string]Symbol
+
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{store: make(map[string]Symbol)}
+}
+
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	return s
+}
+
+// Define returns the slot for name in this table, allocating one the first
+// time the name is seen. Redefining a name reuses its slot.
+func (s *SymbolTable) Define(name string) Symbol {
+	if symbol, ok := s.store[name]; ok {
+		return symbol
+	}
+	symbol := Symbol{Name: name, Index: len(s.names)}
+	if s.Outer == nil {
+		symbol.Scope = GlobalScope
+	} else {
+		symbol.Scope = LocalScope
+	}
+	s.store[name] = symbol
+	s.names = append(s.names, name)
+	return symbol
+}
+
+// Declare records names that a function defines later on, so that nested
+// functions can refer to them before the let has been compiled.
+func (s *SymbolTable) Declare(names []string) {
+	if s.pending == nil {
+		s.pending = make(map[string]bool)
+	}
+	for _, name := range names {
+		s.pending[name] = true
+	}
+}
+
+// ResolveDeclared is the fallback for names Resolve doesn't know: it defines
+// name in this function if a let further down declares it.
+func (s *SymbolTable) ResolveDeclared(name string) (Symbol, bool) {
+	if s.Outer == nil || !s.pending[name] {
+		return Symbol{}, false
+	}
+	return s.Define(name), true
+}
+
+// Resolve looks name up in this table and then in the enclosing ones. An
+// enclosing function that declares name but hasn't defined it yet gets it
+// defined: the code being compiled runs when it is called, by which time
+// the let has usually run, so it must see that variable and not an outer
+// one of the same name. The current function's own declarations are left
+// to ResolveDeclared, because its code that precedes the let reads the
+// outer variable, as it does in the evaluator.
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	symbol, ok := s.store[name]
+	if ok || s.Outer == nil {
+		return symbol, ok
+	}
+	symbol, ok = s.Outer.resolveEnclosing(name)
+	if ok && symbol.Scope == LocalScope {
+		symbol.Depth++
+	}
+	return symbol, ok
+}
+
+func (s *SymbolTable) resolveEnclosing(name string) (Symbol, bool) {
+	if _, ok := s.store[name]; !ok && s.Outer != nil && s.pending[name] {
+		s.Define(name)
+	}
+	return s.Resolve(name)
+}
+
+// OpenBlock starts a block whose own names, such as a catch parameter and
+// the lets in the catch block, are visible in it only. They get new slots
+// when they are defined, shadowing names from outside the block.
+func (s *SymbolTable) OpenBlock(names []string) BlockScope {
+	saved := BlockScope{}
+	for _, name := range names {
+		if _, ok := saved[name]; ok {
+			continue
+		}
+		symbol, ok := s.store[name]
+		if !ok {
+			symbol.Index = -1
+		}
+		saved[name] = symbol
+		delete(s.store, name)
+	}
+	return saved
+}
+
+// CloseBlock ends the block OpenBlock started, bringing back the names it
+// shadowed.
+func (s *SymbolTable) CloseBlock(saved BlockScope) {
+	for name, symbol := range saved {
+		if inner, ok := s.store[name]; ok {
+			if s.scoped == nil {
+				s.scoped = make(map[int]bool)
+			}
+			s.scoped[inner.Index] = true
+		}
+		if symbol.Index < 0 {
+			delete(s.store, name)
+		} else {
+			s.store[name] = symbol
+		}
+	}
+}
+
+// Scoped returns the slots that belonged to names of closed blocks. Their
+// names are in Names, for error messages, but no longer refer to them.
+func (s *SymbolTable) Scoped() map[int]bool {
+	return s.scoped
+}
+
+// Global returns the outermost table.
+func (s *SymbolTable) Global() *SymbolTable {
+	for s.Outer != nil {
+		s = s.Outer
+	}
+	return s
+}
+
+// Names returns the defined names ordered by slot index.
+func (s *SymbolTable) Names() []string {
+	return s.names
+}
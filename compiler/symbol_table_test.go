@@ -0,0 +1,105 @@
+package compiler
+
+import "testing"
+
+func TestDefineAndResolve(t *testing.T) {
+	global := NewSymbolTable()
+	a := global.Define("a")
+	global.Define("b")
+
+	first := NewEnclosedSymbolTable(global)
+	first.Define("c")
+	second := NewEnclosedSymbolTable(first)
+	second.Define("d")
+
+	tests := []struct {
+		table    *SymbolTable
+		name     string
+		expected Symbol
+	}{
+		{global, "a", Symbol{Name: "a", Scope: GlobalScope, Index: 0}},
+		{global, "b", Symbol{Name: "b", Scope: GlobalScope, Index: 1}},
+		{first, "a", Symbol{Name: "a", Scope: GlobalScope, Index: 0}},
+		{first, "c", Symbol{Name: "c", Scope: LocalScope, Index: 0}},
+		{second, "c", Symbol{Name: "c", Scope: LocalScope, Index: 0, Depth: 1}},
+		{second, "d", Symbol{Name: "d", Scope: LocalScope, Index: 0}},
+	}
+	for _, tt := range tests {
+		symbol, ok := tt.table.Resolve(tt.name)
+		if !ok {
+			t.Errorf("name %s not resolvable", tt.name)
+			continue
+		}
+		if symbol != tt.expected {
+			t.Errorf("expected %s to resolve to %+v, got=%+v", tt.name, tt.expected, symbol)
+		}
+	}
+
+	if again := global.Define("a"); again != a {
+		t.Errorf("redefining a changed its symbol. want=%+v, got=%+v", a, again)
+	}
+	if _, ok := second.Resolve("e"); ok {
+		t.Errorf("name e resolved but was never defined")
+	}
+}
+
+func TestResolveDeclared(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("later")
+	outer := NewEnclosedSymbolTable(global)
+	outer.Define("x")
+	outer.Declare([]string{"later", "own"})
+	inner := NewEnclosedSymbolTable(outer)
+
+	// A name an enclosing function declares wins over the global one.
+	symbol, ok := inner.Resolve("later")
+	if !ok {
+		t.Fatalf("declared name not resolvable")
+	}
+	expected := Symbol{Name: "later", Scope: LocalScope, Index: 1, Depth: 1}
+	if symbol != expected {
+		t.Errorf("wrong symbol. want=%+v, got=%+v", expected, symbol)
+	}
+
+	// Within the declaring function, code before the let sees the outer
+	// name until the let is compiled.
+	other := NewEnclosedSymbolTable(global)
+	other.Declare([]string{"later"})
+	symbol, _ = other.Resolve("later")
+	if symbol.Scope != GlobalScope {
+		t.Errorf("own declaration hid the global. got=%+v", symbol)
+	}
+
+	symbol, ok = outer.ResolveDeclared("own")
+	expected = Symbol{Name: "own", Scope: LocalScope, Index: 2}
+	if !ok || symbol != expected {
+		t.Errorf("wrong symbol for own declaration. want=%+v, got=%+v", expected, symbol)
+	}
+	if _, ok := inner.ResolveDeclared("unknown"); ok {
+		t.Errorf("undeclared name resolved")
+	}
+}
+
+func TestBlockScope(t *testing.T) {
+	global := NewSymbolTable()
+	e := global.Define("e")
+
+	block := global.OpenBlock([]string{"e", "y", "e"})
+	inner := global.Define("e")
+	if inner.Index == e.Index {
+		t.Errorf("block name reused the outer slot %d", e.Index)
+	}
+	y := global.Define("y")
+	global.CloseBlock(block)
+
+	if symbol, ok := global.Resolve("e"); !ok || symbol != e {
+		t.Errorf("outer name not restored. want=%+v, got=%+v", e, symbol)
+	}
+	if _, ok := global.Resolve("y"); ok {
+		t.Errorf("block name still visible")
+	}
+	scoped := global.Scoped()
+	if !scoped[inner.Index] || !scoped[y.Index] || scoped[e.Index] {
+		t.Errorf("wrong scoped slots %v", scoped)
+	}
+}
@@ -0,0 +1,137 @@
+// Package enginetest is the test suite shared by the engines that run
+// Buggy programs: the evaluator and the virtual machine. Every test runs
+// the same programs and expects the same results from both, so the engines
+// cannot drift apart.
+package enginetest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/smiksha1701/buggy/evaluator"
+	"github.com/smiksha1701/buggy/object"
+)
+
+// Engine runs the program input under the budget b, which may be nil, and
+// returns the value of the program or the error that stopped it.
+type Engine func(input string, b *object.Budget) object.Object
+
+func (engine Engine) eval(input string) object.Object {
+	return engine(input, nil)
+}
+
+// Run runs the suite against engine, each test as a subtest.
+func Run(t *testing.T, engine Engine) {
+	for _, tt := range suite {
+		test := tt.test
+		t.Run(tt.name, func(t *testing.T) { test(t, engine) })
+	}
+}
+
+// writeModules writes files, keyed by their slash-separated paths, into a
+// temporary directory and returns the directory.
+func writeModules(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, source := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func testNullObject(t *testing.T, evaluated object.Object) bool {
+	if evaluated != evaluator.NULL {
+		t.Errorf("object is not NULL, got =%T (%+v)", evaluated, evaluated)
+		return false
+	}
+	return true
+}
+
+func testIntegerObject(t *testing.T, obj object.Object, tt int64) bool {
+	result, ok := obj.(*object.Integer)
+	if !ok {
+		t.Errorf("object is not integer. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != tt {
+		t.Errorf("object has wrong value. got=%d expected=%d ", result.Value, tt)
+		return false
+	}
+	return true
+}
+
+func testFloatObject(t *testing.T, obj object.Object, tt float64) bool {
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("object is not float. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != tt {
+		t.Errorf("object has wrong value. got=%g expected=%g ", result.Value, tt)
+		return false
+	}
+	return true
+}
+
+func testBooleanObject(t *testing.T, obj object.Object, tt bool, testIdx int) bool {
+	result, ok := obj.(*object.Boolean)
+	if !ok {
+		t.Errorf("TEST %d object is not boolean. got=%T (%+v)", testIdx+1, obj, obj)
+		return false
+	}
+	if result.Value != tt {
+		t.Errorf("TEST %d object has wrong value. got=%t expected=%t ", testIdx+1, result.Value, tt)
+		return false
+	}
+	return true
+}
+
+// ExpectObject checks evaluated against expected, which is an int,
+// float64, bool or nil for the matching object, or a string that is the
+// value of a string or the message of an error.
+func ExpectObject(t *testing.T, input string, evaluated object.Object, expected interface{}) {
+	t.Helper()
+	switch expected := expected.(type) {
+	case int:
+		testIntegerObject(t, evaluated, int64(expected))
+	case float64:
+		testFloatObject(t, evaluated, expected)
+	case bool:
+		testBooleanObject(t, evaluated, expected, 0)
+	case nil:
+		testNullObject(t, evaluated)
+	case string:
+		switch obj := evaluated.(type) {
+		case *object.String:
+			if obj.Value != expected {
+				t.Errorf("wrong string for %s. expected=%q, got=%q", input, expected, obj.Value)
+			}
+		case *object.Error:
+			if obj.Message != expected {
+				t.Errorf("wrong error message for %s. expected=%q, got=%q", input, expected, obj.Message)
+			}
+		default:
+			t.Errorf("expected %q for %s. got=%T (%+v)", expected, input, evaluated, evaluated)
+		}
+	}
+}
+
+// ExpectKind checks that evaluated is an error of the given kind.
+func ExpectKind(t *testing.T, evaluated object.Object, kind object.ErrorKind) {
+	t.Helper()
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+		return
+	}
+	if errObj.Kind != kind {
+		t.Errorf("wrong error kind. expected=%s, got=%s (%s)", kind, errObj.Kind, errObj.Message)
+	}
+}
@@ -0,0 +1,1289 @@
+package enginetest
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smiksha1701/buggy/evaluator"
+	"github.com/smiksha1701/buggy/object"
+)
+
+// suite lists the tests Run runs, by name.
+var suite = []struct {
+	name string
+	test func(t *testing.T, engine Engine)
+}{
+	{"EvalIntegerExpression", testEvalIntegerExpression},
+	{"EvalFloatExpression", testEvalFloatExpression},
+	{"FloatComparison", testFloatComparison},
+	{"FloatInspect", testFloatInspect},
+	{"StringLiteral", testStringLiteral},
+	{"IfElseStatement", testIfElseStatement},
+	{"EvalBooleanExpression", testEvalBooleanExpression},
+	{"BangOperator", testBangOperator},
+	{"ReturnStatements", testReturnStatements},
+	{"LetStatements", testLetStatements},
+	{"ErrorHandling", testErrorHandling},
+	{"ErrorPositions", testErrorPositions},
+	{"ArrayLiterals", testArrayLiterals},
+	{"HashIndexExpression", testHashIndexExpression},
+	{"HashLiterals", testHashLiterals},
+	{"ArrayIndexExpression", testArrayIndexExpression},
+	{"BuiltinFunction", testBuiltinFunction},
+	{"FunctionApplication", testFunctionApplication},
+	{"Closures", testClosures},
+	{"RecursiveFunctions", testRecursiveFunctions},
+	{"TailCalls", testTailCalls},
+	{"TailCallsWithinCallDepthLimit", testTailCallsWithinCallDepthLimit},
+	{"TailCallStacks", testTailCallStacks},
+	{"WhileLoops", testWhileLoops},
+	{"ForLoops", testForLoops},
+	{"Assignments", testAssignments},
+	{"SelfContaining", testSelfContaining},
+	{"LogicalOperators", testLogicalOperators},
+	{"ComparisonAndModulo", testComparisonAndModulo},
+	{"UnicodeStrings", testUnicodeStrings},
+	{"StackTraces", testStackTraces},
+	{"TryCatch", testTryCatch},
+	{"Limits", testLimits},
+	{"JSON", testJSON},
+	{"StringBuiltins", testStringBuiltins},
+	{"CollectionBuiltins", testCollectionBuiltins},
+	{"CallbackErrors", testCallbackErrors},
+	{"HashBuiltins", testHashBuiltins},
+	{"Modules", testModules},
+}
+
+func testEvalIntegerExpression(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"5", 5},
+		{"10", 10},
+		{"-5", -5},
+		{"5+10", 15},
+		{"-5+10", 5},
+		{"-2*5-2", -12},
+		{"2*(3*3)", 18},
+		{"20+-5", 15},
+	}
+	for _, tt := range tests {
+		evaluated := engine.eval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func testEvalFloatExpression(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.5", 3.5},
+		{"-2.5", -2.5},
+		{"1.5 + 1.5", 3},
+		{"1 + 0.5", 1.5},
+		{"0.5 * 4", 2},
+		{"7 / 2.0", 3.5},
+		{"1e3 - 1", 999},
+		{"(1 + 2 + 3) / 4.0", 1.5},
+	}
+	for _, tt := range tests {
+		evaluated := engine.eval(tt.input)
+		testFloatObject(t, evaluated, tt.expected)
+	}
+}
+
+func testFloatComparison(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"1.5 < 2", true},
+		{"2 > 1.5", true},
+		{"1.0 == 1", true},
+		{"0.1 + 0.2 != 0.3", true},
+		{"2.5 < 2.5", false},
+	}
+	for idx, tt := range tests {
+		evaluated := engine.eval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected, idx)
+	}
+}
+
+func testFloatInspect(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"3.0", "3.0"},
+		{"1.25", "1.25"},
+		{"2 * 1.5", "3.0"},
+		{"1e21", "1e+21"},
+	}
+	for _, tt := range tests {
+		evaluated := engine.eval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong Inspect for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func testStringLiteral(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"foobar"`, "foobar"},
+	}
+	for _, tt := range tests {
+		evaluated := engine.eval(tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Fatalf("object is not String. got=%q", evaluated)
+		}
+		if str.Value != "foobar" {
+			t.Errorf("String has wrong value. got=%q", str.Value)
+		}
+	}
+}
+
+func testIfElseStatement(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"if (true) { 10 }", 10},
+		{"if (true) { 10 }", 10},
+		{"if (1) { 10 }", 10},
+		{"if (1 < 2) { 10 }", 10},
+		{"if (1 > 2) { 10 }", nil},
+		{"if (1 > 2) { 10 } else { 20 }", 20},
+		{"if (1 < 2) { 10 } else { 20 }", 10},
+	}
+	for _, tt := range tests {
+		evaluated := engine.eval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func testEvalBooleanExpression(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"true", true},
+		{"false", false},
+		{"1>2", false},
+		{"1<2", true},
+		{"1<1", false},
+		{"1>1", false},
+		{"1!=1", false},
+		{"1==1", true},
+		{"1==2", false},
+		{"1!=2", true},
+		{"true==true", true},
+		{"false==false", true},
+		{"true!=false", true},
+		{"false!=true", true},
+		{"(1 < 2) == true", true},
+		{"(1 < 2) == false", false},
+		{"(1 > 2) == true", false},
+		{"(1 > 2) == false", true},
+	}
+	for idx, tt := range tests {
+		evaluated := engine.eval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected, idx)
+	}
+}
+
+func testBangOperator(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"!true", false},
+		{"!false", true},
+		{"!5", false},
+		{"!!false", false},
+		{"!!true", true},
+		{"!!false", false},
+		{"!!5", true},
+	}
+	for idx, tt := range tests {
+		evaluated := engine.eval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected, idx)
+	}
+}
+
+func testReturnStatements(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"return 10;", 10},
+		{"return 10; 9;", 10},
+		{"return 2 * 5; 9;", 10},
+		{"9; return 2*5; 9;", 10},
+	}
+	for _, tt := range tests {
+		evaluated := engine.eval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func testLetStatements(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let a = 5; a;", 5},
+		{"let a = 5 * 5; a;", 25},
+		{"let a = 5; let b = a; b;", 5},
+		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+	}
+	for _, tt := range tests {
+		evaluated := engine.eval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func testErrorHandling(t *testing.T, engine Engine) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"5 + true;", "type mismatch: INTEGER + BOOLEAN"},
+		{"5 + true; 5;", "type mismatch: INTEGER + BOOLEAN"},
+		{"-true;", "unknown operator: -BOOLEAN"},
+		{"true-true;", "unknown operator: BOOLEAN - BOOLEAN"},
+		{"foobar;", "identifier not found: foobar"},
+		{"5; false + true; 5;", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"if (10 > 1) { true + false; }", "unknown operator: BOOLEAN + BOOLEAN"},
+		{
+			`if (10 > 1) {
+				if (10 > 1) {
+					return true + false;
+				}
+
+				return 1;
+			}
+			`, "unknown operator: BOOLEAN + BOOLEAN"},
+	}
+	for _, tt := range tests {
+		evaluated := engine.eval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v", evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func testErrorPositions(t *testing.T, engine Engine) {
+	tests := []struct {
+		input       string
+		expectedPos string
+	}{
+		{"5 + true;", "1:3"},
+		{"let x = 1;\n  foobar;", "2:3"},
+		{"let f = fn(x) {\n  x * -true\n};\nf(1);", "2:7"},
+		{`len(1)`, "1:4"},
+	}
+	for _, tt := range tests {
+		evaluated := engine.eval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+		if errObj.Pos.String() != tt.expectedPos {
+			t.Errorf("wrong error position for %q. expected=%q, got=%q", tt.input, tt.expectedPos, errObj.Pos)
+		}
+	}
+}
+
+func testArrayLiterals(t *testing.T, engine Engine) {
+	input := "[1, 2 * 2, 3 + 3]"
+	evaluated := engine.eval(input)
+	result, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Errorf("no Array object returned. got=%T(%+v", evaluated, evaluated)
+	}
+	if len(result.Elements) != 3 {
+		t.Errorf("Array has wrong number of elements. Elements=%+v", result.Elements)
+	}
+
+	testIntegerObject(t, result.Elements[0], 1)
+	testIntegerObject(t, result.Elements[1], 4)
+	testIntegerObject(t, result.Elements[2], 6)
+}
+
+func testHashIndexExpression(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{
+			`{"foo": 5}["foo"]`,
+			5,
+		},
+		{
+			`{"foo": 5}["bar"]`,
+			nil,
+		},
+		{
+			`let key = "foo"; {"foo": 5}[key]`,
+			5,
+		},
+		{
+			`{}["foo"]`,
+			nil,
+		},
+		{
+			`{5: 5}[5]`,
+			5,
+		},
+		{
+			`{true: 5}[true]`,
+			5,
+		},
+		{
+			`{false: 5}[false]`,
+			5,
+		},
+		{
+			`{1.5: 5}[1.5]`,
+			5,
+		},
+		{
+			`{1: 5}[1.0]`,
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		evaluated := engine.eval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func testHashLiterals(t *testing.T, engine Engine) {
+	input := `let two = "two";
+	{
+		"one": 10 - 9,
+		two: 1 + 1,
+		"thr" + "ee": 6 / 2,
+		4: 4,
+		true: 5,
+		false: 6
+	}
+	`
+	evaluated := engine.eval(input)
+	result, ok := evaluated.(*object.Hash)
+	if !ok {
+		t.Fatalf("no Array object returned. got=%T(%+v", evaluated, evaluated)
+	}
+	expected := map[object.HashKey]int64{
+		(&object.String{Value: "one"}).HashKey():   1,
+		(&object.String{Value: "two"}).HashKey():   2,
+		(&object.String{Value: "three"}).HashKey(): 3,
+		(&object.Integer{Value: 4}).HashKey():      4,
+		evaluator.TRUE.HashKey():                   5,
+		evaluator.FALSE.HashKey():                  6,
+	}
+	if len(result.Pairs) != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
+	}
+	for expectedKey, expexpectedValue := range expected {
+		pair, ok := result.Pairs[expectedKey]
+		if !ok {
+			t.Errorf("no pair for given key in Pairs")
+		}
+		testIntegerObject(t, pair.Value, expexpectedValue)
+	}
+}
+
+func testArrayIndexExpression(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2, 3][0]", 1},
+		{"[1, 2, 3][1]", 2},
+		{"[1, 2, 3][2]", 3},
+		{"let i = 0; [1][i]", 1},
+		{"[1, 2, 3][1 + 1]", 3},
+		{"let myArray = [1, 2, 3]; myArray[2];", 3},
+	}
+	for _, tt := range tests {
+		evaluated := engine.eval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func testBuiltinFunction(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`len("")`, 0},
+		{`len("four")`, 4},
+		{`len("hello world")`, 11},
+		{`len(1)`, "argument to `len` not supported, got INTEGER"},
+		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
+	}
+	for _, tt := range tests {
+		evaluated := engine.eval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func testFunctionApplication(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let identity = fn(x) { x; }; identity(5);", 5},
+		{"let identity = fn(x) { return x; }; identity(5);", 5},
+		{"let double = fn(x) { x * 2; }; double(5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5, 5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
+		{"fn(x) { x; }(5)", 5},
+	}
+	for _, tt := range tests {
+		testIntegerObject(t, engine.eval(tt.input), tt.expected)
+	}
+}
+
+func testClosures(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let newAdder = fn(a) { fn(b) { a + b } }; newAdder(2)(3);", 5},
+		{"let f = fn(a) { fn(b) { fn(c) { a + b + c } } }; f(1)(2)(3);", 6},
+		{"let f = fn() { let g = fn() { h * 2 }; let h = 21; g() }; f();", 42},
+		{"let f = fn() { let x = 1; let x = x + 1; x }; f();", 2},
+		{"let x = 10; let f = fn() { let x = x + 1; x }; f() + x;", 21},
+		{"let x = 1; let f = fn() { let g = fn() { x }; let x = 2; g() }; f();", 2},
+		{"let x = 1; let f = fn() { let g = fn() { fn() { x } }; let x = 3; g()() }; f();", 3},
+	}
+	for _, tt := range tests {
+		testIntegerObject(t, engine.eval(tt.input), tt.expected)
+	}
+}
+
+func testRecursiveFunctions(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(15);", 610},
+		{"let wrapper = fn() { let countDown = fn(x) { if (x == 0) { 0 } else { countDown(x - 1) } }; countDown(1) }; wrapper();", 0},
+		{"let isEven = fn(n) { if (n == 0) { true } else { isOdd(n - 1) } }; let isOdd = fn(n) { if (n == 0) { false } else { isEven(n - 1) } }; if (isEven(10)) { 1 } else { 0 }", 1},
+		{"let sum = fn(n) { if (n == 0) { 0 } else { n + sum(n - 1) } }; sum(20000);", 200010000},
+	}
+	for _, tt := range tests {
+		testIntegerObject(t, engine.eval(tt.input), tt.expected)
+	}
+}
+
+func testTailCalls(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let loop = fn(n) { if (n == 0) { 0 } else { loop(n - 1) } }; loop(1000000)", 0},
+		{"let count = fn(n, acc) { if (n == 0) { return acc; } return count(n - 1, acc + 1); }; count(1000000, 0)", 1000000},
+		{"let count = fn(n, acc) { while (true) { return if (n == 0) { acc } else { count(n - 1, acc + 1) } } }; count(1000000, 0)", 1000000},
+		{"let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } }; let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } }; even(1000001)", false},
+		{"let double = fn(x) { x * 2 }; return double(21);", 42},
+		{"let f = fn() { len([1, 2]) }; f()", 2},
+		{`let g = fn() { throw "x" }; let f = fn() { try { return g() } catch (e) { "caught" } }; f()`, "caught"},
+		{`let log = []; let g = fn() { log = push(log, "g") }; let f = fn() { try { return g() } finally { log = push(log, "finally") } }; f(); join(log, ", ")`, "g, finally"},
+		{"let f = fn() { g() }; let g = fn(a) { a }; f()", "wrong number of arguments: want=1, got=0"},
+	}
+	for _, tt := range tests {
+		ExpectObject(t, tt.input, engine.eval(tt.input), tt.expected)
+	}
+}
+
+func testTailCallsWithinCallDepthLimit(t *testing.T, engine Engine) {
+	input := "let loop = fn(n) { if (n == 0) { 0 } else { loop(n - 1) } }; loop(10000)"
+	ExpectObject(t, input, engine(input, object.NewBudget(nil, object.Limits{MaxCallDepth: 10})), 0)
+}
+
+func testTailCallStacks(t *testing.T, engine Engine) {
+	// A long chain of tail calls keeps its ends in the stack and counts the
+	// frames between them.
+	input := `let f = fn(n) { if (n == 0) { throw "x" } else { f(n - 1) } };
+		try { f(1000) } catch (e) { [len(e["stack"]), e["stack"][0], e["stack"][201], e["stack"][301]] }`
+	evaluated := engine.eval(input)
+	expected := "[302, f (1:31), (700 tail calls), f (1:51)]"
+	if evaluated.Inspect() != expected {
+		t.Errorf("wrong stack. expected=%q, got=%q", expected, evaluated.Inspect())
+	}
+}
+
+func testWhileLoops(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let i = 0; while (i < 10) { let i = i + 1; }; i;", 10},
+		{"let i = 0; while (true) { let i = i + 1; if (i > 4) { break; } }; i;", 5},
+		{"let i = 0; let n = 0; while (i < 5) { let i = i + 1; if (i == 2) { continue; } let n = n + i; }; n;", 13},
+		{"let f = fn() { let i = 0; while (true) { let i = i + 1; if (i == 3) { return i * 10; } } }; f();", 30},
+		{"let f = fn() { while (false) { } }; f();", nil},
+	}
+	for _, tt := range tests {
+		evaluated := engine.eval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func testForLoops(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let sum = 0; for (x in [1, 2, 3]) { let sum = sum + x; }; sum;", 6},
+		{"let sum = 0; for (i, x in [10, 20, 30]) { let sum = sum + i * x; }; sum;", 80},
+		{`let s = ""; for (c in "abc") { let s = c + s; }; s;`, "cba"},
+		{`let n = 0; for (k in {"a": 1, "b": 2}) { let n = n + len(k); }; n;`, 2},
+		{`let n = 0; for (k, v in {"a": 1, "b": 2}) { let n = n + v; }; n;`, 3},
+		{"let sum = 0; for (x in [1, 2, 3, 4]) { if (x == 2) { continue; } if (x == 4) { break; } let sum = sum + x; }; sum;", 4},
+		{"let total = 0; for (row in [[1, 2], [3]]) { for (x in row) { let total = total + x; } }; total;", 6},
+		{"let f = fn(xs) { for (x in xs) { if (x > 1) { return x; } } }; f([1, 5, 7]);", 5},
+		{"for (x in []) { x }; 1;", 1},
+		{"for (x in 5) { x }", "cannot iterate over INTEGER"},
+	}
+	for _, tt := range tests {
+		evaluated := engine.eval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				if errObj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+				}
+				continue
+			}
+			str, ok := evaluated.(*object.String)
+			if !ok || str.Value != expected {
+				t.Errorf("expected %q. got=%T (%+v)", expected, evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func testAssignments(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let x = 1; x = 2; x;", 2},
+		{"let x = 1; x += 4; x;", 5},
+		{"let x = 10; x -= 4; x *= 3; x /= 2; x;", 9},
+		{`let s = "a"; s += "b"; s;`, "ab"},
+		{"let x = 1; let f = fn() { x = x + 1; }; f(); f(); x;", 3},
+		{"let x = 1; let f = fn() { let x = 5; x = 6; }; f(); x;", 1},
+		{"let counter = fn() { let n = 0; fn() { n += 1; n } }; let c = counter(); c(); c(); c();", 3},
+		{"let i = 0; let sum = 0; while (i < 5) { i += 1; sum += i; }; sum;", 15},
+		{"let a = [1, 2, 3]; a[0] = 10; a[-1] += 5; a[0] + a[2];", 18},
+		{"let a = [1, 2]; let b = a; b[1] = 7; a[1];", 7},
+		{`let h = {"a": 1}; h["a"] += 1; h["b"] = 5; h["a"] * h["b"];`, 10},
+		{"let m = [[1], [2]]; m[1][0] = 9; m[1][0];", 9},
+		{"x = 1;", "cannot assign to undeclared identifier: x"},
+		{"let f = fn() { y = 1; }; f();", "cannot assign to undeclared identifier: y"},
+		{"len = 1;", "cannot assign to undeclared identifier: len"},
+		{"x += 1;", "identifier not found: x"},
+		{"let a = [1]; a[1] = 2;", "index out of range: 1"},
+		{`let s = "ab"; s[0] = "c";`, "index assignment not supported: STRING"},
+		{`let h = {}; h[fn(x) { x }] = 1;`, "unusable as hash key: FN"},
+		{`let x = 1; x += "a";`, "type mismatch: INTEGER + STRING"},
+	}
+	for _, tt := range tests {
+		evaluated := engine.eval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				if errObj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+				}
+				continue
+			}
+			str, ok := evaluated.(*object.String)
+			if !ok || str.Value != expected {
+				t.Errorf("expected %q. got=%T (%+v)", expected, evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func testSelfContaining(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let a = [1]; a[0] = a; a", "[[...]]"},
+		{"let a = [1, 2]; a[1] = a; [a, a]", "[[1, [...]], [1, [...]]]"},
+		{`let h = {"a": 1}; h["self"] = h; h`, "{a: 1, self: {...}}"},
+		{`let a = [0]; let h = {"a": a}; a[0] = h; a`, "[{a: [...]}]"},
+	}
+	for _, tt := range tests {
+		evaluated := engine.eval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong inspect for %q. expected=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func testLogicalOperators(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"true && true", true},
+		{"true && false", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 && \"a\"", true},
+		{"0 || false", true},
+		{"1 < 2 && 2 < 3", true},
+		{"1 > 2 || 2 > 3", false},
+		{"false && undefined_name", false},
+		{"true || undefined_name", true},
+		{"let n = 0; let inc = fn() { n += 1; true }; false && inc(); true || inc(); n == 0", true},
+		{"let n = 0; let inc = fn() { n += 1; true }; true && inc(); false || inc(); n == 2", true},
+	}
+	for i, tt := range tests {
+		evaluated := engine.eval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected, i)
+	}
+}
+
+func testComparisonAndModulo(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"1 <= 2", true},
+		{"2 <= 2", true},
+		{"3 <= 2", false},
+		{"2 >= 3", false},
+		{"3 >= 3", true},
+		{"1.5 <= 1", false},
+		{"2 >= 1.5", true},
+		{`"abc" <= "abd"`, true},
+		{`"b" >= "abc"`, true},
+		{`"a" < "b"`, true},
+		{`"a" == "a"`, true},
+		{`"a" != "a"`, false},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"10 % 5", 0},
+		{"let x = 17; x %= 5; x", 2},
+		{"7 % 0", "division by zero"},
+		{"7 / 0", "division by zero"},
+		{`"a" % "b"`, "unknown operator: STRING%STRING"},
+	}
+	for i, tt := range tests {
+		evaluated := engine.eval(tt.input)
+		switch expected := tt.expected.(type) {
+		case bool:
+			testBooleanObject(t, evaluated, expected, i)
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func testUnicodeStrings(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`len("привет")`, 6},
+		{`byte_len("привет")`, 12},
+		{`len("🐛")`, 1},
+		{`byte_len("🐛")`, 4},
+		{`"привет"[0]`, "п"},
+		{`"привет"[-1]`, "т"},
+		{`"héllo"[1]`, "é"},
+		{`"abc"[3]`, nil},
+		{`let привет = "мир"; привет`, "мир"},
+		{`let s = ""; for (i, c in "añb") { s = c * (i + 1) + s }; s`, "bbbñña"},
+		{`let n = 0; for (c in "日本語") { n += 1 }; n`, 3},
+		{`"привет"[1:4]`, "рив"},
+		{`"привет"[3:]`, "вет"},
+		{`"привет"[:-3]`, "при"},
+		{`"abc"[5:10]`, ""},
+		{`"abc"[2:1]`, ""},
+		{`[1, 2, 3, 4][1:3]`, []int{2, 3}},
+		{`[1, 2, 3][:]`, []int{1, 2, 3}},
+		{`[1, 2, 3][-2:]`, []int{2, 3}},
+		{`let a = [1, 2]; let b = a[:]; b[0] = 9; a[0]`, 1},
+		{`"abc"["a":]`, "slice bound must be INTEGER, got STRING"},
+		{`5[1:]`, "slice operator not supported: INTEGER"},
+		{`byte_len(1)`, "argument to `byte_len` not supported, got INTEGER"},
+	}
+	for _, tt := range tests {
+		evaluated := engine.eval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case []int:
+			arr, ok := evaluated.(*object.Array)
+			if !ok {
+				t.Errorf("obj not Array. got=%T (%+v)", evaluated, evaluated)
+				continue
+			}
+			if len(arr.Elements) != len(expected) {
+				t.Errorf("wrong num of elements. want=%d, got=%d", len(expected), len(arr.Elements))
+				continue
+			}
+			for i, el := range expected {
+				testIntegerObject(t, arr.Elements[i], int64(el))
+			}
+		case string:
+			switch obj := evaluated.(type) {
+			case *object.String:
+				if obj.Value != expected {
+					t.Errorf("wrong string for %s. expected=%q, got=%q", tt.input, expected, obj.Value)
+				}
+			case *object.Error:
+				if obj.Message != expected {
+					t.Errorf("wrong error message. expected=%q, got=%q", expected, obj.Message)
+				}
+			default:
+				t.Errorf("expected %q for %s. got=%T (%+v)", expected, tt.input, evaluated, evaluated)
+			}
+		case nil:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func testStackTraces(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"let x = 1;\nfoobar;",
+			"NameError: identifier not found: foobar\n" +
+				"    at <main> (2:1)\n",
+		},
+		{
+			"let add = fn(a, b) {\n  a + b\n};\nlet twice = fn(x) { add(x, true) };\n" +
+				"let apply = fn(f, v) { f(v) };\napply(fn(y) { twice(y) }, 1);",
+			"TypeError: type mismatch: INTEGER + BOOLEAN\n" +
+				"    at add (2:5)\n" +
+				"    at twice (4:24)\n" +
+				"    at <anonymous> (6:20)\n" +
+				"    at apply (5:25)\n" +
+				"    at <main> (6:6)\n",
+		},
+		{
+			"let add = fn(a, b) {\n  a + b\n};\nlet twice = fn(x) { 2 * add(x, true) };\n" +
+				"let apply = fn(f, v) { [f(v)] };\napply(fn(y) { -twice(y) }, 1);",
+			"TypeError: type mismatch: INTEGER + BOOLEAN\n" +
+				"    at add (2:5)\n" +
+				"    at twice (4:28)\n" +
+				"    at <anonymous> (6:21)\n" +
+				"    at apply (5:26)\n" +
+				"    at <main> (6:6)\n",
+		},
+		{
+			"let f = fn(a, b) { a };\nlet g = fn() { f(1) };\ng();",
+			"ArgumentError: wrong number of arguments: want=2, got=1\n" +
+				"    at g (2:17)\n" +
+				"    at <main> (3:2)\n",
+		},
+		{
+			"let f = fn(xs) { len(xs) + xs[5] / 0 };\nf([1]);",
+			"TypeError: type mismatch: NULL / INTEGER\n" +
+				"    at f (1:34)\n" +
+				"    at <main> (2:2)\n",
+		},
+		{
+			"let f = fn(n) { 10 / n };\nf(0);",
+			"ZeroDivisionError: division by zero\n" +
+				"    at f (1:20)\n" +
+				"    at <main> (2:2)\n",
+		},
+		{
+			"let f = fn() { [1][3] = 0 };\nf();",
+			"IndexError: index out of range: 3\n" +
+				"    at f (1:23)\n" +
+				"    at <main> (2:2)\n",
+		},
+		{
+			"let check = fn(r) {\n  if (r < 0) { throw \"negative\" }\n};\n" +
+				"let f = fn() { try { check(-1) } finally { 0 } };\nf();",
+			"Error: negative\n" +
+				"    at check (2:16)\n" +
+				"    at f (4:27)\n" +
+				"    at <main> (5:2)\n",
+		},
+		{
+			"let check = fn(r) {\n  if (r < 0) { throw \"negative\" }\n};\n" +
+				"let f = fn() { try { check(-1) } finally { 0 } };\nf();",
+			"Error: negative\n" +
+				"    at check (2:16)\n" +
+				"    at f (4:27)\n" +
+				"    at <main> (5:2)\n",
+		},
+	}
+	for _, tt := range tests {
+		evaluated := engine.eval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+		if errObj.Traceback() != tt.expected {
+			t.Errorf("wrong traceback for %q.\nexpected=\n%s\ngot=\n%s", tt.input, tt.expected, errObj.Traceback())
+		}
+	}
+}
+
+func testTryCatch(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"try { 1 } catch (e) { 2 }", 1},
+		{`try { throw "bad record" } catch (e) { e["message"] }`, "bad record"},
+		{`try { throw "bad" } catch (e) { e["kind"] }`, "Error"},
+		{`try { 1 / 0 } catch (e) { e["kind"] }`, "ZeroDivisionError"},
+		{`try { len(1) } catch (e) { e["kind"] }`, "TypeError"},
+		{`try { throw {"kind": "ValueError", "message": "m", "row": 3} } catch (e) { e["kind"] + ": " + e["message"] }`, "ValueError: m"},
+		{`try { throw {"kind": "ValueError", "row": 3} } catch (e) { e["row"] }`, 3},
+		{`try { throw 42 } catch (e) { e["value"] }`, 42},
+		{`let f = fn() { throw "x" }; let g = fn() { f() }; try { g() } catch (e) { len(e["stack"]) }`, 2},
+		{`let f = fn() { throw "x" }; try { f() } catch (e) { e["stack"][0] }`, "f (1:16)"},
+		{`let f = fn(r) { try { r / 0 } catch (e) { -1 } }; f(1) + f(2)`, -2},
+		{"1 + try { throw 1 } catch (e) { 2 }", 3},
+		{"let n = 0; try { n = 1 } finally { n += 10 }; n", 11},
+		{"try { 1 } finally { 2 }", 1},
+		{`let n = 0; try { try { throw "x" } finally { n = 5 } } catch (e) { n + 1 }`, 6},
+		{"let f = fn() { try { return 1 } finally { return 2 } }; f()", 2},
+		{"let n = 0; let f = fn() { try { return 1 } finally { n = 5 } }; f() + n", 6},
+		{`let f = fn() { try { throw "a" } catch (e) { throw e["message"] + "b" } }; try { f() } catch (e) { e["message"] }`, "ab"},
+		{`let n = 0; try { try { throw "a" } catch (e) { throw "b" } finally { n = 1 } } catch (e) { if (n == 1) { e["message"] } }`, "b"},
+		{"let n = 0; for (x in [1, 2, 3]) { try { if (x == 2) { break } } finally { n += x } }; n", 3},
+		{"let n = 0; while (n < 5) { try { n += 1; continue } finally { n += 10 } }; n", 11},
+		{`let e = try { throw {"kind": "K", "message": "m"} } catch (err) { try { throw err } catch (again) { again } }; e["kind"] + e["message"]`, "Km"},
+		{`throw "boom"`, "boom"},
+		{`try { throw "x" } finally { 1 }`, "x"},
+		{`try { 1 } catch (e) { 2 } finally { throw "from finally" }`, "from finally"},
+		// The catch block has a scope of its own.
+		{`let e = 1; try { throw("x") } catch (e) {}; e`, 1},
+		{`try { throw "x" } catch (e) { 1 }; e`, "identifier not found: e"},
+		{`let f = fn() { let e = 1; try { throw "x" } catch (e) { 0 }; e }; f()`, 1},
+		{`let n = 1; try { throw "x" } catch (e) { let n = 5 }; n`, 1},
+		{`let n = 1; try { throw "x" } catch (e) { n = 5 } finally { n += 1 }; n`, 6},
+		{`let g = try { throw "m" } catch (e) { fn() { e["message"] } }; g()`, "m"},
+	}
+	for _, tt := range tests {
+		evaluated := engine.eval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if errObj, ok := evaluated.(*object.Error); ok {
+				if errObj.Message != expected {
+					t.Errorf("wrong error message for %q. expected=%q, got=%q", tt.input, expected, errObj.Message)
+				}
+				continue
+			}
+			str, ok := evaluated.(*object.String)
+			if !ok || str.Value != expected {
+				t.Errorf("wrong result for %q. expected %q, got=%T (%+v)", tt.input, expected, evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func testLimits(t *testing.T, engine Engine) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		input    string
+		ctx      context.Context
+		limits   object.Limits
+		expected interface{}
+	}{
+		{"while (true) { }", nil, object.Limits{MaxSteps: 1000}, object.STEP_LIMIT_ERROR},
+		{"let f = fn(n) { 1 + f(n + 1) }; f(0)", nil, object.Limits{MaxCallDepth: 100}, object.CALL_DEPTH_ERROR},
+		{"let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(99)", nil, object.Limits{MaxCallDepth: 100}, 99},
+		{`"ab" * 1000000000`, nil, object.Limits{MaxStringLength: 1000}, object.STRING_LIMIT_ERROR},
+		{`"ab" * 9223372036854775807`, nil, object.Limits{MaxStringLength: 1000}, object.STRING_LIMIT_ERROR},
+		{`"ab" * 500`, nil, object.Limits{MaxStringLength: 1000}, 1000},
+		{`"ab" * 9223372036854775807`, nil, object.Limits{}, object.VALUE_ERROR},
+		{`"ab" * 2147483647`, nil, object.Limits{}, object.VALUE_ERROR},
+		{`let s = "ab"; s *= 9223372036854775807`, nil, object.Limits{}, object.VALUE_ERROR},
+		{`"" * 9223372036854775807`, nil, object.Limits{}, 0},
+		{`let s = "ab"; while (true) { s += s }`, nil, object.Limits{MaxStringLength: 1 << 20}, object.STRING_LIMIT_ERROR},
+		{"let xs = []; while (true) { xs = push(xs, 1) }", nil, object.Limits{MaxArrayLength: 100}, object.ARRAY_LIMIT_ERROR},
+		{"while (true) { }", nil, object.Limits{Timeout: time.Millisecond}, object.TIMEOUT_ERROR},
+		{"while (true) { }", cancelled, object.Limits{}, object.CANCELLED_ERROR},
+		{`try { "ab" * 1000 } catch (e) { len(e["kind"]) }`, nil, object.Limits{MaxStringLength: 1000}, 16},
+		{"let f = fn() { f() + 1 }; let n = try { f() } catch (e) { 1 }; n + 1", nil, object.Limits{MaxCallDepth: 10}, 2},
+		// Once a run is out of steps or time, catch and finally blocks
+		// still run, but their first step fails again, so catching the
+		// error does not let the run go on.
+		{`try { while (true) {} } catch (e) { e["kind"] }`, nil, object.Limits{MaxSteps: 1000}, object.STEP_LIMIT_ERROR},
+		{`try { while (true) {} } catch (e) { 1 } finally { 2 }`, nil, object.Limits{MaxSteps: 1000}, object.STEP_LIMIT_ERROR},
+		{"while (true) { try { while (true) {} } catch (e) {} }", nil, object.Limits{MaxSteps: 1000}, object.STEP_LIMIT_ERROR},
+		{"while (true) { try { while (true) {} } catch (e) {} }", nil, object.Limits{Timeout: time.Millisecond}, object.TIMEOUT_ERROR},
+		{"while (true) { try { while (true) {} } catch (e) {} }", cancelled, object.Limits{}, object.CANCELLED_ERROR},
+		{"while (true) { try { while (true) {} } finally { 0 } }", nil, object.Limits{MaxSteps: 1000}, object.STEP_LIMIT_ERROR},
+		{"let f = fn() { while (true) {} }; while (true) { try { map([1], fn(x) { f() }) } catch (e) {} }", nil, object.Limits{MaxSteps: 1000}, object.STEP_LIMIT_ERROR},
+	}
+	for _, tt := range tests {
+		evaluated := engine(tt.input, object.NewBudget(tt.ctx, tt.limits))
+		switch expected := tt.expected.(type) {
+		case int:
+			if str, ok := evaluated.(*object.String); ok {
+				if len(str.Value) != expected {
+					t.Errorf("wrong length for %q. expected=%d, got=%d", tt.input, expected, len(str.Value))
+				}
+				continue
+			}
+			testIntegerObject(t, evaluated, int64(expected))
+		case object.ErrorKind:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("no error object returned for %q. got=%T(%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Kind != expected {
+				t.Errorf("wrong error kind for %q. expected=%s, got=%s (%s)", tt.input, expected, errObj.Kind, errObj.Message)
+			}
+		}
+	}
+}
+
+func testJSON(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`json_parse("{\"a\": {\"b\": [1, 2]}}")["a"]["b"][1]`, 2},
+		{`json_parse(" 2.5 ")`, 2.5},
+		{`json_parse("12345678901234567890")`, 12345678901234567890.0},
+		{`json_parse("\"caf\\u00e9\"")`, "café"},
+		{`json_parse("null")`, nil},
+		{`json_parse("[true]")[0]`, true},
+		{`json_stringify(json_parse("[1, 2.0, true, null, \"a\"]"))`, `[1,2.0,true,null,"a"]`},
+		{`json_stringify({"b": 1, "a": ["x\"y", {}]})`, `{"b":1,"a":["x\"y",{}]}`},
+		{`json_stringify(json_parse("{\"z\": 1, \"y\": 2, \"x\": 3}"))`, `{"z":1,"y":2,"x":3}`},
+		{`json_stringify({"a": [1]}, 2)`, "{\n  \"a\": [\n    1\n  ]\n}"},
+		{`json_stringify([1], "\t")`, "[\n\t1\n]"},
+		{`json_stringify("<&>")`, `"<&>"`},
+		{`let inner = [1]; json_stringify([inner, inner])`, `[[1],[1]]`},
+		{`json_parse("[1,")`, "invalid JSON at offset 2: unexpected end of JSON input"},
+		{`json_parse("")`, "invalid JSON at offset 0: unexpected end of JSON input"},
+		{`json_parse("[1] 2")`, "invalid JSON at offset 5: unexpected data after the top-level value"},
+		{`json_parse("{1: 2}")`, "invalid JSON at offset 1: object member name must be a string"},
+		{`json_parse(1)`, "argument to `json_parse` not supported, got INTEGER"},
+		{`json_stringify(fn(x) { x })`, "cannot convert FN to JSON"},
+		{`json_stringify([len])`, "cannot convert BUILTIN to JSON"},
+		{`json_stringify({1: 2})`, "cannot convert hash key 1 to JSON: keys must be strings, got INTEGER"},
+		{`let a = [1]; a[0] = a; json_stringify(a)`, "cannot convert ARRAY to JSON: it contains itself"},
+		{`let h = {}; h["self"] = [h]; json_stringify(h)`, "cannot convert HASH to JSON: it contains itself"},
+		{`json_stringify(1, true)`, "indent for `json_stringify` not supported, got BOOLEAN"},
+		{`try { json_parse("nope") } catch (e) { e["kind"] }`, "ValueError"},
+	}
+	for _, tt := range tests {
+		ExpectObject(t, tt.input, engine.eval(tt.input), tt.expected)
+	}
+}
+
+func testStringBuiltins(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`json_stringify(split("a,b,,c", ","))`, `["a","b","","c"]`},
+		{`json_stringify(split("  one two\tthree "))`, `["one","two","three"]`},
+		{`json_stringify(split("añb", ""))`, `["a","ñ","b"]`},
+		{`join(["a", "b", "c"], ", ")`, "a, b, c"},
+		{`join(["a", "b"])`, "ab"},
+		{`join([])`, ""},
+		{`join(["a", 1], "")`, "element 1 of the array given to `join` must be STRING, got INTEGER"},
+		{`trim("  hi \n")`, "hi"},
+		{`trim("--hi-", "-")`, "hi"},
+		{`upper("héllo")`, "HÉLLO"},
+		{`lower("ÀB")`, "àb"},
+		{`replace("a-b-c", "-", "+")`, "a+b+c"},
+		{`replace("a-b-c", "-", "+", 1)`, "a+b-c"},
+		{`contains("buggy", "gg")`, true},
+		{`contains("buggy", "x")`, false},
+		{`starts_with("buggy", "bu")`, true},
+		{`ends_with("buggy", "bu")`, false},
+		{`index_of("привет", "вет")`, 3},
+		{`index_of("abc", "d")`, -1},
+		{`substr("привет", 1, 3)`, "рив"},
+		{`substr("привет", -3)`, "вет"},
+		{`substr("abc", 1, 10)`, "bc"},
+		{`substr("abc", 5)`, ""},
+		{`substr("abc", 0, -1)`, "length given to `substr` must not be negative, got -1"},
+		{`json_stringify(chars("日本"))`, `["日","本"]`},
+		{`repeat("ab", 3)`, "ababab"},
+		{`repeat("ab", 0)`, ""},
+		{`repeat("ab", -1)`, "count given to `repeat` must not be negative, got -1"},
+		{`format("%s is %d years and %.1f%% done", "Buggy", 3, 99.5)`, "Buggy is 3 years and 99.5% done"},
+		{`format("[%5s|%-3d|%x|%t|%v]", "a", 7, 255, true, [1, "b"])`, "[    a|7  |ff|true|[1, b]]"},
+		{`format("%f", 2)`, "2.000000"},
+		{`format("%d", "x")`, "format: %d does not take STRING"},
+		{`format("%d %d", 1)`, "format: missing argument for %d"},
+		{`format("%d", 1, 2)`, "format: 1 arguments left over"},
+		{`format("%y", 1)`, "format: unknown verb %y"},
+		{`format("50%")`, "format: incomplete verb % at the end"},
+		{`format()`, "wrong number of arguments. got=0, want>=1"},
+		{`upper(1)`, "argument to `upper` not supported, got INTEGER"},
+		{`split("a", 1)`, "argument to `split` not supported, got INTEGER"},
+		{`split()`, "wrong number of arguments. got=0, want=1 or 2"},
+		{`replace("a")`, "wrong number of arguments. got=1, want=3 or 4"},
+		{`substr("a")`, "wrong number of arguments. got=1, want=2 or 3"},
+	}
+	for _, tt := range tests {
+		ExpectObject(t, tt.input, engine.eval(tt.input), tt.expected)
+	}
+}
+
+func testCollectionBuiltins(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`json_stringify(map([1, 2, 3], fn(x) { x * 2 }))`, `[2,4,6]`},
+		{`json_stringify(map(["a", "bc"], len))`, `[1,2]`},
+		{`let k = 10; json_stringify(map([1, 2], fn(x) { x + k }))`, `[11,12]`},
+		{`json_stringify(map([[1, 2], [3]], fn(xs) { map(xs, fn(x) { -x }) }))`, `[[-1,-2],[-3]]`},
+		{`json_stringify(filter(range(10), fn(x) { x % 3 == 0 }))`, `[0,3,6,9]`},
+		{`reduce([1, 2, 3, 4], fn(acc, x) { acc + x })`, 10},
+		{`reduce([], fn(acc, x) { acc + x }, 5)`, 5},
+		{`reduce(["a", "b"], fn(acc, x) { acc + x }, "")`, "ab"},
+		{`reduce([], fn(acc, x) { acc + x })`, "`reduce` of an empty array needs an initial value"},
+		{`find([1, 5, 8], fn(x) { x > 3 })`, 5},
+		{`find([1], fn(x) { x > 3 })`, nil},
+		{`any([1, 2], fn(x) { x > 1 })`, true},
+		{`any([], fn(x) { true })`, false},
+		{`all([1, 2], fn(x) { x > 1 })`, false},
+		{`all([1, "a", true])`, true},
+		{`json_stringify(flat_map([1, 2], fn(x) { [x, x * 10] }))`, `[1,10,2,20]`},
+		{`json_stringify(flat_map([1, 2], fn(x) { x }))`, `[1,2]`},
+		{`json_stringify(zip([1, 2, 3], ["a", "b"]))`, `[[1,"a"],[2,"b"]]`},
+		{`json_stringify(enumerate(["a", "b"]))`, `[[0,"a"],[1,"b"]]`},
+		{`json_stringify(range(3))`, `[0,1,2]`},
+		{`json_stringify(range(2, 5))`, `[2,3,4]`},
+		{`json_stringify(range(5, 0, -2))`, `[5,3,1]`},
+		{`json_stringify(range(5, 2))`, `[]`},
+		{`range(0, 10, 0)`, "step given to `range` must not be 0"},
+		{`json_stringify(sort([3, 1.5, 2]))`, `[1.5,2,3]`},
+		{`json_stringify(sort(["b", "c", "a"]))`, `["a","b","c"]`},
+		{`json_stringify(sort([3, 1, 2], fn(a, b) { a > b }))`, `[3,2,1]`},
+		{`json_stringify(sort(["bb", "a", "ccc"], fn(a, b) { len(a) - len(b) }))`, `["a","bb","ccc"]`},
+		{`let xs = [2, 1]; sort(xs); xs[0]`, 2},
+		{`sort([1, "a"])`, "`sort` cannot compare STRING with INTEGER without a comparator"},
+		{`sort([1, 2], fn(a, b) { "x" })`, "comparator given to `sort` must return BOOLEAN or INTEGER, got STRING"},
+		{`json_stringify(reverse([1, 2, 3]))`, `[3,2,1]`},
+		{`reverse("añb")`, "bña"},
+		{`json_stringify(slice([1, 2, 3, 4], 1, 3))`, `[2,3]`},
+		{`slice("привет", -3)`, "вет"},
+		{`map([1], 2)`, "argument to `map` not supported, got INTEGER"},
+		{`map(1, fn(x) { x })`, "argument to `map` not supported, got INTEGER"},
+		{`map([1], fn(x) { x + "a" })`, "type mismatch: INTEGER + STRING"},
+		{`map([1, 2], fn(x) { throw "bad " + str(x) })`, "identifier not found: str"},
+		{`try { map([1], fn(x) { throw "no" }) } catch (e) { e["message"] }`, "no"},
+		{`json_stringify(map([1, 2], fn(x) { try { if (x == 2) { throw "two" }; x } catch (e) { 0 } }))`, `[1,0]`},
+		{`let f = fn() { map([1], fn(x) { return x + 1; 99 }) }; json_stringify(f())`, `[2]`},
+	}
+	for _, tt := range tests {
+		ExpectObject(t, tt.input, engine.eval(tt.input), tt.expected)
+	}
+}
+
+func testCallbackErrors(t *testing.T, engine Engine) {
+	input := `let bad = fn(x) {
+  x + "a"
+};
+let run = fn() { map([1], bad) };
+run()`
+	evaluated := engine.eval(input)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	expected := "TypeError: type mismatch: INTEGER + STRING\n" +
+		"    at bad (2:5)\n" +
+		"    at run (4:21)\n" +
+		"    at <main> (5:4)\n"
+	if errObj.Traceback() != expected {
+		t.Errorf("wrong traceback.\nwant=%q\ngot= %q", expected, errObj.Traceback())
+	}
+
+	limited := engine(`range(1000)`, object.NewBudget(nil, object.Limits{MaxArrayLength: 100}))
+	ExpectKind(t, limited, object.ARRAY_LIMIT_ERROR)
+	limited = engine(`repeat("ab", 1000)`, object.NewBudget(nil, object.Limits{MaxStringLength: 100}))
+	ExpectKind(t, limited, object.STRING_LIMIT_ERROR)
+	limited = engine(`let f = fn(x) { map([x], f) }; f(1)`, object.NewBudget(nil, object.Limits{MaxCallDepth: 50}))
+	ExpectKind(t, limited, object.CALL_DEPTH_ERROR)
+}
+
+func testHashBuiltins(t *testing.T, engine Engine) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`format("%v", {"b": 1, "a": 2})`, "{b: 1, a: 2}"},
+		{`json_stringify({"b": 1, "a": 2, "c": 3})`, `{"b":1,"a":2,"c":3}`},
+		{`let s = ""; for (k in {"z": 1, "a": 2}) { s += k }; s`, "za"},
+		{`let h = {"a": 1, "b": 2}; h["a"] = 3; json_stringify(h)`, `{"a":3,"b":2}`},
+		{`let h = {"a": 1, "b": 2}; h["c"] = 3; json_stringify(keys(h))`, `["a","b","c"]`},
+		{`json_stringify(keys({"b": 1, 2: 2, true: 3}))`, `["b",2,true]`},
+		{`json_stringify(values({"b": 1, "a": 2}))`, `[1,2]`},
+		{`json_stringify(keys({}))`, `[]`},
+		{`has({"a": 1}, "a")`, true},
+		{`has({"a": find([], len)}, "a")`, true},
+		{`has({"a": 1}, 1)`, false},
+		{`has({}, [1])`, "unusable as hash key: ARRAY"},
+		{`json_stringify(delete({"a": 1, "b": 2}, "a"))`, `{"b":2}`},
+		{`json_stringify(delete({"a": 1}, "x"))`, `{"a":1}`},
+		{`let h = {"a": 1}; delete(h, "a"); h["a"]`, 1},
+		{`json_stringify(set(delete({"a": 1, "b": 2}, "a"), "a", 3))`, `{"b":2,"a":3}`},
+		{`json_stringify(set({"a": 1, "b": 2}, "a", 3))`, `{"a":3,"b":2}`},
+		{`let h = {}; set(h, "a", 1); len(keys(h))`, 0},
+		{`set({}, fn() {}, 1)`, "unusable as hash key: FN"},
+		{`json_stringify(merge({"a": 1, "b": 2}, {"c": 3, "a": 4}))`, `{"a":4,"b":2,"c":3}`},
+		{`json_stringify(merge({}))`, `{}`},
+		{`merge()`, "wrong number of arguments. got=0, want>=1"},
+		{`merge({}, [])`, "argument to `merge` not supported, got ARRAY"},
+		{`keys([1])`, "argument to `keys` not supported, got ARRAY"},
+		{`has({})`, "wrong number of arguments. got=1, want=2"},
+	}
+	for _, tt := range tests {
+		ExpectObject(t, tt.input, engine.eval(tt.input), tt.expected)
+	}
+}
+
+func testModules(t *testing.T, engine Engine) {
+	dir := writeModules(t, map[string]string{
+		"lib.bg": `let secret = 10;
+export let add = fn(x) { x + secret };
+export let name = "lib";
+let hidden = fn() { visible };
+export let peek = fn() { hidden() };
+export let seeMain = fn() { mainOnly };`,
+		"state.bg":         `export let state = {"n": 0};`,
+		"counter.bg":       `export let counter = 0; export let bump = fn() { counter = counter + 1 };`,
+		"pkg/math.bg":      `import "helpers.bg" as h; export let square = fn(x) { h.mul(x, x) };`,
+		"pkg/helpers.bg":   `export let mul = fn(a, b) { a * b };`,
+		"a.bg":             `import "b.bg" as b; export let x = 1;`,
+		"b.bg":             `import "a.bg" as a; export let y = 2;`,
+		"bad.bg":           `let = 1;`,
+		"fail.bg":          "let half = fn(n) { 10 / n };\nexport let x = half(0);",
+		"uses_builtins.bg": `export let size = fn(xs) { len(xs) };`,
+		"imports_fail.bg":  `import "fail.bg" as f;`,
+		"caught.bg":        `try { throw "x" } catch (err) { 0 }; export let err = "none";`,
+	})
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`import "{dir}/lib.bg" as lib; lib.add(1)`, 11},
+		{`let secret = 1; import "{dir}/lib.bg" as lib; lib.add(1) + secret`, 12},
+		{`import "{dir}/lib.bg" as lib; lib.name`, "lib"},
+		{`import "{dir}/lib.bg" as lib; format("%v", lib)`, `module "{dir}/lib.bg"`},
+		{`import "{dir}/lib.bg" as lib; lib.secret`, "module {dir}/lib.bg does not export secret"},
+		{`let visible = 1; import "{dir}/lib.bg" as lib; lib.peek()`, "identifier not found: visible"},
+		{`let mainOnly = 5; import "{dir}/lib.bg" as lib; lib.seeMain()`, "identifier not found: mainOnly"},
+		{`import "{dir}/pkg/math.bg" as m; m.square(7)`, 49},
+		{`import "{dir}/uses_builtins.bg" as u; u.size([1, 2, 3])`, 3},
+		{`import "{dir}/counter.bg" as c; c.bump(); c.bump(); c.counter`, 2},
+		{`import "{dir}/counter.bg" as c; import "{dir}/counter.bg" as d; c.bump(); d.counter`, 1},
+		{`import "{dir}/state.bg" as a; a.state["n"] = 5; import "{dir}/pkg/../state.bg" as b; b.state["n"]`, 5},
+		{`import "{dir}/a.bg" as a; a.x`, "import cycle: {dir}/a.bg -> {dir}/b.bg -> {dir}/a.bg"},
+		{`import "{dir}/missing.bg" as m;`, "cannot import {dir}/missing.bg: no such file or directory"},
+		{`import "{dir}/bad.bg" as m;`, "cannot import {dir}/bad.bg: {dir}/bad.bg:1:5: expected identifier, found ="},
+		{`import "{dir}/fail.bg" as f;`, "division by zero"},
+		{`import "{dir}/caught.bg" as c; c.err`, "none"},
+		{`let h = {}; h.x`, "member access not supported: HASH.x"},
+	}
+	for _, tt := range tests {
+		input := strings.ReplaceAll(tt.input, "{dir}", dir)
+		expected := tt.expected
+		if s, ok := expected.(string); ok {
+			expected = strings.ReplaceAll(s, "{dir}", dir)
+		}
+		ExpectObject(t, input, engine.eval(input), expected)
+	}
+
+	evaluated := engine.eval(`import "` + dir + `/imports_fail.bg" as f;`)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	expected := "ZeroDivisionError: division by zero\n" +
+		"    at half (" + dir + "/fail.bg:1:23)\n" +
+		"    at <module " + dir + "/fail.bg> (" + dir + "/fail.bg:2:20)\n" +
+		"    at <module " + dir + "/imports_fail.bg> (" + dir + "/imports_fail.bg:1:1)\n" +
+		"    at <main> (1:1)\n"
+	if errObj.Traceback() != expected {
+		t.Errorf("wrong traceback.\nexpected=\n%s\ngot=\n%s", expected, errObj.Traceback())
+	}
+}
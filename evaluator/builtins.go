@@ -1,35 +1,66 @@
 package evaluator
 
 import (
+	"bufio"
 	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/smiksha1701/buggy/object"
 )
 
+func init() {
+	for _, group := range []map[string]*object.Builtin{stringBuiltins, collectionBuiltins, hashBuiltins} {
+		for name, builtin := range group {
+			builtins[name] = builtin
+		}
+	}
+}
+
+// LookupBuiltin returns the builtin function bound to name, if any.
+func LookupBuiltin(name string) (*object.Builtin, bool) {
+	builtin, ok := builtins[name]
+	return builtin, ok
+}
+
 var builtins = map[string]*object.Builtin{
 	"len": &object.Builtin{
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
+				return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1", len(args))
 			}
 			switch arg := args[0].(type) {
 			case *object.String:
-				return &object.Integer{Value: int64(len(arg.Value))}
+				return &object.Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
 			case *object.Array:
 				return &object.Integer{Value: int64(len(arg.Elements))}
 			default:
-				return newError("argument to `len` not supported, got %s", args[0].Type())
+				return newError(object.TYPE_ERROR, "argument to `len` not supported, got %s", args[0].Type())
+			}
+		},
+	},
+	"byte_len": &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			if len(args) != 1 {
+				return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1", len(args))
 			}
+			arg, ok := args[0].(*object.String)
+			if !ok {
+				return newError(object.TYPE_ERROR, "argument to `byte_len` not supported, got %s", args[0].Type())
+			}
+			return &object.Integer{Value: int64(len(arg.Value))}
 		},
 	},
 	"first": &object.Builtin{
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
+				return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1", len(args))
 			}
 
 			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to `first` not supported, got %s", args[0].Type())
+				return newError(object.TYPE_ERROR, "argument to `first` not supported, got %s", args[0].Type())
 			}
 
 			arr := args[0].(*object.Array)
@@ -43,11 +74,11 @@ var builtins = map[string]*object.Builtin{
 	"last": &object.Builtin{
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
+				return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1", len(args))
 			}
 
 			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to `last` not supported, got %s", args[0].Type())
+				return newError(object.TYPE_ERROR, "argument to `last` not supported, got %s", args[0].Type())
 			}
 
 			arr := args[0].(*object.Array)
@@ -62,11 +93,11 @@ var builtins = map[string]*object.Builtin{
 	"rest": &object.Builtin{
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 1 {
-				return newError("wrong number of arguments. got=%d, want=1", len(args))
+				return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1", len(args))
 			}
 
 			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to `rest` not supported, got %s", args[0].Type())
+				return newError(object.TYPE_ERROR, "argument to `rest` not supported, got %s", args[0].Type())
 			}
 
 			arr := args[0].(*object.Array)
@@ -83,11 +114,11 @@ var builtins = map[string]*object.Builtin{
 	"push": &object.Builtin{
 		Fn: func(args ...object.Object) object.Object {
 			if len(args) != 2 {
-				return newError("wrong number of arguments. got=%d, want=2", len(args))
+				return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=2", len(args))
 			}
 
 			if args[0].Type() != object.ARRAY_OBJ {
-				return newError("argument to `push` not supported, got %s", args[0].Type())
+				return newError(object.TYPE_ERROR, "argument to `push` not supported, got %s", args[0].Type())
 			}
 
 			arr := args[0].(*object.Array)
@@ -103,28 +134,102 @@ var builtins = map[string]*object.Builtin{
 		Fn: func(args ...object.Object) object.Object {
 			switch len(args) {
 			case 0:
-				return &object.String{Value: `Hi again, Buggy language creator speaking. Buggy supports 5 types: integer, boolean, string, array and hash. Here is list of Buggy's built-in functions:
+				return &object.String{Value: `Hi again, Buggy language creator speaking. Buggy supports 6 types: integer, float, boolean, string, array and hash. Here is list of Buggy's built-in functions:
 	help() -> prints out this text
 	help(arg) -> prints out description of function(under development)
 	len(Array) -> returns number of elements in Array 
-	len(String) -> returns length of String
+	len(String) -> returns number of characters in String
+	byte_len(String) -> returns number of bytes in the UTF-8 encoding of String
 	first(Array) -> returns first element in Array
 	last(Array) -> returns last element in Array
 	rest(Array) -> returns new ARRAY with all elements of Array except first
 	push(Array, newVal) -> returns new ARRAY with all elements of Array with added to the end newVal  
+	say(args...) -> prints out each of args on a line of its own
+	input(prompt?) -> prints out prompt and returns the next line typed in, or null at the end of input
+	json_parse(String) -> returns the value String encodes in JSON
+	json_stringify(value, indent?) -> returns value encoded in JSON, indented by indent spaces if given
+	split(String, sep?) -> returns ARRAY of the parts of String between sep, or between spaces
+	join(Array, sep?) -> returns the strings in Array joined by sep
+	trim(String, chars?) -> returns String without the spaces, or chars, at either end
+	upper(String), lower(String) -> return String in upper or lower case
+	replace(String, old, new, n?) -> returns String with old replaced by new, n times if given
+	contains(String, sub), starts_with(String, sub), ends_with(String, sub) -> tell where sub is in String
+	index_of(String, sub) -> returns the index of the first sub in String, or -1
+	substr(String, start, length?) -> returns length characters of String from start on
+	chars(String) -> returns ARRAY of the characters of String
+	repeat(String, n) -> returns String n times over
+	format(template, args...) -> returns template with its %d, %s, %f... verbs replaced by args
+	map(Array, f), filter(Array, f) -> return ARRAY of f(x) for each x in Array, or of the x for which f(x) is true
+	reduce(Array, f, initial?) -> combines the elements of Array by calling f(acc, x) for each x
+	find(Array, f) -> returns the first x in Array for which f(x) is true, or null
+	any(Array, f?), all(Array, f?) -> tell whether f(x) is true for any or all x in Array
+	flat_map(Array, f) -> returns the elements of the arrays f(x) returns, one after another
+	zip(Arrays...), enumerate(Array) -> return ARRAY of [a, b, ...] or [index, x] pairs
+	range(start?, end, step?) -> returns ARRAY of the integers from start up to, but not including, end
+	sort(Array, f?) -> returns Array sorted, by f(a, b) telling whether a goes before b if given
+	reverse(Array), slice(Array, start, end?) -> return Array reversed, or Array[start:end]
+	keys(Hash), values(Hash) -> return ARRAY of the keys or values of Hash, in the order they were added
+	has(Hash, key) -> tells whether Hash has key
+	set(Hash, key, value), delete(Hash, key) -> return new HASH with key set to value, or without key
+	merge(Hashes...) -> returns new HASH with the pairs of all Hashes, later ones winning
 
 you can find detailed info on Buggy webpage smiksha1701.github.io/Buggy`}
 			case 1:
 			}
-			return newError("wrong number of arguments. got=%d, want<2", len(args))
+			return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want<2", len(args))
 		},
 	},
-	"say": &object.Builtin{
+	"say":            Say(os.Stdout),
+	"input":          Input(os.Stdin, os.Stdout),
+	"json_parse":     &object.Builtin{Fn: jsonParse},
+	"json_stringify": &object.Builtin{Fn: jsonStringify},
+}
+
+// Say returns the `say` builtin, which writes each of its arguments to out
+// on a line of its own.
+func Say(out io.Writer) *object.Builtin {
+	return &object.Builtin{
 		Fn: func(args ...object.Object) object.Object {
 			for _, arg := range args {
-				fmt.Println(arg.Inspect())
+				fmt.Fprintln(out, arg.Inspect())
 			}
 			return NULL
 		},
-	},
+	}
+}
+
+// Input returns the `input` builtin, which writes its optional prompt to out
+// and reads a line from in. It returns null once in has nothing left. A
+// *bufio.Reader is read from as it is, so that the builtin can share it
+// with other code reading the same input, such as the REPL.
+func Input(in io.Reader, out io.Writer) *object.Builtin {
+	// Any other reader is buffered on the first call, so that an unused
+	// builtin does not buffer input meant for someone else.
+	reader, _ := in.(*bufio.Reader)
+	return &object.Builtin{
+		Fn: func(args ...object.Object) object.Object {
+			switch len(args) {
+			case 0:
+			case 1:
+				prompt, ok := args[0].(*object.String)
+				if !ok {
+					return newError(object.TYPE_ERROR, "argument to `input` not supported, got %s", args[0].Type())
+				}
+				io.WriteString(out, prompt.Value)
+			default:
+				return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want<2", len(args))
+			}
+			if reader == nil {
+				reader = bufio.NewReader(in)
+			}
+			line, err := reader.ReadString('\n')
+			if err != nil && line == "" {
+				if err == io.EOF {
+					return NULL
+				}
+				return newError(object.RUNTIME_ERROR, "input: %s", err)
+			}
+			return &object.String{Value: strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")}
+		},
+	}
 }
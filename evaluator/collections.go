@@ -0,0 +1,319 @@
+package evaluator
+
+import (
+	"sort"
+
+	"github.com/smiksha1701/buggy/object"
+)
+
+// checkFunction checks that argument i of the builtin name can be called.
+func checkFunction(name string, args []object.Object, i int) *object.Error {
+	switch args[i].Type() {
+	case object.FN_OBJ, object.BUILTIN_OBJ:
+		return nil
+	}
+	return newError(object.TYPE_ERROR, "argument to `%s` not supported, got %s", name, args[i].Type())
+}
+
+// checkCallback checks the arguments of builtins that take an array and a
+// function to call on its elements.
+func checkCallback(name string, args []object.Object) *object.Error {
+	if err := checkArgs(name, args, 2, object.ARRAY_OBJ, anyType); err != nil {
+		return err
+	}
+	return checkFunction(name, args, 1)
+}
+
+func arrayElements(obj object.Object) []object.Object { return obj.(*object.Array).Elements }
+
+// collectionBuiltins call the functions they are given with one element
+// at a time and return new arrays, leaving the ones they are given alone.
+var collectionBuiltins = map[string]*object.Builtin{
+	"map": {RuntimeFn: func(rt object.Runtime, args ...object.Object) object.Object {
+		if err := checkCallback("map", args); err != nil {
+			return err
+		}
+		elements := arrayElements(args[0])
+		mapped := make([]object.Object, len(elements))
+		for i, element := range elements {
+			result := rt.Call(args[1], element)
+			if isError(result) {
+				return result
+			}
+			mapped[i] = result
+		}
+		return &object.Array{Elements: mapped}
+	}},
+	"filter": {RuntimeFn: func(rt object.Runtime, args ...object.Object) object.Object {
+		if err := checkCallback("filter", args); err != nil {
+			return err
+		}
+		kept := []object.Object{}
+		for _, element := range arrayElements(args[0]) {
+			result := rt.Call(args[1], element)
+			if isError(result) {
+				return result
+			}
+			if IsTruthy(result) {
+				kept = append(kept, element)
+			}
+		}
+		return &object.Array{Elements: kept}
+	}},
+	"reduce": {RuntimeFn: func(rt object.Runtime, args ...object.Object) object.Object {
+		if err := checkArgs("reduce", args, 2, object.ARRAY_OBJ, anyType, anyType); err != nil {
+			return err
+		}
+		if err := checkFunction("reduce", args, 1); err != nil {
+			return err
+		}
+		elements := arrayElements(args[0])
+		var acc object.Object
+		if len(args) == 3 {
+			acc = args[2]
+		} else if len(elements) > 0 {
+			acc, elements = elements[0], elements[1:]
+		} else {
+			return newError(object.VALUE_ERROR, "`reduce` of an empty array needs an initial value")
+		}
+		for _, element := range elements {
+			acc = rt.Call(args[1], acc, element)
+			if isError(acc) {
+				return acc
+			}
+		}
+		return acc
+	}},
+	"find": {RuntimeFn: func(rt object.Runtime, args ...object.Object) object.Object {
+		if err := checkCallback("find", args); err != nil {
+			return err
+		}
+		for _, element := range arrayElements(args[0]) {
+			result := rt.Call(args[1], element)
+			if isError(result) {
+				return result
+			}
+			if IsTruthy(result) {
+				return element
+			}
+		}
+		return NULL
+	}},
+	"any": {RuntimeFn: func(rt object.Runtime, args ...object.Object) object.Object {
+		return testElements(rt, "any", args, true)
+	}},
+	"all": {RuntimeFn: func(rt object.Runtime, args ...object.Object) object.Object {
+		return testElements(rt, "all", args, false)
+	}},
+	"flat_map": {RuntimeFn: func(rt object.Runtime, args ...object.Object) object.Object {
+		if err := checkCallback("flat_map", args); err != nil {
+			return err
+		}
+		flat := []object.Object{}
+		for _, element := range arrayElements(args[0]) {
+			result := rt.Call(args[1], element)
+			if isError(result) {
+				return result
+			}
+			if arr, ok := result.(*object.Array); ok {
+				flat = append(flat, arr.Elements...)
+			} else {
+				flat = append(flat, result)
+			}
+			if err := rt.Budget().CheckArray(int64(len(flat))); err != nil {
+				return err
+			}
+		}
+		return &object.Array{Elements: flat}
+	}},
+	"zip": {Fn: func(args ...object.Object) object.Object {
+		if len(args) == 0 {
+			return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=0, want>=1")
+		}
+		length := -1
+		for _, arg := range args {
+			arr, ok := arg.(*object.Array)
+			if !ok {
+				return newError(object.TYPE_ERROR, "argument to `zip` not supported, got %s", arg.Type())
+			}
+			if length < 0 || len(arr.Elements) < length {
+				length = len(arr.Elements)
+			}
+		}
+		zipped := make([]object.Object, length)
+		for i := range zipped {
+			tuple := make([]object.Object, len(args))
+			for j, arg := range args {
+				tuple[j] = arrayElements(arg)[i]
+			}
+			zipped[i] = &object.Array{Elements: tuple}
+		}
+		return &object.Array{Elements: zipped}
+	}},
+	"enumerate": {Fn: func(args ...object.Object) object.Object {
+		if err := checkArgs("enumerate", args, 1, object.ARRAY_OBJ); err != nil {
+			return err
+		}
+		elements := arrayElements(args[0])
+		pairs := make([]object.Object, len(elements))
+		for i, element := range elements {
+			pairs[i] = &object.Array{Elements: []object.Object{&object.Integer{Value: int64(i)}, element}}
+		}
+		return &object.Array{Elements: pairs}
+	}},
+	"range": {RuntimeFn: func(rt object.Runtime, args ...object.Object) object.Object {
+		err := checkArgs("range", args, 1, object.INTEGER_OBJ, object.INTEGER_OBJ, object.INTEGER_OBJ)
+		if err != nil {
+			return err
+		}
+		start, end, step := int64(0), integerValue(args[0]), int64(1)
+		if len(args) > 1 {
+			start, end = integerValue(args[0]), integerValue(args[1])
+		}
+		if len(args) > 2 {
+			step = integerValue(args[2])
+		}
+		if step == 0 {
+			return newError(object.VALUE_ERROR, "step given to `range` must not be 0")
+		}
+		// The count is worked out in uint64, which holds the distance
+		// between any two integers.
+		var count uint64
+		if step > 0 && start < end {
+			count = (uint64(end-start)-1)/uint64(step) + 1
+		} else if step < 0 && start > end {
+			count = (uint64(start-end)-1)/uint64(-step) + 1
+		}
+		if count > 1<<31 {
+			return newError(object.VALUE_ERROR, "`range` would make an array of %d elements", count)
+		}
+		if err := rt.Budget().CheckArray(int64(count)); err != nil {
+			return err
+		}
+		elements := make([]object.Object, count)
+		for i := range elements {
+			elements[i] = &object.Integer{Value: start + int64(i)*step}
+		}
+		return &object.Array{Elements: elements}
+	}},
+	"sort": {RuntimeFn: func(rt object.Runtime, args ...object.Object) object.Object {
+		if err := checkArgs("sort", args, 1, object.ARRAY_OBJ, anyType); err != nil {
+			return err
+		}
+		if len(args) == 2 {
+			if err := checkFunction("sort", args, 1); err != nil {
+				return err
+			}
+		}
+		sorted := make([]object.Object, len(arrayElements(args[0])))
+		copy(sorted, arrayElements(args[0]))
+		var err object.Object
+		sort.SliceStable(sorted, func(i, j int) bool {
+			if err != nil {
+				return false
+			}
+			var less bool
+			if len(args) == 2 {
+				less, err = compareWith(rt, args[1], sorted[i], sorted[j])
+			} else {
+				less, err = compare(sorted[i], sorted[j])
+			}
+			return less
+		})
+		if err != nil {
+			return err
+		}
+		return &object.Array{Elements: sorted}
+	}},
+	"reverse": {Fn: func(args ...object.Object) object.Object {
+		if len(args) != 1 {
+			return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1", len(args))
+		}
+		switch arg := args[0].(type) {
+		case *object.Array:
+			reversed := make([]object.Object, len(arg.Elements))
+			for i, element := range arg.Elements {
+				reversed[len(reversed)-1-i] = element
+			}
+			return &object.Array{Elements: reversed}
+		case *object.String:
+			chars := []rune(arg.Value)
+			for i, j := 0, len(chars)-1; i < j; i, j = i+1, j-1 {
+				chars[i], chars[j] = chars[j], chars[i]
+			}
+			return newString(string(chars))
+		}
+		return newError(object.TYPE_ERROR, "argument to `reverse` not supported, got %s", args[0].Type())
+	}},
+	"slice": {Fn: func(args ...object.Object) object.Object {
+		if err := checkArgs("slice", args, 2, anyType, anyType, anyType); err != nil {
+			return err
+		}
+		high := object.Object(NULL)
+		if len(args) == 3 {
+			high = args[2]
+		}
+		return EvalSliceExpression(args[0], args[1], high)
+	}},
+}
+
+// testElements is `any` when want is true and `all` when it is false: it
+// looks for an element for which the function, if given, returns want, or
+// that is itself as truthy as want.
+func testElements(rt object.Runtime, name string, args []object.Object, want bool) object.Object {
+	if err := checkArgs(name, args, 1, object.ARRAY_OBJ, anyType); err != nil {
+		return err
+	}
+	if len(args) == 2 {
+		if err := checkFunction(name, args, 1); err != nil {
+			return err
+		}
+	}
+	for _, element := range arrayElements(args[0]) {
+		result := element
+		if len(args) == 2 {
+			result = rt.Call(args[1], element)
+			if isError(result) {
+				return result
+			}
+		}
+		if IsTruthy(result) == want {
+			return nativeBooltoBooleanObj(want)
+		}
+	}
+	return nativeBooltoBooleanObj(!want)
+}
+
+// compare orders numbers and strings for `sort` when it has no comparator.
+func compare(a, b object.Object) (bool, object.Object) {
+	if isNumber(a) && isNumber(b) {
+		if a, ok := a.(*object.Integer); ok {
+			if b, ok := b.(*object.Integer); ok {
+				return a.Value < b.Value, nil
+			}
+		}
+		return toFloat(a) < toFloat(b), nil
+	}
+	if a, ok := a.(*object.String); ok {
+		if b, ok := b.(*object.String); ok {
+			return a.Value < b.Value, nil
+		}
+	}
+	return false, newError(object.TYPE_ERROR, "`sort` cannot compare %s with %s without a comparator", a.Type(), b.Type())
+}
+
+// compareWith asks the comparator given to `sort` whether a goes before b.
+// It may answer with a boolean or, like comparators in many languages,
+// with a negative, zero or positive integer.
+func compareWith(rt object.Runtime, comparator, a, b object.Object) (bool, object.Object) {
+	result := rt.Call(comparator, a, b)
+	switch result := result.(type) {
+	case *object.Error:
+		return false, result
+	case *object.Boolean:
+		return result.Value, nil
+	case *object.Integer:
+		return result.Value < 0, nil
+	}
+	return false, newError(object.TYPE_ERROR, "comparator given to `sort` must return BOOLEAN or INTEGER, got %s", result.Type())
+}
@@ -2,18 +2,36 @@ package evaluator
 
 import (
 	"fmt"
+	"math"
+	"strings"
 
 	"github.com/smiksha1701/buggy/ast"
 	"github.com/smiksha1701/buggy/object"
+	"github.com/smiksha1701/buggy/token"
 )
 
 var (
-	NULL  = &object.Null{}
-	TRUE  = &object.Boolean{Value: true}
-	FALSE = &object.Boolean{Value: false}
+	NULL     = object.NULL
+	TRUE     = object.TRUE
+	FALSE    = object.FALSE
+	BREAK    = &object.Break{}
+	CONTINUE = &object.Continue{}
 )
 
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	var result object.Object
+	if err := env.Budget().Step(); err != nil {
+		result = err
+	} else {
+		result = eval(node, env)
+	}
+	if errObj, ok := result.(*object.Error); ok && !errObj.Pos.IsValid() {
+		errObj.Pos = node.Pos()
+	}
+	return result
+}
+
+func eval(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 	case *ast.Program:
 		return evalProgram(node, env)
@@ -27,12 +45,12 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(right) {
 			return right
 		}
-		return evalPrefixExpression(node.Operator, right)
+		return EvalPrefixExpression(node.Operator, right)
 
 	case *ast.FunctionLiteral:
 		parameters := node.Parameters
 		body := node.Body
-		return &object.Fn{Parameters: parameters, Body: body, Env: env}
+		return &object.Fn{Parameters: parameters, Body: body, Env: env, Name: node.Name}
 
 	case *ast.CallExpression:
 		function := Eval(node.Function, env)
@@ -43,7 +61,7 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if len(args) == 1 && isError(args[0]) {
 			return args[0]
 		}
-		return applyFunction(function, args)
+		return applyFunction(env.Budget(), function, args, node.Pos())
 
 	case *ast.ArrayLiteral:
 		elements := evalExpressions(node.Elements, env)
@@ -61,9 +79,22 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(index) {
 			return index
 		}
-		return evalIndexExpression(left, index)
+		return EvalIndexExpression(left, index)
+
+	case *ast.SliceExpression:
+		return evalSliceExpression(node, env)
+
+	case *ast.MemberExpression:
+		left := Eval(node.Left, env)
+		if isError(left) {
+			return left
+		}
+		return EvalMemberExpression(left, node.Name.Value)
 
 	case *ast.InfixExpression:
+		if node.Operator == "&&" || node.Operator == "||" {
+			return evalLogicalExpression(node, env)
+		}
 		left := Eval(node.Left, env)
 		if isError(left) {
 			return left
@@ -72,13 +103,13 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(right) {
 			return right
 		}
-		return evalInfixExpression(node.Operator, left, right)
+		return evalInfix(env, node.Operator, left, right)
 
 	case *ast.BlockStatement:
 		return evalBlockStatement(node, env)
 
 	case *ast.ReturnStatement:
-		val := Eval(node.Return, env)
+		val := evalTailExpression(node.Return, env)
 		if isError(val) {
 			return val
 		}
@@ -87,6 +118,28 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.IfExpression:
 		return evalIfExpression(node, env)
 
+	case *ast.WhileStatement:
+		return evalWhileStatement(node, env)
+
+	case *ast.ForStatement:
+		return evalForStatement(node, env)
+
+	case *ast.ThrowStatement:
+		val := Eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		return object.NewThrownError(val)
+
+	case *ast.TryExpression:
+		return evalTryExpression(node, env)
+
+	case *ast.BreakStatement:
+		return BREAK
+
+	case *ast.ContinueStatement:
+		return CONTINUE
+
 	case *ast.Identifier:
 		return evalIdentifier(node, env)
 
@@ -97,9 +150,21 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		}
 		env.Set(node.Name.Value, val)
 
+	case *ast.AssignStatement:
+		return evalAssignStatement(node, env)
+
+	case *ast.ImportStatement:
+		return evalImportStatement(node, env)
+
+	case *ast.ExportStatement:
+		return Eval(node.Statement, env)
+
 	case *ast.IntegerLiteral:
 		return &object.Integer{Value: node.Value}
 
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
+
 	case *ast.StringLiteral:
 		return &object.String{Value: node.Value}
 
@@ -110,36 +175,162 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	return nil
 }
 
-func evalIndexExpression(left, index object.Object) object.Object {
+func EvalIndexExpression(left, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
 		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalStringIndexExpression(left, index)
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index)
 	default:
-		return newError("index operator not supported: %s", left.Type())
+		return newError(object.TYPE_ERROR, "index operator not supported: %s", left.Type())
+	}
+}
+
+// evalStringIndexExpression returns the character at index as a string.
+// Like arrays, strings are indexed by character and negative indices count
+// from the end.
+func evalStringIndexExpression(left, index object.Object) object.Object {
+	chars := []rune(left.(*object.String).Value)
+	idx := index.(*object.Integer).Value
+	max := int64(len(chars) - 1)
+	if idx > max || idx < -(max+1) {
+		return NULL
+	}
+
+	if idx < 0 {
+		idx = max + idx + 1
+	}
+
+	return &object.String{Value: string(chars[idx])}
+}
+
+func evalSliceExpression(node *ast.SliceExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+	bounds := []object.Object{NULL, NULL}
+	for i, boundNode := range []ast.Expression{node.Low, node.High} {
+		if boundNode == nil {
+			continue
+		}
+		bounds[i] = Eval(boundNode, env)
+		if isError(bounds[i]) {
+			return bounds[i]
+		}
+	}
+	return EvalSliceExpression(left, bounds[0], bounds[1])
+}
+
+// EvalSliceExpression returns a copy of the part of an array or string
+// between low and high, counting characters for strings. A NULL bound stands
+// for the start or the end, negative bounds count from the end and bounds
+// past either end are clamped. It is exported for the vm.
+func EvalSliceExpression(left, low, high object.Object) object.Object {
+	switch left := left.(type) {
+	case *object.Array:
+		start, end, err := sliceBounds(low, high, len(left.Elements))
+		if err != nil {
+			return err
+		}
+		elements := make([]object.Object, end-start)
+		copy(elements, left.Elements[start:end])
+		return &object.Array{Elements: elements}
+	case *object.String:
+		chars := []rune(left.Value)
+		start, end, err := sliceBounds(low, high, len(chars))
+		if err != nil {
+			return err
+		}
+		return &object.String{Value: string(chars[start:end])}
+	default:
+		return newError(object.TYPE_ERROR, "slice operator not supported: %s", left.Type())
+	}
+}
+
+func sliceBounds(low, high object.Object, length int) (int, int, *object.Error) {
+	start, err := sliceBound(low, 0, length)
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := sliceBound(high, length, length)
+	if err != nil {
+		return 0, 0, err
+	}
+	if end < start {
+		end = start
+	}
+	return start, end, nil
+}
+
+func sliceBound(bound object.Object, omitted, length int) (int, *object.Error) {
+	if bound == NULL {
+		return omitted, nil
+	}
+	integer, ok := bound.(*object.Integer)
+	if !ok {
+		return 0, newError(object.TYPE_ERROR, "slice bound must be INTEGER, got %s", bound.Type())
+	}
+	idx := integer.Value
+	if idx < 0 {
+		idx += int64(length)
+	}
+	if idx < 0 {
+		idx = 0
+	}
+	if idx > int64(length) {
+		idx = int64(length)
+	}
+	return int(idx), nil
+}
+
+// EvalIndexAssignment stores value under index in an array or hash, changing
+// it in place. It returns nil on success. It is exported for the vm.
+func EvalIndexAssignment(left, index, value object.Object) object.Object {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+		arrayObject := left.(*object.Array)
+		idx := index.(*object.Integer).Value
+		max := int64(len(arrayObject.Elements) - 1)
+		if idx > max || idx < -(max+1) {
+			return newError(object.INDEX_ERROR, "index out of range: %d", idx)
+		}
+		if idx < 0 {
+			idx = max + idx + 1
+		}
+		arrayObject.Elements[idx] = value
+	case left.Type() == object.HASH_OBJ:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError(object.TYPE_ERROR, "unusable as hash key: %s", index.Type())
+		}
+		left.(*object.Hash).Set(key.HashKey(), object.HashPair{Key: index, Value: value})
+	default:
+		return newError(object.TYPE_ERROR, "index assignment not supported: %s", left.Type())
 	}
+	return nil
 }
+
 func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
-	pairs := make(map[object.HashKey]object.HashPair)
-	for keyNode, valueNode := range node.Pairs {
+	hash := object.NewHash()
+	for _, keyNode := range node.Keys {
 		key := Eval(keyNode, env)
 		if isError(key) {
 			return key
 		}
 		hashkey, ok := key.(object.Hashable)
 		if !ok {
-			return newError("unusable as hash key: %s", key.Type())
+			return newError(object.TYPE_ERROR, "unusable as hash key: %s", key.Type())
 		}
-		value := Eval(valueNode, env)
+		value := Eval(node.Pairs[keyNode], env)
 		if isError(value) {
 			return value
 		}
-		hashed := hashkey.HashKey()
-		pairs[hashed] = object.HashPair{Key: key, Value: value}
+		hash.Set(hashkey.HashKey(), object.HashPair{Key: key, Value: value})
 	}
-	return &object.Hash{Pairs: pairs}
-
+	return hash
 }
 
 func evalHashIndexExpression(left, index object.Object) object.Object {
@@ -147,7 +338,7 @@ func evalHashIndexExpression(left, index object.Object) object.Object {
 
 	key, ok := index.(object.Hashable)
 	if !ok {
-		return newError("unusable as hash key: %s", index.Type())
+		return newError(object.TYPE_ERROR, "unusable as hash key: %s", index.Type())
 	}
 	pair, ok := hashObject.Pairs[key.HashKey()]
 	if !ok {
@@ -171,18 +362,85 @@ func evalArrayIndexExpression(left, index object.Object) object.Object {
 	return arrayObject.Elements[idx]
 }
 
-func applyFunction(function object.Object, args []object.Object) object.Object {
-	switch function := function.(type) {
-	case *object.Fn:
-		extendedEnv := extendFunctionEnv(function, args)
-		evaluated := Eval(function.Body, extendedEnv)
-		return unwrapReturnValue(evaluated)
-	case *object.Builtin:
-		return function.Fn(args...)
-	default:
-		return newError("not a function: %s", function.Type())
+// applyFunction calls function with args. callPos is the position of the
+// call, recorded in the stack of any error raised inside a Buggy function.
+//
+// A function that ends in a call in tail position returns a *tailCall
+// instead of making it, and the loop below makes the call in its place, so
+// tail recursion runs without growing the Go stack. The replaced functions
+// are recorded in TailFrames, so error stacks still list them.
+//
+// b limits the depth of calls and the size of the values builtins return.
+func applyFunction(b *object.Budget, function object.Object, args []object.Object, callPos token.Position) object.Object {
+	if err := b.Enter(); err != nil {
+		return err
+	}
+	defer b.Leave()
+	var replaced TailFrames
+	pos := callPos // the position of the call being made
+	for {
+		var result object.Object
+		switch fn := function.(type) {
+		case *object.Fn:
+			if len(args) < len(fn.Parameters) {
+				result = newError(object.ARGUMENT_ERROR, "wrong number of arguments: want=%d, got=%d",
+					len(fn.Parameters), len(args))
+				break
+			}
+			evaluated := evalTailBlock(fn.Body, extendFunctionEnv(fn, args))
+			if errObj, ok := evaluated.(*object.Error); ok {
+				errObj.Stack = append(errObj.Stack, object.StackFrame{Function: fn.Name, Pos: pos})
+				errObj.Stack = replaced.AppendTo(errObj.Stack)
+				return errObj
+			}
+			evaluated = unwrapReturnValue(evaluated)
+			if call, ok := evaluated.(*tailCall); ok {
+				replaced.Push(object.StackFrame{Function: fn.Name, Pos: pos})
+				pos = call.pos
+				function, args = call.function, call.args
+				continue
+			}
+			if evaluated == nil {
+				return NULL
+			}
+			return evaluated
+		case *object.Builtin:
+			result = fn.Call(evalRuntime{budget: b, pos: pos}, args...)
+			if err := b.CheckValue(result); err != nil {
+				result = err
+			}
+		default:
+			result = newError(object.TYPE_ERROR, "not a function: %s", function.Type())
+		}
+		// An error raised by the call itself belongs to the function that
+		// made the tail call, as if it had made the call normally.
+		if errObj, ok := result.(*object.Error); ok && !replaced.Empty() {
+			if !errObj.Pos.IsValid() {
+				errObj.Pos = pos
+			}
+			errObj.Stack = replaced.AppendTo(errObj.Stack)
+		}
+		return result
 	}
+}
+
+// evalRuntime lets builtins called at pos call back into the program.
+type evalRuntime struct {
+	budget *object.Budget
+	pos    token.Position
+}
 
+func (rt evalRuntime) Call(fn object.Object, args ...object.Object) object.Object {
+	return applyFunction(rt.budget, fn, args, rt.pos)
+}
+
+func (rt evalRuntime) Budget() *object.Budget { return rt.budget }
+
+// ApplyFunction calls fn, a Buggy function or a builtin, with args the way
+// a call expression in env would, so that Go code can call back into a
+// program. Calls made this way are limited by the budget of env.
+func ApplyFunction(env *object.Environment, fn object.Object, args []object.Object) object.Object {
+	return applyFunction(env.Budget(), fn, args, token.Position{})
 }
 
 func extendFunctionEnv(fn *object.Fn, args []object.Object) *object.Environment {
@@ -208,7 +466,69 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 		return builtin
 	}
 
-	return newError("identifier not found: " + node.Value)
+	return newError(object.NAME_ERROR, "identifier not found: "+node.Value)
+}
+
+// evalAssignStatement rebinds a name in the closest environment that
+// declares it, or stores into an array or hash. Compound operators such as
+// += read the current value first.
+func evalAssignStatement(node *ast.AssignStatement, env *object.Environment) object.Object {
+	operator := strings.TrimSuffix(node.Operator, "=")
+
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		var current object.Object
+		if operator != "" {
+			current = evalIdentifier(target, env)
+			if isError(current) {
+				return current
+			}
+		}
+		val := Eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		if operator != "" {
+			val = evalInfix(env, operator, current, val)
+			if isError(val) {
+				return val
+			}
+		}
+		if _, ok := env.Assign(target.Value, val); !ok {
+			return newError(object.NAME_ERROR, "cannot assign to undeclared identifier: %s", target.Value)
+		}
+
+	case *ast.IndexExpression:
+		left := Eval(target.Left, env)
+		if isError(left) {
+			return left
+		}
+		index := Eval(target.Index, env)
+		if isError(index) {
+			return index
+		}
+		val := Eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		if operator != "" {
+			current := EvalIndexExpression(left, index)
+			if isError(current) {
+				return current
+			}
+			val = evalInfix(env, operator, current, val)
+			if isError(val) {
+				return val
+			}
+		}
+		if err := EvalIndexAssignment(left, index, val); err != nil {
+			return err
+		}
+
+	default:
+		return newError(object.TYPE_ERROR, "cannot assign to %s", node.Target.String())
+	}
+	return nil
 }
 
 func evalExpressions(expressions []ast.Expression, env *object.Environment) []object.Object {
@@ -230,16 +550,125 @@ func evalIfExpression(node *ast.IfExpression, env *object.Environment) object.Ob
 	if isError(condition) {
 		return condition
 	}
-	if isTruthy(condition) {
-		return Eval(node.Consequence, env)
+	var result object.Object
+	if IsTruthy(condition) {
+		result = Eval(node.Consequence, env)
 	} else if node.Alternative != nil {
-		return Eval(node.Alternative, env)
-	} else {
+		result = Eval(node.Alternative, env)
+	}
+	// A branch ending in a statement such as let has no value.
+	if result == nil {
+		return NULL
+	}
+	return result
+}
+
+// evalTryExpression runs the try block and, if it raised an error, the
+// catch block with the error bound as a hash. The finally block always runs
+// last; if it raises an error, returns or leaves a loop that wins over the
+// outcome of the other blocks, otherwise its value is discarded. An error
+// that exhausted the budget goes through without running either.
+func evalTryExpression(node *ast.TryExpression, env *object.Environment) object.Object {
+	// A tail call returned from the try block has to run under its
+	// handler, and one from the catch block before the finally block.
+	result := forceTailCall(env, Eval(node.Body, env))
+	if errObj, ok := result.(*object.Error); ok && node.Catch != nil {
+		// The catch block is a scope of its own, so the parameter doesn't
+		// overwrite a variable of the same name outside it.
+		catchEnv := object.NewEnclosedEnv(env)
+		catchEnv.Set(node.CatchParam.Value, errObj.ToHash())
+		result = forceTailCall(catchEnv, Eval(node.Catch, catchEnv))
+	}
+	if node.Finally != nil {
+		if finally := Eval(node.Finally, env); isAbrupt(finally) {
+			return finally
+		}
+	}
+	if result == nil {
 		return NULL
 	}
+	return result
+}
+
+// isAbrupt reports whether obj stops the enclosing block: an error, a
+// return value, break or continue.
+func isAbrupt(obj object.Object) bool {
+	if obj == nil {
+		return false
+	}
+	switch obj.Type() {
+	case object.RETURN_VALUE_OBJ, object.ERROR_OBJ, object.BREAK_OBJ, object.CONTINUE_OBJ:
+		return true
+	}
+	return false
 }
 
-func isTruthy(cond object.Object) bool {
+func evalWhileStatement(node *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		condition := Eval(node.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+		if !IsTruthy(condition) {
+			return nil
+		}
+		result := Eval(node.Body, env)
+		if result == BREAK {
+			return nil
+		}
+		if isLoopExit(result) {
+			return result
+		}
+	}
+}
+
+// evalForStatement binds the loop variables in env itself, like let does in
+// a block, so they are still visible once the loop is over.
+func evalForStatement(node *ast.ForStatement, env *object.Environment) object.Object {
+	iterable := Eval(node.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+	iterator, ok := object.NewIterator(iterable)
+	if !ok {
+		return newError(object.TYPE_ERROR, "cannot iterate over %s", iterable.Type())
+	}
+	for {
+		if node.Key != nil {
+			key, value, ok := iterator.Next()
+			if !ok {
+				return nil
+			}
+			env.Set(node.Key.Value, key)
+			env.Set(node.Value.Value, value)
+		} else {
+			item, ok := iterator.NextItem()
+			if !ok {
+				return nil
+			}
+			env.Set(node.Value.Value, item)
+		}
+		result := Eval(node.Body, env)
+		if result == BREAK {
+			return nil
+		}
+		if isLoopExit(result) {
+			return result
+		}
+	}
+}
+
+// isLoopExit reports whether result ends the enclosing loop: a return or an
+// error leave it and carry on up to the caller.
+func isLoopExit(result object.Object) bool {
+	if result == nil {
+		return false
+	}
+	rt := result.Type()
+	return rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ
+}
+
+func IsTruthy(cond object.Object) bool {
 	switch cond {
 	case NULL:
 		return false
@@ -267,7 +696,7 @@ func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 
 		switch result := result.(type) {
 		case *object.ReturnValue:
-			return result.Value
+			return unwrapReturnValue(forceTailCall(env, result))
 		case *object.Error:
 			return result
 		}
@@ -281,28 +710,61 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 	for _, statement := range block.Statements {
 		result = Eval(statement, env)
 
-		if result != nil {
-			rt := result.Type()
-			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
-				return result
-			}
+		if isAbrupt(result) {
+			return result
 		}
 	}
 	return result
 }
 
-func evalPrefixExpression(operator string, right object.Object) object.Object {
+func EvalPrefixExpression(operator string, right object.Object) object.Object {
 	switch operator {
 	case "!":
 		return evalBangOperator(right)
 	case "-":
 		return evalMinusOperator(right)
 	default:
-		return newError("unknown operator: %s %s", operator, right.Type())
+		return newError(object.TYPE_ERROR, "unknown operator: %s %s", operator, right.Type())
+	}
+}
+
+// evalInfix is EvalInfixExpression within the budget of env.
+func evalInfix(env *object.Environment, operator string, left, right object.Object) object.Object {
+	if err := CheckAllocation(env.Budget(), operator, left, right); err != nil {
+		return err
 	}
+	return EvalInfixExpression(operator, left, right)
 }
 
-func evalInfixExpression(operator string, left object.Object, right object.Object) object.Object {
+// CheckAllocation fails if applying operator to left and right would build
+// a string longer than b allows. It runs before EvalInfixExpression so that
+// such a string is never built.
+func CheckAllocation(b *object.Budget, operator string, left, right object.Object) *object.Error {
+	l, ok := left.(*object.String)
+	if !ok || b == nil {
+		return nil
+	}
+	length := int64(len(l.Value))
+	switch r := right.(type) {
+	case *object.String:
+		if operator == "+" {
+			return b.CheckString(length + int64(len(r.Value)))
+		}
+	case *object.Integer:
+		if operator == "*" && length > 0 && r.Value > 1 {
+			if r.Value > math.MaxInt64/length {
+				return b.CheckString(math.MaxInt64)
+			}
+			return b.CheckString(length * r.Value)
+		}
+	}
+	return nil
+}
+
+// EvalInfixExpression applies a binary operator to two evaluated operands.
+// It is exported, together with the other operator helpers, so the bytecode
+// vm shares the tree-walker's semantics and error messages.
+func EvalInfixExpression(operator string, left object.Object, right object.Object) object.Object {
 	switch {
 	case left.Type() == right.Type() && left.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right)
@@ -310,39 +772,75 @@ func evalInfixExpression(operator string, left object.Object, right object.Objec
 		return evalMultiplyString(operator, left, right)
 	case left.Type() == right.Type() && left.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfixExpression(operator, left, right)
 	case operator == "==":
 		return nativeBooltoBooleanObj(left == right)
 	case operator == "!=":
 		return nativeBooltoBooleanObj(left != right)
 	case left.Type() != right.Type():
-		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+		return newError(object.TYPE_ERROR, "type mismatch: %s %s %s", left.Type(), operator, right.Type())
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newError(object.TYPE_ERROR, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
 
-func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
-	if operator != "+" {
-		return newError("unknown operator: %s%s%s", left.Type(), operator, right.Type())
+// evalLogicalExpression evaluates && and ||. The right operand is only
+// evaluated when the left one does not decide the result, which is always
+// true or false.
+func evalLogicalExpression(node *ast.InfixExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+	if IsTruthy(left) == (node.Operator == "||") {
+		return nativeBooltoBooleanObj(IsTruthy(left))
+	}
+	right := Eval(node.Right, env)
+	if isError(right) {
+		return right
 	}
+	return nativeBooltoBooleanObj(IsTruthy(right))
+}
 
+func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
 	leftVal := left.(*object.String).Value
 	rightVal := right.(*object.String).Value
-	return &object.String{Value: leftVal + rightVal}
+
+	switch operator {
+	case "+":
+		return &object.String{Value: leftVal + rightVal}
+	case "<":
+		return nativeBooltoBooleanObj(leftVal < rightVal)
+	case ">":
+		return nativeBooltoBooleanObj(leftVal > rightVal)
+	case "<=":
+		return nativeBooltoBooleanObj(leftVal <= rightVal)
+	case ">=":
+		return nativeBooltoBooleanObj(leftVal >= rightVal)
+	case "==":
+		return nativeBooltoBooleanObj(leftVal == rightVal)
+	case "!=":
+		return nativeBooltoBooleanObj(leftVal != rightVal)
+	default:
+		return newError(object.TYPE_ERROR, "unknown operator: %s%s%s", left.Type(), operator, right.Type())
+	}
 }
 
 func evalMultiplyString(operator string, left, right object.Object) object.Object {
 	if operator != "*" {
-		return newError("unknown operator: %s%s%s", left.Type(), operator, right.Type())
+		return newError(object.TYPE_ERROR, "unknown operator: %s%s%s", left.Type(), operator, right.Type())
 	}
 
 	leftVal := left.(*object.String).Value
 	rightVal := right.(*object.Integer).Value
-	resulting_string := leftVal
-	for i := 1; i < int(rightVal); i++ {
-		resulting_string += leftVal
+	if rightVal <= 1 {
+		return &object.String{Value: leftVal}
+	}
+	if len(leftVal) > 0 && rightVal > math.MaxInt32/int64(len(leftVal)) {
+		return newError(object.VALUE_ERROR, "`*` would make a string of %d times %d bytes", rightVal, len(leftVal))
 	}
-	return &object.String{Value: resulting_string}
+	return &object.String{Value: strings.Repeat(leftVal, int(rightVal))}
 }
 
 func evalIntegerInfixExpression(operator string, left, right object.Object) object.Object {
@@ -357,17 +855,78 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	case "*":
 		return &object.Integer{Value: leftVal * rightVal}
 	case "/":
+		if rightVal == 0 {
+			return newError(object.ZERO_DIVISION_ERROR, "division by zero")
+		}
 		return &object.Integer{Value: leftVal / rightVal}
+	case "%":
+		if rightVal == 0 {
+			return newError(object.ZERO_DIVISION_ERROR, "division by zero")
+		}
+		return &object.Integer{Value: leftVal % rightVal}
 	case "<":
 		return nativeBooltoBooleanObj(leftVal < rightVal)
 	case ">":
 		return nativeBooltoBooleanObj(leftVal > rightVal)
+	case "<=":
+		return nativeBooltoBooleanObj(leftVal <= rightVal)
+	case ">=":
+		return nativeBooltoBooleanObj(leftVal >= rightVal)
 	case "==":
 		return nativeBooltoBooleanObj(leftVal == rightVal)
 	case "!=":
 		return nativeBooltoBooleanObj(leftVal != rightVal)
 	default:
-		return newError("unknown operator: %s%s%s", left.Type(), operator, right.Type())
+		return newError(object.TYPE_ERROR, "unknown operator: %s%s%s", left.Type(), operator, right.Type())
+	}
+}
+
+// evalFloatInfixExpression handles float operands, promoting an integer on
+// either side to float first.
+func evalFloatInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := toFloat(left)
+	rightVal := toFloat(right)
+
+	switch operator {
+	case "-":
+		return &object.Float{Value: leftVal - rightVal}
+	case "+":
+		return &object.Float{Value: leftVal + rightVal}
+	case "*":
+		return &object.Float{Value: leftVal * rightVal}
+	case "/":
+		return &object.Float{Value: leftVal / rightVal}
+	case "%":
+		return &object.Float{Value: math.Mod(leftVal, rightVal)}
+	case "<":
+		return nativeBooltoBooleanObj(leftVal < rightVal)
+	case ">":
+		return nativeBooltoBooleanObj(leftVal > rightVal)
+	case "<=":
+		return nativeBooltoBooleanObj(leftVal <= rightVal)
+	case ">=":
+		return nativeBooltoBooleanObj(leftVal >= rightVal)
+	case "==":
+		return nativeBooltoBooleanObj(leftVal == rightVal)
+	case "!=":
+		return nativeBooltoBooleanObj(leftVal != rightVal)
+	default:
+		return newError(object.TYPE_ERROR, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.FLOAT_OBJ
+}
+
+func toFloat(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value)
+	case *object.Float:
+		return obj.Value
+	default:
+		return 0
 	}
 }
 
@@ -385,15 +944,18 @@ func evalBangOperator(right object.Object) object.Object {
 }
 
 func evalMinusOperator(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJ {
-		return newError("unknown operator: -%s", right.Type())
+	switch right := right.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: -right.Value}
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default:
+		return newError(object.TYPE_ERROR, "unknown operator: -%s", right.Type())
 	}
-	value := right.(*object.Integer).Value
-	return &object.Integer{Value: -value}
 }
 
-func newError(format string, a ...interface{}) *object.Error {
-	return &object.Error{Message: fmt.Sprintf(format, a...)}
+func newError(kind object.ErrorKind, format string, a ...interface{}) *object.Error {
+	return &object.Error{Kind: kind, Message: fmt.Sprintf(format, a...)}
 }
 
 func isError(obj object.Object) bool {
@@ -3,188 +3,15 @@ package evaluator_test
 import (
 	"testing"
 
+	"github.com/smiksha1701/buggy/enginetest"
 	"github.com/smiksha1701/buggy/evaluator"
 	"github.com/smiksha1701/buggy/lexer"
 	"github.com/smiksha1701/buggy/object"
 	"github.com/smiksha1701/buggy/parser"
 )
 
-func TestEvalIntegerExpression(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected int64
-	}{
-		{"5", 5},
-		{"10", 10},
-		{"-5", -5},
-		{"5+10", 15},
-		{"-5+10", 5},
-		{"-2*5-2", -12},
-		{"2*(3*3)", 18},
-		{"20+-5", 15},
-	}
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-		testIntegerObject(t, evaluated, tt.expected)
-	}
-}
-
-func TestStringLiteral(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected string
-	}{
-		{`"foobar"`, "foobar"},
-	}
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-		str, ok := evaluated.(*object.String)
-		if !ok {
-			t.Fatalf("object is not String. got=%q", evaluated)
-		}
-		if str.Value != "foobar" {
-			t.Errorf("String has wrong value. got=%q", str.Value)
-		}
-	}
-}
-
-func TestIfElseStatement(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected interface{}
-	}{
-		{"if (true) { 10 }", 10},
-		{"if (true) { 10 }", 10},
-		{"if (1) { 10 }", 10},
-		{"if (1 < 2) { 10 }", 10},
-		{"if (1 > 2) { 10 }", nil},
-		{"if (1 > 2) { 10 } else { 20 }", 20},
-		{"if (1 < 2) { 10 } else { 20 }", 10},
-	}
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-		integer, ok := tt.expected.(int)
-		if ok {
-			testIntegerObject(t, evaluated, int64(integer))
-		} else {
-			testNullObject(t, evaluated)
-		}
-	}
-}
-func TestEvalBooleanExpression(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected bool
-	}{
-		{"true", true},
-		{"false", false},
-		{"1>2", false},
-		{"1<2", true},
-		{"1<1", false},
-		{"1>1", false},
-		{"1!=1", false},
-		{"1==1", true},
-		{"1==2", false},
-		{"1!=2", true},
-		{"true==true", true},
-		{"false==false", true},
-		{"true!=false", true},
-		{"false!=true", true},
-		{"(1 < 2) == true", true},
-		{"(1 < 2) == false", false},
-		{"(1 > 2) == true", false},
-		{"(1 > 2) == false", true},
-	}
-	for idx, tt := range tests {
-		evaluated := testEval(tt.input)
-		testBooleanObject(t, evaluated, tt.expected, idx)
-	}
-}
-
-func TestBangOperator(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected bool
-	}{
-		{"!true", false},
-		{"!false", true},
-		{"!5", false},
-		{"!!false", false},
-		{"!!true", true},
-		{"!!false", false},
-		{"!!5", true},
-	}
-	for idx, tt := range tests {
-		evaluated := testEval(tt.input)
-		testBooleanObject(t, evaluated, tt.expected, idx)
-	}
-}
-
-func TestReturnStatements(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected int64
-	}{
-		{"return 10;", 10},
-		{"return 10; 9;", 10},
-		{"return 2 * 5; 9;", 10},
-		{"9; return 2*5; 9;", 10},
-	}
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-		testIntegerObject(t, evaluated, tt.expected)
-	}
-}
-
-func TestLetStatements(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected int64
-	}{
-		{"let a = 5; a;", 5},
-		{"let a = 5 * 5; a;", 25},
-		{"let a = 5; let b = a; b;", 5},
-		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
-	}
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-		testIntegerObject(t, evaluated, tt.expected)
-	}
-}
-
-func TestErrorHandling(t *testing.T) {
-	tests := []struct {
-		input           string
-		expectedMessage string
-	}{
-		{"5 + true;", "type mismatch: INTEGER + BOOLEAN"},
-		{"5 + true; 5;", "type mismatch: INTEGER + BOOLEAN"},
-		{"-true;", "unknown operator: -BOOLEAN"},
-		{"true-true;", "unknown operator: BOOLEAN - BOOLEAN"},
-		{"foobar;", "identifier not found: foobar"},
-		{"5; false + true; 5;", "unknown operator: BOOLEAN + BOOLEAN"},
-		{"if (10 > 1) { true + false; }", "unknown operator: BOOLEAN + BOOLEAN"},
-		{
-			`if (10 > 1) {
-				if (10 > 1) {
-					return true + false;
-				}
-
-				return 1;
-			}
-			`, "unknown operator: BOOLEAN + BOOLEAN"},
-	}
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-		errObj, ok := evaluated.(*object.Error)
-		if !ok {
-			t.Errorf("no error object returned. got=%T(%+v", evaluated, evaluated)
-			continue
-		}
-		if errObj.Message != tt.expectedMessage {
-			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, errObj.Message)
-		}
-	}
+func TestEngine(t *testing.T) {
+	enginetest.Run(t, testEvalWithBudget)
 }
 
 func TestFnHandling(t *testing.T) {
@@ -205,209 +32,16 @@ func TestFnHandling(t *testing.T) {
 		t.Fatalf("body is not %q. got=%q", expectedBody, FnObj.Body.String())
 	}
 }
-func TestArrayLiterals(t *testing.T) {
-	input := "[1, 2 * 2, 3 + 3]"
-	evaluated := testEval(input)
-	result, ok := evaluated.(*object.Array)
-	if !ok {
-		t.Errorf("no Array object returned. got=%T(%+v", evaluated, evaluated)
-	}
-	if len(result.Elements) != 3 {
-		t.Errorf("Array has wrong number of elements. Elements=%+v", result.Elements)
-	}
-
-	testIntegerObject(t, result.Elements[0], 1)
-	testIntegerObject(t, result.Elements[1], 4)
-	testIntegerObject(t, result.Elements[2], 6)
-}
 
-func TestHashIndexExpression(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected interface{}
-	}{
-		{
-			`{"foo": 5}["foo"]`,
-			5,
-		},
-		{
-			`{"foo": 5}["bar"]`,
-			nil,
-		},
-		{
-			`let key = "foo"; {"foo": 5}[key]`,
-			5,
-		},
-		{
-			`{}["foo"]`,
-			nil,
-		},
-		{
-			`{5: 5}[5]`,
-			5,
-		},
-		{
-			`{true: 5}[true]`,
-			5,
-		},
-		{
-			`{false: 5}[false]`,
-			5,
-		},
-	}
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-		integer, ok := tt.expected.(int)
-		if ok {
-			testIntegerObject(t, evaluated, int64(integer))
-		} else {
-			testNullObject(t, evaluated)
-		}
-	}
-}
-
-func TestHashLiterals(t *testing.T) {
-	input := `let two = "two";
-	{
-		"one": 10 - 9,
-		two: 1 + 1,
-		"thr" + "ee": 6 / 2,
-		4: 4,
-		true: 5,
-		false: 6
-	}
-	`
-	evaluated := testEval(input)
-	result, ok := evaluated.(*object.Hash)
-	if !ok {
-		t.Fatalf("no Array object returned. got=%T(%+v", evaluated, evaluated)
-	}
-	expected := map[object.HashKey]int64{
-		(&object.String{Value: "one"}).HashKey():   1,
-		(&object.String{Value: "two"}).HashKey():   2,
-		(&object.String{Value: "three"}).HashKey(): 3,
-		(&object.Integer{Value: 4}).HashKey():      4,
-		evaluator.TRUE.HashKey():                   5,
-		evaluator.FALSE.HashKey():                  6,
-	}
-	if len(result.Pairs) != len(expected) {
-		t.Fatalf("Hash has wrong num of pairs. got=%d", len(result.Pairs))
-	}
-	for expectedKey, expexpectedValue := range expected {
-		pair, ok := result.Pairs[expectedKey]
-		if !ok {
-			t.Errorf("no pair for given key in Pairs")
-		}
-		testIntegerObject(t, pair.Value, expexpectedValue)
-	}
-}
-func TestArrayIndexExpression(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected interface{}
-	}{
-		{"[1, 2, 3][0]", 1},
-		{"[1, 2, 3][1]", 2},
-		{"[1, 2, 3][2]", 3},
-		{"let i = 0; [1][i]", 1},
-		{"[1, 2, 3][1 + 1]", 3},
-		{"let myArray = [1, 2, 3]; myArray[2];", 3},
-	}
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-		integer, ok := tt.expected.(int)
-		if ok {
-			testIntegerObject(t, evaluated, int64(integer))
-		} else {
-			testNullObject(t, evaluated)
-		}
-	}
-}
-
-func TestBuiltinFunction(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected interface{}
-	}{
-		{`len("")`, 0},
-		{`len("four")`, 4},
-		{`len("hello world")`, 11},
-		{`len(1)`, "argument to `len` not supported, got INTEGER"},
-		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
-	}
-	for _, tt := range tests {
-		evaluated := testEval(tt.input)
-		switch expected := tt.expected.(type) {
-		case int:
-			testIntegerObject(t, evaluated, int64(expected))
-		case string:
-			errObj, ok := evaluated.(*object.Error)
-			if !ok {
-				t.Errorf("object is not Error. got=%T (%+v)", evaluated, evaluated)
-				continue
-			}
-			if errObj.Message != expected {
-				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
-			}
-		}
-	}
-}
-
-func TestFunctionApplication(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected int64
-	}{
-		{"let identity = fn(x) { x; }; identity(5);", 5},
-		{"let identity = fn(x) { return x; }; identity(5);", 5},
-		{"let double = fn(x) { x * 2; }; double(5);", 10},
-		{"let add = fn(x, y) { x + y; }; add(5, 5);", 10},
-		{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
-		{"fn(x) { x; }(5)", 5},
-	}
-	for _, tt := range tests {
-		testIntegerObject(t, testEval(tt.input), tt.expected)
-	}
+func testEval(input string) object.Object {
+	return testEvalWithBudget(input, nil)
 }
 
-func testEval(input string) object.Object {
+func testEvalWithBudget(input string, b *object.Budget) object.Object {
 	l := lexer.New(input)
 	p := parser.New(l)
 	program := p.ParseProgram()
 	env := object.NewEnvironment()
+	env.SetBudget(b)
 	return evaluator.Eval(program, env)
 }
-
-func testNullObject(t *testing.T, evaluated object.Object) bool {
-	if evaluated != evaluator.NULL {
-		t.Errorf("object is not NULL, got =%T (%+v)", evaluated, evaluated)
-		return false
-	}
-	return true
-}
-
-func testIntegerObject(t *testing.T, obj object.Object, tt int64) bool {
-	result, ok := obj.(*object.Integer)
-	if !ok {
-		t.Errorf("object is not integer. got=%T (%+v)", obj, obj)
-		return false
-	}
-	if result.Value != tt {
-		t.Errorf("object has wrong value. got=%d expected=%d ", result.Value, tt)
-		return false
-	}
-	return true
-}
-
-func testBooleanObject(t *testing.T, obj object.Object, tt bool, testIdx int) bool {
-	result, ok := obj.(*object.Boolean)
-	if !ok {
-		t.Errorf("TEST %d object is not boolean. got=%T (%+v)", testIdx+1, obj, obj)
-		return false
-	}
-	if result.Value != tt {
-		t.Errorf("TEST %d object has wrong value. got=%t expected=%t ", testIdx+1, result.Value, tt)
-		return false
-	}
-	return true
-}
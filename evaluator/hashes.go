@@ -0,0 +1,95 @@
+package evaluator
+
+import "github.com/smiksha1701/buggy/object"
+
+// hashKey returns the key obj is stored under in a hash, failing for
+// objects that can't be keys.
+func hashKey(obj object.Object) (object.HashKey, *object.Error) {
+	hashable, ok := obj.(object.Hashable)
+	if !ok {
+		return object.HashKey{}, newError(object.TYPE_ERROR, "unusable as hash key: %s", obj.Type())
+	}
+	return hashable.HashKey(), nil
+}
+
+// hashBuiltins list the pairs of hashes in the order their keys were
+// added. Like `push`, those that change a hash return a new one and leave
+// the hash they are given alone.
+var hashBuiltins = map[string]*object.Builtin{
+	"keys": {Fn: func(args ...object.Object) object.Object {
+		if err := checkArgs("keys", args, 1, object.HASH_OBJ); err != nil {
+			return err
+		}
+		pairs := args[0].(*object.Hash).OrderedPairs()
+		keys := make([]object.Object, len(pairs))
+		for i, pair := range pairs {
+			keys[i] = pair.Key
+		}
+		return &object.Array{Elements: keys}
+	}},
+	"values": {Fn: func(args ...object.Object) object.Object {
+		if err := checkArgs("values", args, 1, object.HASH_OBJ); err != nil {
+			return err
+		}
+		pairs := args[0].(*object.Hash).OrderedPairs()
+		values := make([]object.Object, len(pairs))
+		for i, pair := range pairs {
+			values[i] = pair.Value
+		}
+		return &object.Array{Elements: values}
+	}},
+	"has": {Fn: func(args ...object.Object) object.Object {
+		if err := checkArgs("has", args, 2, object.HASH_OBJ, anyType); err != nil {
+			return err
+		}
+		key, err := hashKey(args[1])
+		if err != nil {
+			return err
+		}
+		_, ok := args[0].(*object.Hash).Pairs[key]
+		return nativeBooltoBooleanObj(ok)
+	}},
+	"delete": {Fn: func(args ...object.Object) object.Object {
+		if err := checkArgs("delete", args, 2, object.HASH_OBJ, anyType); err != nil {
+			return err
+		}
+		key, err := hashKey(args[1])
+		if err != nil {
+			return err
+		}
+		hash := args[0].(*object.Hash).Copy()
+		hash.Delete(key)
+		return hash
+	}},
+	"set": {Fn: func(args ...object.Object) object.Object {
+		if err := checkArgs("set", args, 3, object.HASH_OBJ, anyType, anyType); err != nil {
+			return err
+		}
+		key, err := hashKey(args[1])
+		if err != nil {
+			return err
+		}
+		hash := args[0].(*object.Hash).Copy()
+		hash.Set(key, object.HashPair{Key: args[1], Value: args[2]})
+		return hash
+	}},
+	"merge": {Fn: func(args ...object.Object) object.Object {
+		if len(args) == 0 {
+			return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=0, want>=1")
+		}
+		merged := object.NewHash()
+		for _, arg := range args {
+			hash, ok := arg.(*object.Hash)
+			if !ok {
+				return newError(object.TYPE_ERROR, "argument to `merge` not supported, got %s", arg.Type())
+			}
+			// Later hashes win, but a key keeps the place it had in the
+			// first hash that has it.
+			for _, pair := range hash.OrderedPairs() {
+				key, _ := hashKey(pair.Key)
+				merged.Set(key, pair)
+			}
+		}
+		return merged
+	}},
+}
@@ -0,0 +1,209 @@
+package evaluator
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/smiksha1701/buggy/object"
+)
+
+// jsonParse is the `json_parse` builtin. Objects become hashes with string
+// keys, whole numbers integers and other numbers floats.
+func jsonParse(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1", len(args))
+	}
+	source, ok := args[0].(*object.String)
+	if !ok {
+		return newError(object.TYPE_ERROR, "argument to `json_parse` not supported, got %s", args[0].Type())
+	}
+	dec := json.NewDecoder(strings.NewReader(source.Value))
+	dec.UseNumber()
+	value, err := decodeJSON(dec)
+	if err == nil {
+		if _, err = dec.Token(); err == io.EOF {
+			return value
+		}
+		if err == nil {
+			err = errors.New("unexpected data after the top-level value")
+		}
+	}
+	if err == io.EOF {
+		err = errors.New("unexpected end of JSON input")
+	}
+	return newError(object.VALUE_ERROR, "invalid JSON at offset %d: %s", dec.InputOffset(), err)
+}
+
+func decodeJSON(dec *json.Decoder) (object.Object, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok := tok.(type) {
+	case nil:
+		return NULL, nil
+	case bool:
+		return nativeBooltoBooleanObj(tok), nil
+	case string:
+		return &object.String{Value: tok}, nil
+	case json.Number:
+		if i, err := tok.Int64(); err == nil {
+			return &object.Integer{Value: i}, nil
+		}
+		f, err := tok.Float64()
+		if err != nil {
+			return nil, err
+		}
+		return &object.Float{Value: f}, nil
+	case json.Delim:
+		if tok == '[' {
+			arr := &object.Array{Elements: []object.Object{}}
+			for dec.More() {
+				element, err := decodeJSON(dec)
+				if err != nil {
+					return nil, err
+				}
+				arr.Elements = append(arr.Elements, element)
+			}
+			_, err := dec.Token()
+			return arr, err
+		}
+		hash := object.NewHash()
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key := &object.String{Value: keyTok.(string)}
+			value, err := decodeJSON(dec)
+			if err != nil {
+				return nil, err
+			}
+			hash.Set(key.HashKey(), object.HashPair{Key: key, Value: value})
+		}
+		_, err := dec.Token()
+		return hash, err
+	}
+	return nil, errors.New("unexpected token")
+}
+
+// jsonStringify is the `json_stringify` builtin. Its optional second
+// argument is the number of spaces, or the string, to indent nested values
+// with. Without it the output is on one line.
+func jsonStringify(args ...object.Object) object.Object {
+	if len(args) != 1 && len(args) != 2 {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=1 or 2", len(args))
+	}
+	indent := ""
+	if len(args) == 2 {
+		switch arg := args[1].(type) {
+		case *object.Integer:
+			if arg.Value < 0 || arg.Value > 10 {
+				return newError(object.VALUE_ERROR, "indent must be between 0 and 10, got %d", arg.Value)
+			}
+			indent = strings.Repeat(" ", int(arg.Value))
+		case *object.String:
+			indent = arg.Value
+		default:
+			return newError(object.TYPE_ERROR, "indent for `json_stringify` not supported, got %s", arg.Type())
+		}
+	}
+	e := &jsonEncoder{active: map[object.Object]bool{}}
+	if err := e.encode(args[0]); err != nil {
+		return err
+	}
+	if indent == "" {
+		return &object.String{Value: e.out.String()}
+	}
+	var out bytes.Buffer
+	json.Indent(&out, e.out.Bytes(), "", indent)
+	return &object.String{Value: out.String()}
+}
+
+type jsonEncoder struct {
+	out bytes.Buffer
+	// active holds the arrays and hashes being encoded, to catch those
+	// that contain themselves.
+	active map[object.Object]bool
+}
+
+func (e *jsonEncoder) encode(obj object.Object) *object.Error {
+	switch obj := obj.(type) {
+	case *object.Null:
+		e.out.WriteString("null")
+	case *object.Boolean:
+		e.out.WriteString(strconv.FormatBool(obj.Value))
+	case *object.Integer:
+		e.out.WriteString(strconv.FormatInt(obj.Value, 10))
+	case *object.Float:
+		if math.IsNaN(obj.Value) || math.IsInf(obj.Value, 0) {
+			return newError(object.VALUE_ERROR, "cannot convert %s to JSON", obj.Inspect())
+		}
+		// Inspect keeps a decimal point, so the value stays a float when
+		// parsed again.
+		e.out.WriteString(obj.Inspect())
+	case *object.String:
+		e.writeString(obj.Value)
+	case *object.Array:
+		if err := e.enter(obj); err != nil {
+			return err
+		}
+		e.out.WriteByte('[')
+		for i, element := range obj.Elements {
+			if i > 0 {
+				e.out.WriteByte(',')
+			}
+			if err := e.encode(element); err != nil {
+				return err
+			}
+		}
+		e.out.WriteByte(']')
+		delete(e.active, obj)
+	case *object.Hash:
+		if err := e.enter(obj); err != nil {
+			return err
+		}
+		e.out.WriteByte('{')
+		for i, pair := range obj.OrderedPairs() {
+			key, ok := pair.Key.(*object.String)
+			if !ok {
+				return newError(object.TYPE_ERROR, "cannot convert hash key %s to JSON: keys must be strings, got %s",
+					pair.Key.Inspect(), pair.Key.Type())
+			}
+			if i > 0 {
+				e.out.WriteByte(',')
+			}
+			e.writeString(key.Value)
+			e.out.WriteByte(':')
+			if err := e.encode(pair.Value); err != nil {
+				return err
+			}
+		}
+		e.out.WriteByte('}')
+		delete(e.active, obj)
+	default:
+		return newError(object.TYPE_ERROR, "cannot convert %s to JSON", obj.Type())
+	}
+	return nil
+}
+
+func (e *jsonEncoder) enter(obj object.Object) *object.Error {
+	if e.active[obj] {
+		return newError(object.VALUE_ERROR, "cannot convert %s to JSON: it contains itself", obj.Type())
+	}
+	e.active[obj] = true
+	return nil
+}
+
+func (e *jsonEncoder) writeString(s string) {
+	enc := json.NewEncoder(&e.out)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	// Encode ends every value with a newline.
+	e.out.Truncate(e.out.Len() - 1)
+}
@@ -0,0 +1,123 @@
+package evaluator
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/smiksha1701/buggy/ast"
+	"github.com/smiksha1701/buggy/lexer"
+	"github.com/smiksha1701/buggy/object"
+	"github.com/smiksha1701/buggy/parser"
+	"github.com/smiksha1701/buggy/token"
+)
+
+// ResolveImport returns the file that `import path` names in code at pos.
+// A relative path is relative to the directory of the file pos is in, or to
+// the working directory for code that does not come from a file.
+func ResolveImport(pos token.Position, path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(filepath.Dir(pos.Filename), path)
+}
+
+// ParseModule reads and parses the file at path for an import. It is
+// exported for the vm.
+func ParseModule(path string) (*ast.Program, *object.Error) {
+	source, err := os.ReadFile(path)
+	if err != nil {
+		var pathErr *os.PathError
+		if errors.As(err, &pathErr) {
+			err = pathErr.Err
+		}
+		return nil, newError(object.IMPORT_ERROR, "cannot import %s: %s", path, err)
+	}
+	p := parser.New(lexer.NewFile(path, string(source)))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		err := newError(object.IMPORT_ERROR, "cannot import %s: %s", path, errs[0])
+		if len(errs) > 1 {
+			err.Message += " (and more)"
+		}
+		return nil, err
+	}
+	return program, nil
+}
+
+// ExportNames lists the names the export statements of program declare.
+func ExportNames(program *ast.Program) []string {
+	names := []string{}
+	for _, statement := range program.Statements {
+		if export, ok := statement.(*ast.ExportStatement); ok {
+			names = append(names, export.Statement.Name.Value)
+		}
+	}
+	return names
+}
+
+// ModuleName is how the code at the top level of the file at path shows up
+// in error stacks.
+func ModuleName(path string) string {
+	return "<module " + path + ">"
+}
+
+// EvalMemberExpression reads the export name of a module. It is exported
+// for the vm.
+func EvalMemberExpression(left object.Object, name string) object.Object {
+	module, ok := left.(*object.Module)
+	if !ok {
+		return newError(object.TYPE_ERROR, "member access not supported: %s.%s", left.Type(), name)
+	}
+	value, ok := module.Get(name)
+	if !ok {
+		return newError(object.NAME_ERROR, "module %s does not export %s", module.Path, name)
+	}
+	return value
+}
+
+// evalImportStatement binds the module in the file the statement names.
+// The file runs the first time code sharing the run of env imports it,
+// and later imports get the same module.
+func evalImportStatement(node *ast.ImportStatement, env *object.Environment) object.Object {
+	module := importModule(env, ResolveImport(node.Pos(), node.Path.Value), node.Pos())
+	if isError(module) {
+		return module
+	}
+	env.Set(node.Name.Value, module)
+	return nil
+}
+
+func importModule(env *object.Environment, path string, pos token.Position) object.Object {
+	modules := env.Modules()
+	if module, ok := modules.Get(path); ok {
+		return module
+	}
+	if err := modules.Begin(path); err != nil {
+		return err
+	}
+	var module *object.Module
+	defer func() { modules.End(path, module) }()
+
+	program, err := ParseModule(path)
+	if err != nil {
+		return err
+	}
+	// Running a module counts as a call, so imports can't nest deeper than
+	// calls can.
+	b := env.Budget()
+	if err := b.Enter(); err != nil {
+		return err
+	}
+	defer b.Leave()
+	moduleEnv := object.NewModuleEnv(env)
+	if errObj, ok := Eval(program, moduleEnv).(*object.Error); ok {
+		errObj.Stack = append(errObj.Stack, object.StackFrame{Function: ModuleName(path), Pos: pos})
+		return errObj
+	}
+	module = &object.Module{Path: path, Exports: map[string]bool{}, Globals: moduleEnv}
+	for _, name := range ExportNames(program) {
+		module.Exports[name] = true
+	}
+	return module
+}
@@ -0,0 +1,279 @@
+package evaluator
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/smiksha1701/buggy/object"
+)
+
+// anyType accepts arguments of every type in checkArgs.
+const anyType object.ObjectType = ""
+
+// checkArgs checks the arguments of the builtin name: there must be at
+// least min of them and at most one for each of types, and each must have
+// the type listed for its place.
+func checkArgs(name string, args []object.Object, min int, types ...object.ObjectType) *object.Error {
+	if len(args) < min || len(args) > len(types) {
+		return wrongArgCount(len(args), min, len(types))
+	}
+	for i, arg := range args {
+		if types[i] != anyType && arg.Type() != types[i] {
+			return newError(object.TYPE_ERROR, "argument to `%s` not supported, got %s", name, arg.Type())
+		}
+	}
+	return nil
+}
+
+func wrongArgCount(got, min, max int) *object.Error {
+	switch {
+	case min == max:
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=%d", got, min)
+	case max == min+1:
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=%d or %d", got, min, max)
+	}
+	return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=%d, want=%d to %d", got, min, max)
+}
+
+func stringValue(obj object.Object) string { return obj.(*object.String).Value }
+
+func integerValue(obj object.Object) int64 { return obj.(*object.Integer).Value }
+
+func newString(s string) object.Object { return &object.String{Value: s} }
+
+// stringArray makes an array of strings, as returned by `split` and
+// `chars`.
+func stringArray(values []string) *object.Array {
+	elements := make([]object.Object, len(values))
+	for i, value := range values {
+		elements[i] = newString(value)
+	}
+	return &object.Array{Elements: elements}
+}
+
+// stringBuiltins work on the characters of strings, not their bytes, like
+// indexing and `len` do.
+var stringBuiltins = map[string]*object.Builtin{
+	"split": {Fn: func(args ...object.Object) object.Object {
+		if err := checkArgs("split", args, 1, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+			return err
+		}
+		if len(args) == 1 {
+			return stringArray(strings.Fields(stringValue(args[0])))
+		}
+		return stringArray(strings.Split(stringValue(args[0]), stringValue(args[1])))
+	}},
+	"join": {Fn: func(args ...object.Object) object.Object {
+		if err := checkArgs("join", args, 1, object.ARRAY_OBJ, object.STRING_OBJ); err != nil {
+			return err
+		}
+		elements := args[0].(*object.Array).Elements
+		parts := make([]string, len(elements))
+		for i, element := range elements {
+			str, ok := element.(*object.String)
+			if !ok {
+				return newError(object.TYPE_ERROR, "element %d of the array given to `join` must be STRING, got %s", i, element.Type())
+			}
+			parts[i] = str.Value
+		}
+		sep := ""
+		if len(args) == 2 {
+			sep = stringValue(args[1])
+		}
+		return newString(strings.Join(parts, sep))
+	}},
+	"trim": {Fn: func(args ...object.Object) object.Object {
+		if err := checkArgs("trim", args, 1, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+			return err
+		}
+		if len(args) == 1 {
+			return newString(strings.TrimSpace(stringValue(args[0])))
+		}
+		return newString(strings.Trim(stringValue(args[0]), stringValue(args[1])))
+	}},
+	"upper": {Fn: func(args ...object.Object) object.Object {
+		if err := checkArgs("upper", args, 1, object.STRING_OBJ); err != nil {
+			return err
+		}
+		return newString(strings.ToUpper(stringValue(args[0])))
+	}},
+	"lower": {Fn: func(args ...object.Object) object.Object {
+		if err := checkArgs("lower", args, 1, object.STRING_OBJ); err != nil {
+			return err
+		}
+		return newString(strings.ToLower(stringValue(args[0])))
+	}},
+	"replace": {Fn: func(args ...object.Object) object.Object {
+		err := checkArgs("replace", args, 3, object.STRING_OBJ, object.STRING_OBJ, object.STRING_OBJ, object.INTEGER_OBJ)
+		if err != nil {
+			return err
+		}
+		n := -1
+		if len(args) == 4 {
+			n = int(integerValue(args[3]))
+		}
+		return newString(strings.Replace(stringValue(args[0]), stringValue(args[1]), stringValue(args[2]), n))
+	}},
+	"contains": {Fn: func(args ...object.Object) object.Object {
+		if err := checkArgs("contains", args, 2, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+			return err
+		}
+		return nativeBooltoBooleanObj(strings.Contains(stringValue(args[0]), stringValue(args[1])))
+	}},
+	"starts_with": {Fn: func(args ...object.Object) object.Object {
+		if err := checkArgs("starts_with", args, 2, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+			return err
+		}
+		return nativeBooltoBooleanObj(strings.HasPrefix(stringValue(args[0]), stringValue(args[1])))
+	}},
+	"ends_with": {Fn: func(args ...object.Object) object.Object {
+		if err := checkArgs("ends_with", args, 2, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+			return err
+		}
+		return nativeBooltoBooleanObj(strings.HasSuffix(stringValue(args[0]), stringValue(args[1])))
+	}},
+	"index_of": {Fn: func(args ...object.Object) object.Object {
+		if err := checkArgs("index_of", args, 2, object.STRING_OBJ, object.STRING_OBJ); err != nil {
+			return err
+		}
+		s := stringValue(args[0])
+		i := strings.Index(s, stringValue(args[1]))
+		if i < 0 {
+			return &object.Integer{Value: -1}
+		}
+		return &object.Integer{Value: int64(utf8.RuneCountInString(s[:i]))}
+	}},
+	"substr": {Fn: func(args ...object.Object) object.Object {
+		err := checkArgs("substr", args, 2, object.STRING_OBJ, object.INTEGER_OBJ, object.INTEGER_OBJ)
+		if err != nil {
+			return err
+		}
+		chars := []rune(stringValue(args[0]))
+		start := integerValue(args[1])
+		if start < 0 {
+			start += int64(len(chars))
+		}
+		if start < 0 {
+			start = 0
+		}
+		if start > int64(len(chars)) {
+			start = int64(len(chars))
+		}
+		end := int64(len(chars))
+		if len(args) == 3 {
+			length := integerValue(args[2])
+			if length < 0 {
+				return newError(object.VALUE_ERROR, "length given to `substr` must not be negative, got %d", length)
+			}
+			if length < end-start {
+				end = start + length
+			}
+		}
+		return newString(string(chars[start:end]))
+	}},
+	"chars": {Fn: func(args ...object.Object) object.Object {
+		if err := checkArgs("chars", args, 1, object.STRING_OBJ); err != nil {
+			return err
+		}
+		s := stringValue(args[0])
+		chars := make([]string, 0, utf8.RuneCountInString(s))
+		for _, c := range s {
+			chars = append(chars, string(c))
+		}
+		return stringArray(chars)
+	}},
+	"repeat": {RuntimeFn: func(rt object.Runtime, args ...object.Object) object.Object {
+		if err := checkArgs("repeat", args, 2, object.STRING_OBJ, object.INTEGER_OBJ); err != nil {
+			return err
+		}
+		s, n := stringValue(args[0]), integerValue(args[1])
+		if n < 0 {
+			return newError(object.VALUE_ERROR, "count given to `repeat` must not be negative, got %d", n)
+		}
+		if len(s) > 0 && n > math.MaxInt32/int64(len(s)) {
+			return newError(object.VALUE_ERROR, "`repeat` would make a string of %d times %d bytes", n, len(s))
+		}
+		if err := rt.Budget().CheckString(int64(len(s)) * n); err != nil {
+			return err
+		}
+		return newString(strings.Repeat(s, int(n)))
+	}},
+	"format": {Fn: func(args ...object.Object) object.Object {
+		if len(args) == 0 {
+			return newError(object.ARGUMENT_ERROR, "wrong number of arguments. got=0, want>=1")
+		}
+		if err := checkArgs("format", args[:1], 1, object.STRING_OBJ); err != nil {
+			return err
+		}
+		return format(stringValue(args[0]), args[1:])
+	}},
+}
+
+// format is the `format` builtin, which works like Go's fmt.Sprintf. Each
+// verb must get an argument it can show: %d, %x, %X, %o, %b and %c an
+// integer, %f, %e, %g and their capitals a number, %t a boolean, and %s, %q
+// and %v anything, showing it as `say` would.
+func format(template string, args []object.Object) object.Object {
+	var out strings.Builder
+	next := 0
+	for i := 0; i < len(template); i++ {
+		if template[i] != '%' {
+			out.WriteByte(template[i])
+			continue
+		}
+		start := i
+		i++
+		for i < len(template) && strings.IndexByte("+-# 0123456789.", template[i]) >= 0 {
+			i++
+		}
+		if i == len(template) {
+			return newError(object.VALUE_ERROR, "format: incomplete verb %s at the end", template[start:])
+		}
+		verb := template[i]
+		spec := template[start : i+1]
+		if verb == '%' {
+			out.WriteByte('%')
+			continue
+		}
+		if next == len(args) {
+			return newError(object.ARGUMENT_ERROR, "format: missing argument for %s", spec)
+		}
+		value, err := formatValue(spec, verb, args[next])
+		if err != nil {
+			return err
+		}
+		out.WriteString(fmt.Sprintf(spec, value))
+		next++
+	}
+	if next < len(args) {
+		return newError(object.ARGUMENT_ERROR, "format: %d arguments left over", len(args)-next)
+	}
+	return newString(out.String())
+}
+
+func formatValue(spec string, verb byte, arg object.Object) (interface{}, *object.Error) {
+	switch verb {
+	case 'd', 'x', 'X', 'o', 'b', 'c':
+		if i, ok := arg.(*object.Integer); ok {
+			return i.Value, nil
+		}
+	case 'f', 'F', 'e', 'E', 'g', 'G':
+		switch n := arg.(type) {
+		case *object.Float:
+			return n.Value, nil
+		case *object.Integer:
+			return float64(n.Value), nil
+		}
+	case 't':
+		if b, ok := arg.(*object.Boolean); ok {
+			return b.Value, nil
+		}
+	case 's', 'q', 'v':
+		return arg.Inspect(), nil
+	default:
+		return nil, newError(object.VALUE_ERROR, "format: unknown verb %s", spec)
+	}
+	return nil, newError(object.TYPE_ERROR, "format: %s does not take %s", spec, arg.Type())
+}
@@ -0,0 +1,150 @@
+package evaluator
+
+import (
+	"github.com/smiksha1701/buggy/ast"
+	"github.com/smiksha1701/buggy/object"
+	"github.com/smiksha1701/buggy/token"
+)
+
+const TAIL_CALL_OBJ = "TAIL_CALL"
+
+// tailCall is a call in tail position that has been evaluated up to, but
+// not including, calling the function. It is handed back to applyFunction,
+// which makes the call in place of the function that returned it.
+type tailCall struct {
+	function object.Object
+	args     []object.Object
+	pos      token.Position
+}
+
+func (tc *tailCall) Type() object.ObjectType { return TAIL_CALL_OBJ }
+func (tc *tailCall) Inspect() string         { return "tail call" }
+
+// evalTailBlock evaluates the body of a function, or a branch of an if
+// expression that ends one, whose last statement is in tail position.
+func evalTailBlock(block *ast.BlockStatement, env *object.Environment) object.Object {
+	var result object.Object
+	for i, statement := range block.Statements {
+		if i == len(block.Statements)-1 {
+			return evalTailStatement(statement, env)
+		}
+		result = Eval(statement, env)
+		if isAbrupt(result) {
+			return result
+		}
+	}
+	return result
+}
+
+func evalTailStatement(statement ast.Statement, env *object.Environment) object.Object {
+	if stmt, ok := statement.(*ast.ExpressionStatement); ok {
+		return evalTailExpression(stmt.Expression, env)
+	}
+	return Eval(statement, env)
+}
+
+// evalTailExpression evaluates an expression whose value a function
+// returns. A call is not made but returned as a *tailCall, and the branches
+// of an if expression are in tail position in turn.
+func evalTailExpression(node ast.Expression, env *object.Environment) object.Object {
+	switch node := node.(type) {
+	case *ast.CallExpression:
+		function := Eval(node.Function, env)
+		if isError(function) {
+			return function
+		}
+		args := evalExpressions(node.Arguments, env)
+		if len(args) == 1 && isError(args[0]) {
+			return args[0]
+		}
+		return &tailCall{function: function, args: args, pos: node.Pos()}
+
+	case *ast.IfExpression:
+		condition := Eval(node.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+		var result object.Object
+		if IsTruthy(condition) {
+			result = evalTailBlock(node.Consequence, env)
+		} else if node.Alternative != nil {
+			result = evalTailBlock(node.Alternative, env)
+		}
+		if result == nil {
+			return NULL
+		}
+		return result
+
+	default:
+		return Eval(node, env)
+	}
+}
+
+// forceTailCall makes the call of a return statement that was left for the
+// function to make, for code that has to see its outcome first: the top
+// level of a program and try blocks. An error from the call is returned
+// as it is so that it can be caught.
+func forceTailCall(env *object.Environment, obj object.Object) object.Object {
+	returnValue, ok := obj.(*object.ReturnValue)
+	if !ok {
+		return obj
+	}
+	call, ok := returnValue.Value.(*tailCall)
+	if !ok {
+		return obj
+	}
+	result := applyFunction(env.Budget(), call.function, call.args, call.pos)
+	if isError(result) {
+		return result
+	}
+	return &object.ReturnValue{Value: result}
+}
+
+// tailFramesKept is how many of the functions replaced by tail calls are
+// kept at each end of a chain for error stacks.
+const tailFramesKept = 100
+
+// TailFrames records the functions that tail calls replaced, outermost
+// first, so that errors list them as if the calls had been made normally.
+// A chain of any length takes bounded memory: only its ends are kept, and
+// the frames between them are counted. The vm records its tail calls in
+// one too, so both engines report the same stacks.
+type TailFrames struct {
+	head   []object.StackFrame
+	tail   []object.StackFrame
+	elided int
+	// elidedPos is the position of the call of the outermost elided frame.
+	elidedPos token.Position
+}
+
+// Push records frame as replaced by a tail call.
+func (tf *TailFrames) Push(frame object.StackFrame) {
+	if len(tf.head) < tailFramesKept {
+		tf.head = append(tf.head, frame)
+		return
+	}
+	if len(tf.tail) == 2*tailFramesKept {
+		if tf.elided == 0 {
+			tf.elidedPos = tf.tail[0].Pos
+		}
+		tf.elided += tailFramesKept
+		tf.tail = append(tf.tail[:0], tf.tail[tailFramesKept:]...)
+	}
+	tf.tail = append(tf.tail, frame)
+}
+
+func (tf *TailFrames) Empty() bool { return len(tf.head) == 0 }
+
+// AppendTo appends the recorded frames to stack, innermost first.
+func (tf *TailFrames) AppendTo(stack []object.StackFrame) []object.StackFrame {
+	for i := len(tf.tail) - 1; i >= 0; i-- {
+		stack = append(stack, tf.tail[i])
+	}
+	if tf.elided > 0 {
+		stack = append(stack, object.StackFrame{Pos: tf.elidedPos, Elided: tf.elided})
+	}
+	for i := len(tf.head) - 1; i >= 0; i-- {
+		stack = append(stack, tf.head[i])
+	}
+	return stack
+}
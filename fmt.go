@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/smiksha1701/buggy/format"
+)
+
+const fmtUsage = "usage: buggy fmt [-w | -check] path/to/script.bg...\n"
+
+// fmtCommand formats Buggy source files. By default the formatted source is
+// written to standard output; -w writes it back to the files instead and
+// -check only lists the files that are not formatted.
+func fmtCommand(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	flags.Usage = func() { io.WriteString(os.Stderr, fmtUsage) }
+	write := flags.Bool("w", false, "write the result to the files instead of standard output")
+	check := flags.Bool("check", false, "list the files that are not formatted and fail if there are any")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	paths := flags.Args()
+	if len(paths) == 0 || (*write && *check) {
+		io.WriteString(os.Stderr, fmtUsage)
+		return 2
+	}
+
+	status := 0
+	for _, path := range paths {
+		source, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "buggy: %s\n", err)
+			status = 1
+			continue
+		}
+		formatted, err := format.Source(path, source)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+			continue
+		}
+		switch {
+		case *check:
+			if !bytes.Equal(source, formatted) {
+				fmt.Println(path)
+				status = 1
+			}
+		case *write:
+			if bytes.Equal(source, formatted) {
+				continue
+			}
+			if err := os.WriteFile(path, formatted, 0o644); err != nil {
+				fmt.Fprintf(os.Stderr, "buggy: %s\n", err)
+				status = 1
+			}
+		default:
+			os.Stdout.Write(formatted)
+		}
+	}
+	return status
+}
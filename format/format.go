@@ -0,0 +1,470 @@
+// Package format prints Buggy programs in a canonical layout: one statement
+// per line, blocks indented by a tab, single spaces around operators and no
+// more parentheses than the parser needs. Comments, also those inside
+// expressions, and single blank lines between statements are kept.
+package format
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	"github.com/smiksha1701/buggy/ast"
+	"github.com/smiksha1701/buggy/lexer"
+	"github.com/smiksha1701/buggy/parser"
+	"github.com/smiksha1701/buggy/token"
+)
+
+// SyntaxError is returned for source that does not parse. It holds every
+// error the parser found.
+type SyntaxError struct {
+	Errors []*parser.Error
+}
+
+func (e *SyntaxError) Error() string {
+	lines := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		lines[i] = err.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Source formats the Buggy program in src. filename is only used in the
+// positions of syntax errors.
+func Source(filename string, src []byte) ([]byte, error) {
+	p := parser.New(lexer.NewFile(filename, string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		return nil, &SyntaxError{Errors: p.Errors()}
+	}
+
+	pr := newPrinter(filename, string(src))
+	pr.statements(program.Statements, false)
+	pr.flushComments(len(src) + 1)
+	if pr.out.Len() > 0 {
+		pr.out.WriteByte('\n')
+	}
+	return pr.out.Bytes(), nil
+}
+
+const indentation = "\t"
+
+type printer struct {
+	src string
+	out bytes.Buffer
+	// tokens holds every token of src, comments included, and comments the
+	// comments that have not been printed yet.
+	tokens   []token.Token
+	comments []token.Token
+	// trailing tells which comments start on the line another token ends
+	// on, by offset.
+	trailing map[int]bool
+	// closers maps the offset of each opening bracket to the position of
+	// the bracket closing it.
+	closers map[int]token.Position
+	// brackets maps the offset of each comment inside brackets to the
+	// offset of the innermost opening bracket around it.
+	brackets map[int]int
+
+	indent      int
+	atLineStart bool
+	// first is set at the start of a block, where blank lines are dropped.
+	first bool
+}
+
+func newPrinter(filename, src string) *printer {
+	p := &printer{
+		src:      src,
+		trailing: map[int]bool{},
+		closers:  map[int]token.Position{},
+		brackets: map[int]int{},
+		first:    true,
+	}
+	l := lexer.NewFile(filename, src)
+	l.EmitComments = true
+	var open []token.Token
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.COMMENT:
+			if n := len(p.tokens); n > 0 && p.tokens[n-1].End.Line == tok.Pos.Line {
+				p.trailing[tok.Pos.Offset] = true
+			}
+			if n := len(open); n > 0 {
+				p.brackets[tok.Pos.Offset] = open[n-1].Pos.Offset
+			}
+			p.comments = append(p.comments, tok)
+		case token.LPAREN, token.LBRACKET, token.LBRACE:
+			open = append(open, tok)
+		case token.RPAREN, token.RBRACKET, token.RBRACE:
+			if n := len(open); n > 0 {
+				p.closers[open[n-1].Pos.Offset] = tok.Pos
+				open = open[:n-1]
+			}
+		}
+		p.tokens = append(p.tokens, tok)
+	}
+	return p
+}
+
+func (p *printer) write(s string) {
+	if p.atLineStart {
+		p.out.WriteString(strings.Repeat(indentation, p.indent))
+		p.atLineStart = false
+	}
+	p.out.WriteString(s)
+}
+
+// line starts a new line for what begins at pos, after an empty one if the
+// source has a blank line right before pos.
+func (p *printer) line(pos token.Position) {
+	if p.out.Len() > 0 {
+		p.out.WriteByte('\n')
+		if !p.first && p.blankBefore(pos) {
+			p.out.WriteByte('\n')
+		}
+	}
+	p.first = false
+	p.atLineStart = true
+}
+
+func (p *printer) blankBefore(pos token.Position) bool {
+	i := p.tokenIndex(pos.Offset)
+	return i > 0 && pos.Line > p.tokens[i-1].End.Line+1
+}
+
+// tokenIndex returns the index of the first token at or after offset.
+func (p *printer) tokenIndex(offset int) int {
+	return sort.Search(len(p.tokens), func(i int) bool { return p.tokens[i].Pos.Offset >= offset })
+}
+
+// flushComments prints the comments that come before offset. A comment
+// that follows code on its line stays at the end of the current line, the
+// others get lines of their own.
+func (p *printer) flushComments(offset int) {
+	for len(p.comments) > 0 && p.comments[0].Pos.Offset < offset {
+		c := p.comments[0]
+		p.comments = p.comments[1:]
+		text := strings.TrimRight(c.Literal, " \t\r")
+		if p.trailing[c.Pos.Offset] && p.out.Len() > 0 && !p.atLineStart {
+			p.write(" " + text)
+			continue
+		}
+		p.line(c.Pos)
+		p.write(text)
+	}
+}
+
+// inlineComments prints the comments before offset that sit inside an
+// expression, between the tokens they are between in the source. A comment
+// that ends its line ends the printed line too, and the expression goes on
+// in the next one, indented once more.
+func (p *printer) inlineComments(offset int) {
+	for len(p.comments) > 0 && p.comments[0].Pos.Offset < offset {
+		c := p.comments[0]
+		p.comments = p.comments[1:]
+		if out := p.out.Bytes(); !p.atLineStart && len(out) > 0 && !strings.ContainsRune(" \t\n([{", rune(out[len(out)-1])) {
+			p.write(" ")
+		}
+		p.write(strings.TrimRight(c.Literal, " \t\r"))
+		if i := p.tokenIndex(c.End.Offset); strings.HasPrefix(c.Literal, "//") || i < len(p.tokens) && p.tokens[i].Pos.Line > c.End.Line {
+			p.out.WriteString("\n" + strings.Repeat(indentation, p.indent+1))
+			continue
+		}
+		p.write(" ")
+	}
+}
+
+// hasCommentsIn tells whether there are comments directly inside the
+// brackets opened at offset, not nested in inner ones.
+func (p *printer) hasCommentsIn(offset int) bool {
+	for _, c := range p.comments {
+		if open, ok := p.brackets[c.Pos.Offset]; ok && open == offset {
+			return true
+		}
+	}
+	return false
+}
+
+// hasComments tells whether there are comments between the offsets from
+// and to.
+func (p *printer) hasComments(from, to int) bool {
+	for _, c := range p.comments {
+		if c.Pos.Offset > from && c.Pos.Offset < to {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *printer) statements(statements []ast.Statement, inBlock bool) {
+	for i, s := range statements {
+		pos := ast.Start(s)
+		p.flushComments(pos.Offset)
+		p.line(pos)
+		p.statement(s)
+		var next ast.Statement
+		if i+1 < len(statements) {
+			next = statements[i+1]
+		}
+		p.write(p.terminator(s, next, inBlock))
+	}
+}
+
+// terminator returns the semicolon that ends s, if it needs one. The value
+// of a block is its last expression, which is left without one, and so are
+// expressions ending in a block unless next would continue them.
+func (p *printer) terminator(s, next ast.Statement, inBlock bool) string {
+	switch s := s.(type) {
+	case *ast.WhileStatement, *ast.ForStatement:
+		return ""
+	case *ast.ExpressionStatement:
+		if next == nil && inBlock {
+			return ""
+		}
+		switch s.Expression.(type) {
+		case *ast.IfExpression, *ast.TryExpression, *ast.FunctionLiteral:
+			if next == nil || !p.continues(next) {
+				return ""
+			}
+		}
+	}
+	return ";"
+}
+
+// continues tells whether s starts with a token that would be read as an
+// infix operator if it directly followed an expression.
+func (p *printer) continues(s ast.Statement) bool {
+	i := p.tokenIndex(ast.Start(s).Offset)
+	return i < len(p.tokens) && parser.Precedence(p.tokens[i].Type) > parser.LOWEST
+}
+
+func (p *printer) statement(s ast.Statement) {
+	switch s := s.(type) {
+	case *ast.LetStatement:
+		p.write("let " + s.Name.Value + " = ")
+		p.expression(s.Value, parser.LOWEST)
+	case *ast.ExportStatement:
+		p.write("export ")
+		p.statement(s.Statement)
+	case *ast.ImportStatement:
+		p.write("import " + p.literal(s.Path.Token) + " as " + s.Name.Value)
+	case *ast.ReturnStatement:
+		p.write("return ")
+		p.expression(s.Return, parser.LOWEST)
+	case *ast.ThrowStatement:
+		p.write("throw ")
+		p.expression(s.Value, parser.LOWEST)
+	case *ast.AssignStatement:
+		p.expression(s.Target, parser.LOWEST)
+		p.write(" " + s.Operator + " ")
+		p.expression(s.Value, parser.LOWEST)
+	case *ast.ExpressionStatement:
+		p.expression(s.Expression, parser.LOWEST)
+	case *ast.WhileStatement:
+		p.write("while (")
+		p.expression(s.Condition, parser.LOWEST)
+		p.write(") ")
+		p.block(s.Body)
+	case *ast.ForStatement:
+		p.write("for (")
+		if s.Key != nil {
+			p.write(s.Key.Value + ", ")
+		}
+		p.write(s.Value.Value + " in ")
+		p.expression(s.Iterable, parser.LOWEST)
+		p.write(") ")
+		p.block(s.Body)
+	case *ast.BreakStatement:
+		p.write("break")
+	case *ast.ContinueStatement:
+		p.write("continue")
+	case *ast.BlockStatement:
+		p.block(s)
+	}
+}
+
+// block prints b on one line if it is on one line in the source and has no
+// comments inside, and with a statement per line otherwise.
+func (p *printer) block(b *ast.BlockStatement) {
+	open := b.Token.Pos
+	end := p.closers[open.Offset]
+	comments := p.hasComments(open.Offset, end.Offset)
+	switch {
+	case len(b.Statements) == 0 && !comments:
+		p.write("{}")
+		return
+	case end.Line == open.Line && !comments:
+		p.write("{ ")
+		for i, s := range b.Statements {
+			if i > 0 {
+				p.write(" ")
+			}
+			p.statement(s)
+			var next ast.Statement
+			if i+1 < len(b.Statements) {
+				next = b.Statements[i+1]
+			}
+			p.write(p.terminator(s, next, true))
+		}
+		p.write(" }")
+		return
+	}
+	p.write("{")
+	p.indent++
+	p.first = true
+	p.statements(b.Statements, true)
+	p.flushComments(end.Offset)
+	p.indent--
+	p.closeLine()
+	p.write("}")
+}
+
+// closeLine starts the line for a closing bracket.
+func (p *printer) closeLine() {
+	p.out.WriteByte('\n')
+	p.atLineStart = true
+	p.first = false
+}
+
+// precedence returns how tightly e holds together, on the scale of the
+// parser's precedences. Operands and postfix expressions never need
+// parentheses around them.
+func precedence(e ast.Expression) int {
+	switch e := e.(type) {
+	case *ast.InfixExpression:
+		return parser.Precedence(e.Token.Type)
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	default:
+		return parser.INDEX
+	}
+}
+
+// expression prints e where the parser reads operators binding at least as
+// tightly as min, in parentheses if e binds less tightly.
+func (p *printer) expression(e ast.Expression, min int) {
+	p.inlineComments(ast.Start(e).Offset)
+	if precedence(e) < min {
+		p.write("(")
+		defer p.write(")")
+	}
+	switch e := e.(type) {
+	case *ast.Identifier:
+		p.write(e.Value)
+	case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.Boolean:
+		p.write(e.TokenLiteral())
+	case *ast.StringLiteral:
+		p.write(p.literal(e.Token))
+	case *ast.PrefixExpression:
+		p.write(e.Operator)
+		p.expression(e.Right, parser.PREFIX)
+	case *ast.InfixExpression:
+		// Operators are left-associative, so an operand on the right that
+		// binds just as tightly needs parentheses.
+		prec := parser.Precedence(e.Token.Type)
+		p.expression(e.Left, prec)
+		p.write(" " + e.Operator + " ")
+		p.expression(e.Right, prec+1)
+	case *ast.IfExpression:
+		p.write("if (")
+		p.expression(e.Condition, parser.LOWEST)
+		p.write(") ")
+		p.block(e.Consequence)
+		if e.Alternative != nil {
+			p.write(" else ")
+			p.block(e.Alternative)
+		}
+	case *ast.TryExpression:
+		p.write("try ")
+		p.block(e.Body)
+		if e.Catch != nil {
+			p.write(" catch (" + e.CatchParam.Value + ") ")
+			p.block(e.Catch)
+		}
+		if e.Finally != nil {
+			p.write(" finally ")
+			p.block(e.Finally)
+		}
+	case *ast.FunctionLiteral:
+		params := make([]string, len(e.Parameters))
+		for i, param := range e.Parameters {
+			params[i] = param.Value
+		}
+		p.write("fn(" + strings.Join(params, ", ") + ") ")
+		p.block(e.Body)
+	case *ast.CallExpression:
+		p.expression(e.Function, parser.CALL)
+		p.list(e.Token, ")", len(e.Arguments), func(i int) ast.Expression { return e.Arguments[i] }, func(i int) {
+			p.expression(e.Arguments[i], parser.LOWEST)
+		})
+	case *ast.IndexExpression:
+		p.expression(e.Left, parser.CALL)
+		p.write("[")
+		p.expression(e.Index, parser.LOWEST)
+		p.write("]")
+	case *ast.SliceExpression:
+		p.expression(e.Left, parser.CALL)
+		p.write("[")
+		if e.Low != nil {
+			p.expression(e.Low, parser.LOWEST)
+		}
+		p.write(":")
+		if e.High != nil {
+			p.expression(e.High, parser.LOWEST)
+		}
+		p.write("]")
+	case *ast.MemberExpression:
+		p.expression(e.Left, parser.CALL)
+		p.write("." + e.Name.Value)
+	case *ast.ArrayLiteral:
+		p.list(e.Token, "]", len(e.Elements), func(i int) ast.Expression { return e.Elements[i] }, func(i int) {
+			p.expression(e.Elements[i], parser.LOWEST)
+		})
+	case *ast.HashLiteral:
+		p.list(e.Token, "}", len(e.Keys), func(i int) ast.Expression { return e.Keys[i] }, func(i int) {
+			p.expression(e.Keys[i], parser.LOWEST)
+			p.write(": ")
+			p.expression(e.Pairs[e.Keys[i]], parser.LOWEST)
+		})
+	}
+}
+
+// list prints the n items of an array or hash literal or the arguments of a
+// call. They go on one line unless the first one is on a later line than
+// the opening bracket in the source or there are comments between them, in
+// which case each gets a line of its own. first returns the expression an
+// item starts with.
+func (p *printer) list(open token.Token, closer string, n int, first func(int) ast.Expression, item func(int)) {
+	p.write(open.Literal)
+	if !p.hasCommentsIn(open.Pos.Offset) && (n == 0 || ast.Start(first(0)).Line == open.Pos.Line) {
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				p.write(", ")
+			}
+			item(i)
+		}
+		p.write(closer)
+		return
+	}
+	p.indent++
+	p.first = true
+	for i := 0; i < n; i++ {
+		pos := ast.Start(first(i))
+		p.flushComments(pos.Offset)
+		p.line(pos)
+		item(i)
+		if i < n-1 {
+			p.write(",")
+		}
+	}
+	p.flushComments(p.closers[open.Pos.Offset].Offset)
+	p.indent--
+	p.closeLine()
+	p.write(closer)
+}
+
+// literal returns tok as it is written in the source, so that strings keep
+// their escape sequences.
+func (p *printer) literal(tok token.Token) string {
+	return p.src[tok.Pos.Offset:tok.End.Offset]
+}
@@ -0,0 +1,168 @@
+package format_test
+
+import (
+	"testing"
+
+	"github.com/smiksha1701/buggy/format"
+	"github.com/smiksha1701/buggy/lexer"
+	"github.com/smiksha1701/buggy/parser"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"", ""},
+		{"let x=1", "let x = 1;\n"},
+		{"let x = 1 ;  let y = x", "let x = 1;\nlet y = x;\n"},
+		{"x+=1", "x += 1;\n"},
+		{"((1 + 2)) * 3", "(1 + 2) * 3;\n"},
+		{"1 + (2 * 3)", "1 + 2 * 3;\n"},
+		{"(1 - 2) - 3", "1 - 2 - 3;\n"},
+		{"1 - (2 - 3)", "1 - (2 - 3);\n"},
+		{"(a || b) && c", "(a || b) && c;\n"},
+		{"a || (b && c)", "a || b && c;\n"},
+		{"(a < b) == (c > d)", "a < b == c > d;\n"},
+		{"-(a + b)", "-(a + b);\n"},
+		{"(-a)[0]", "(-a)[0];\n"},
+		{"-(a[0])", "-a[0];\n"},
+		{"!(!a)", "!!a;\n"},
+		{"(f(1))(2)", "f(1)(2);\n"},
+		{"(a + b)(c)", "(a + b)(c);\n"},
+		{"(fn(x) { x })(1)", "fn(x) { x }(1);\n"},
+		{"(lib.xs)[1:]", "lib.xs[1:];\n"},
+		{"xs[ : 2 ]", "xs[:2];\n"},
+		{`[1,2 , "three"]`, "[1, 2, \"three\"];\n"},
+		{`{"b":1,"a":2}`, "{\"b\": 1, \"a\": 2};\n"},
+		{"[]; {}", "[];\n{};\n"},
+		{`"tab\there \u{1F41B}"`, "\"tab\\there \\u{1F41B}\";\n"},
+		{`import   "lib.bg" as lib`, "import \"lib.bg\" as lib;\n"},
+		{"export let two=lib.one+1", "export let two = lib.one + 1;\n"},
+		{
+			"let add = fn(a,b){a+b};",
+			"let add = fn(a, b) { a + b };\n",
+		},
+		{
+			"let add = fn(a, b) {\nreturn a + b\n}",
+			"let add = fn(a, b) {\n\treturn a + b;\n};\n",
+		},
+		{
+			"let f = fn() {\nlet x = 1\nx\n}",
+			"let f = fn() {\n\tlet x = 1;\n\tx\n};\n",
+		},
+		{"let f = fn() {\n}", "let f = fn() {};\n"},
+		{
+			"if (a) {\nb\n} else {\nc }",
+			"if (a) {\n\tb\n} else {\n\tc\n}\n",
+		},
+		{"if (a) { b } else { c }", "if (a) { b } else { c }\n"},
+		{
+			"while(i<10){\ni+=1\nif (i == 5) { break }\n}",
+			"while (i < 10) {\n\ti += 1;\n\tif (i == 5) { break; }\n}\n",
+		},
+		{
+			"for(k,v in h) {\nsay(k, v)\n}",
+			"for (k, v in h) {\n\tsay(k, v)\n}\n",
+		},
+		{
+			"try {\nthrow \"x\"\n} catch(e) {\ne\n} finally {\nclose()\n}",
+			"try {\n\tthrow \"x\";\n} catch (e) {\n\te\n} finally {\n\tclose()\n}\n",
+		},
+		{
+			// The semicolon keeps -1 from being subtracted from the if.
+			"if (a) { b }; -1",
+			"if (a) { b };\n-1;\n",
+		},
+		{"if (a) { b }; c", "if (a) { b }\nc;\n"},
+		{
+			"let xs = [\n1, 2,\n3\n]",
+			"let xs = [\n\t1,\n\t2,\n\t3\n];\n",
+		},
+		{
+			"let h = {\n\"a\": fn(x) {\nx\n},\n\"b\": 2,\n}",
+			"let h = {\n\t\"a\": fn(x) {\n\t\tx\n\t},\n\t\"b\": 2\n};\n",
+		},
+		{
+			"let a = 1;\n\n\n\nlet b = 2;\nlet c = 3;",
+			"let a = 1;\n\nlet b = 2;\nlet c = 3;\n",
+		},
+		{
+			"let f = fn() {\n\n  let a = 1;\n\n  a\n\n}",
+			"let f = fn() {\n\tlet a = 1;\n\n\ta\n};\n",
+		},
+		{
+			"// header\n\nlet a = 1; // one\n/* two */ let b = 2;\n// end",
+			"// header\n\nlet a = 1; // one\n/* two */\nlet b = 2;\n// end\n",
+		},
+		{
+			"let f = fn() { // note\n  a // value\n  // after\n}",
+			"let f = fn() { // note\n\ta // value\n\t// after\n};\n",
+		},
+		{
+			"let f = fn() { /* empty */ }",
+			"let f = fn() { /* empty */\n};\n",
+		},
+		{
+			"let xs = [\n  1, // one\n  // two\n  2\n];",
+			"let xs = [\n\t1, // one\n\t// two\n\t2\n];\n",
+		},
+		{
+			"f(1, // odd\n 2);\ng()",
+			"f(\n\t1, // odd\n\t2\n);\ng();\n",
+		},
+		{"let x = 1 + /* c */ 2;", "let x = 1 + /* c */ 2;\n"},
+		{"let x = -/* c */y", "let x = - /* c */ y;\n"},
+		{
+			"let x = 1 + // c\n 2;",
+			"let x = 1 + // c\n\t2;\n",
+		},
+		{
+			"let f = fn() {\nreturn a && /* b */\nc\n}",
+			"let f = fn() {\n\treturn a && /* b */\n\t\tc;\n};\n",
+		},
+		{"f(/* none */)", "f( /* none */\n);\n"},
+		{
+			"map(xs, fn(x) {\n// double\nx * 2\n})",
+			"map(xs, fn(x) {\n\t// double\n\tx * 2\n});\n",
+		},
+	}
+
+	for _, tt := range tests {
+		formatted, err := format.Source("", []byte(tt.input))
+		if err != nil {
+			t.Errorf("unexpected error for %q: %s", tt.input, err)
+			continue
+		}
+		if string(formatted) != tt.expected {
+			t.Errorf("wrong output for %q.\nexpected=\n%s\ngot=\n%s", tt.input, tt.expected, formatted)
+			continue
+		}
+		again, err := format.Source("", formatted)
+		if err != nil || string(again) != string(formatted) {
+			t.Errorf("formatting %q is not stable. got=\n%s\nerr=%v", formatted, again, err)
+		}
+		if parse(tt.input) != parse(string(formatted)) {
+			t.Errorf("formatting %q changed the program to %q", tt.input, formatted)
+		}
+	}
+}
+
+func TestSourceSyntaxErrors(t *testing.T) {
+	_, err := format.Source("bad.bg", []byte("let = 1;\nlet x = (;"))
+	syntaxErr, ok := err.(*format.SyntaxError)
+	if !ok {
+		t.Fatalf("expected *format.SyntaxError. got=%T (%v)", err, err)
+	}
+	if len(syntaxErr.Errors) != 2 {
+		t.Fatalf("wrong number of errors. expected=2, got=%d (%v)", len(syntaxErr.Errors), err)
+	}
+	expected := "bad.bg:1:5: expected identifier, found =\nbad.bg:2:10: expected expression, found ;"
+	if err.Error() != expected {
+		t.Errorf("wrong error. expected=%q, got=%q", expected, err.Error())
+	}
+}
+
+func parse(input string) string {
+	return parser.New(lexer.New(input)).ParseProgram().String()
+}
@@ -0,0 +1,159 @@
+// Package interpreter runs Buggy programs from Go programs that embed the
+// language, for example to evaluate rules written in it.
+//
+//	in := interpreter.New()
+//	in.Register("now", func(args ...object.Object) object.Object { ... })
+//	if _, err := in.Run(`let discount = fn(total) { total / 10 }`); err != nil {
+//		...
+//	}
+//	result, err := in.Call("discount", &object.Integer{Value: 250})
+//
+// Programs run on the tree-walking evaluator. Errors they raise are returned
+// as *object.Error and syntax errors as *ParseError.
+package interpreter
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/smiksha1701/buggy/evaluator"
+	"github.com/smiksha1701/buggy/lexer"
+	"github.com/smiksha1701/buggy/object"
+	"github.com/smiksha1701/buggy/parser"
+)
+
+// Interpreter holds the globals defined by the programs it has run, so a
+// later Run or Call sees what earlier ones left behind. An Interpreter must
+// not be used by more than one goroutine at a time.
+type Interpreter struct {
+	// globals also holds the builtins of this interpreter as host names,
+	// which shadow the ones every program has and are seen by the modules
+	// programs import. Programs can shadow them in turn without replacing
+	// them.
+	globals *object.Environment
+	stdin   io.Reader
+	stdout  io.Writer
+	limits  object.Limits
+}
+
+// New returns an interpreter with no globals that reads from os.Stdin and
+// writes to os.Stdout.
+func New() *Interpreter {
+	in := &Interpreter{
+		globals: object.NewEnvironment(),
+		stdin:   os.Stdin,
+		stdout:  os.Stdout,
+	}
+	in.bindIO()
+	return in
+}
+
+// SetStdout sets where `say` and the prompts of `input` are written.
+func (in *Interpreter) SetStdout(w io.Writer) {
+	in.stdout = w
+	in.bindIO()
+}
+
+// SetStdin sets where `input` reads lines from.
+func (in *Interpreter) SetStdin(r io.Reader) {
+	in.stdin = r
+	in.bindIO()
+}
+
+func (in *Interpreter) bindIO() {
+	in.globals.SetHost("say", evaluator.Say(in.stdout))
+	in.globals.SetHost("input", evaluator.Input(in.stdin, in.stdout))
+}
+
+// SetLimits bounds the resources each later Run or Call may use.
+func (in *Interpreter) SetLimits(limits object.Limits) {
+	in.limits = limits
+}
+
+// Register makes fn callable as a builtin named name by the programs this
+// interpreter runs. It replaces any builtin of the same name, for this
+// interpreter only.
+func (in *Interpreter) Register(name string, fn object.BuiltinFunction) {
+	in.globals.SetHost(name, &object.Builtin{Fn: fn})
+}
+
+// RegisterFunc is like Register for a Go function of any type, which is
+// wrapped with object.NewBuiltin to convert its arguments and results.
+func (in *Interpreter) RegisterFunc(name string, fn interface{}) error {
+	builtin, err := object.NewBuiltin(fn)
+	if err != nil {
+		return err
+	}
+	in.globals.SetHost(name, builtin)
+	return nil
+}
+
+// Set defines a global, as a top-level let statement would.
+func (in *Interpreter) Set(name string, value object.Object) {
+	in.globals.Set(name, value)
+}
+
+// Get returns the value of a global or builtin of this interpreter.
+func (in *Interpreter) Get(name string) (object.Object, bool) {
+	return in.globals.Get(name)
+}
+
+// Run runs source and returns the value of its last statement.
+func (in *Interpreter) Run(source string) (object.Object, error) {
+	return in.RunContext(context.Background(), source)
+}
+
+// RunContext is like Run but stops the program with a CancelledError once
+// ctx is done.
+func (in *Interpreter) RunContext(ctx context.Context, source string) (object.Object, error) {
+	p := parser.New(lexer.New(source))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, &ParseError{Errors: p.Errors()}
+	}
+	in.globals.SetBudget(object.NewBudget(ctx, in.limits))
+	defer in.globals.SetBudget(nil)
+	return result(evaluator.Eval(program, in.globals))
+}
+
+// Call calls the function bound to the global fnName with args.
+func (in *Interpreter) Call(fnName string, args ...object.Object) (object.Object, error) {
+	return in.CallContext(context.Background(), fnName, args...)
+}
+
+// CallContext is like Call but stops the function with a CancelledError
+// once ctx is done.
+func (in *Interpreter) CallContext(ctx context.Context, fnName string, args ...object.Object) (object.Object, error) {
+	fn, ok := in.globals.Get(fnName)
+	if !ok {
+		return nil, &object.Error{Kind: object.NAME_ERROR, Message: "identifier not found: " + fnName}
+	}
+	in.globals.SetBudget(object.NewBudget(ctx, in.limits))
+	defer in.globals.SetBudget(nil)
+	return result(evaluator.ApplyFunction(in.globals, fn, args))
+}
+
+func result(obj object.Object) (object.Object, error) {
+	if errObj, ok := obj.(*object.Error); ok {
+		return nil, errObj
+	}
+	if obj == nil {
+		return evaluator.NULL, nil
+	}
+	return obj, nil
+}
+
+// ParseError reports the syntax errors that kept a program from running.
+type ParseError struct {
+	Errors []*parser.Error
+}
+
+func (e *ParseError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
@@ -0,0 +1,190 @@
+package interpreter_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/smiksha1701/buggy/interpreter"
+	"github.com/smiksha1701/buggy/object"
+)
+
+func TestRunKeepsGlobals(t *testing.T) {
+	in := interpreter.New()
+	if _, err := in.Run("let total = 40;"); err != nil {
+		t.Fatalf("Run failed: %s", err)
+	}
+	result, err := in.Run("total + 2")
+	if err != nil {
+		t.Fatalf("Run failed: %s", err)
+	}
+	testInteger(t, result, 42)
+}
+
+func TestCall(t *testing.T) {
+	in := interpreter.New()
+	if _, err := in.Run("let discount = fn(total, percent) { total * percent / 100 }"); err != nil {
+		t.Fatalf("Run failed: %s", err)
+	}
+	result, err := in.Call("discount", &object.Integer{Value: 250}, &object.Integer{Value: 20})
+	if err != nil {
+		t.Fatalf("Call failed: %s", err)
+	}
+	testInteger(t, result, 50)
+
+	_, err = in.Call("missing")
+	testErrorKind(t, err, object.NAME_ERROR)
+	_, err = in.Call("discount", &object.Integer{Value: 250})
+	testErrorKind(t, err, object.ARGUMENT_ERROR)
+}
+
+func TestSetAndGet(t *testing.T) {
+	in := interpreter.New()
+	in.Set("limit", &object.Integer{Value: 10})
+	if _, err := in.Run("let doubled = limit * 2"); err != nil {
+		t.Fatalf("Run failed: %s", err)
+	}
+	doubled, ok := in.Get("doubled")
+	if !ok {
+		t.Fatalf("doubled is not defined")
+	}
+	testInteger(t, doubled, 20)
+	if _, ok := in.Get("undefined"); ok {
+		t.Errorf("undefined is defined")
+	}
+}
+
+func TestStdoutAndStdin(t *testing.T) {
+	var out bytes.Buffer
+	in := interpreter.New()
+	in.SetStdout(&out)
+	in.SetStdin(strings.NewReader("Ada\nLovelace\n"))
+	result, err := in.Run(`let first = input("first: "); let second = input(); say(first, second); input()`)
+	if err != nil {
+		t.Fatalf("Run failed: %s", err)
+	}
+	if result.Type() != object.NULL_OBJ {
+		t.Errorf("input at the end of stdin returned %s", result.Inspect())
+	}
+	expected := "first: Ada\nLovelace\n"
+	if out.String() != expected {
+		t.Errorf("wrong output. want=%q, got=%q", expected, out.String())
+	}
+}
+
+func TestRegister(t *testing.T) {
+	calls := 0
+	in := interpreter.New()
+	in.Register("tick", func(args ...object.Object) object.Object {
+		calls++
+		return &object.Integer{Value: int64(calls)}
+	})
+	result, err := in.Run("tick(); tick()")
+	if err != nil {
+		t.Fatalf("Run failed: %s", err)
+	}
+	testInteger(t, result, 2)
+
+	// Builtins registered on one interpreter are not seen by another.
+	_, err = interpreter.New().Run("tick()")
+	testErrorKind(t, err, object.NAME_ERROR)
+}
+
+func TestModulesSeeHostBuiltinsOnly(t *testing.T) {
+	dir := t.TempDir()
+	source := "export let ticks = fn() { tick() }; export let peek = fn() { secret };"
+	if err := os.WriteFile(filepath.Join(dir, "lib.bg"), []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	in := interpreter.New()
+	in.Register("tick", func(args ...object.Object) object.Object {
+		return &object.Integer{Value: 1}
+	})
+	in.Set("secret", &object.Integer{Value: 7})
+	if _, err := in.Run(`import "` + dir + `/lib.bg" as lib;`); err != nil {
+		t.Fatalf("Run failed: %s", err)
+	}
+	result, err := in.Run("lib.ticks()")
+	if err != nil {
+		t.Fatalf("Run failed: %s", err)
+	}
+	testInteger(t, result, 1)
+	_, err = in.Run("lib.peek()")
+	testErrorKind(t, err, object.NAME_ERROR)
+}
+
+func TestRegisterFunc(t *testing.T) {
+	in := interpreter.New()
+	err := in.RegisterFunc("total", func(prices map[string]float64) float64 {
+		sum := 0.0
+		for _, price := range prices {
+			sum += price
+		}
+		return sum
+	})
+	if err != nil {
+		t.Fatalf("RegisterFunc failed: %s", err)
+	}
+	result, err := in.Run(`total({"tea": 2, "cake": 3.5})`)
+	if err != nil {
+		t.Fatalf("Run failed: %s", err)
+	}
+	if total, err := object.ToGo(result); err != nil || total != 5.5 {
+		t.Errorf("wrong total %s", result.Inspect())
+	}
+	_, err = in.Run(`total(["tea"])`)
+	testErrorKind(t, err, object.TYPE_ERROR)
+	if err := in.RegisterFunc("broken", 42); err == nil {
+		t.Errorf("RegisterFunc accepted an int")
+	}
+}
+
+func TestErrors(t *testing.T) {
+	in := interpreter.New()
+	_, err := in.Run("let = 1")
+	var parseErr *interpreter.ParseError
+	if !errors.As(err, &parseErr) || len(parseErr.Errors) == 0 {
+		t.Errorf("expected a *ParseError, got=%T (%v)", err, err)
+	}
+
+	_, err = in.Run(`1 + "a"`)
+	testErrorKind(t, err, object.TYPE_ERROR)
+
+	in.SetLimits(object.Limits{MaxSteps: 100})
+	_, err = in.Run("while (true) { }")
+	testErrorKind(t, err, object.STEP_LIMIT_ERROR)
+
+	in.SetLimits(object.Limits{})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = in.RunContext(ctx, "while (true) { }")
+	testErrorKind(t, err, object.CANCELLED_ERROR)
+}
+
+func testInteger(t *testing.T, obj object.Object, expected int64) {
+	t.Helper()
+	integer, ok := obj.(*object.Integer)
+	if !ok {
+		t.Errorf("object is not Integer. got=%T (%+v)", obj, obj)
+		return
+	}
+	if integer.Value != expected {
+		t.Errorf("object has wrong value. got=%d, want=%d", integer.Value, expected)
+	}
+}
+
+func testErrorKind(t *testing.T, err error, expected object.ErrorKind) {
+	t.Helper()
+	var errObj *object.Error
+	if !errors.As(err, &errObj) {
+		t.Errorf("expected an *object.Error, got=%T (%v)", err, err)
+		return
+	}
+	if errObj.Kind != expected {
+		t.Errorf("wrong error kind. want=%s, got=%s (%s)", expected, errObj.Kind, errObj.Message)
+	}
+}
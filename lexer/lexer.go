@@ -1,19 +1,100 @@
 package lexer
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
 	"github.com/smiksha1701/buggy/token"
 )
 
+// Error is a problem in the input that the lexer cannot turn into a token,
+// such as an unterminated string or an invalid escape sequence.
+type Error struct {
+	Pos token.Position
+	Msg string
+	// Unterminated is set when a string or comment runs to the end of the
+	// input, which more input could still close.
+	Unterminated bool
+}
+
+func (e Error) Error() string {
+	return e.Pos.String() + ": " + e.Msg
+}
+
 type Lexer struct {
 	input        string
+	filename     string
 	position     int
 	ReadPosition int
-	ch           byte
+	ch           rune // the character at position, or 0 at the end of input
+	line         int
+	column       int
+
+	// EmitComments makes NextToken return comments as COMMENT tokens
+	// instead of skipping them, for tools that need to preserve them.
+	EmitComments bool
+
+	errors []Error
+}
+
+// Errors returns the problems found in the input read so far.
+func (l *Lexer) Errors() []Error {
+	return l.errors
+}
+
+func (l *Lexer) addError(pos token.Position, format string, a ...interface{}) {
+	l.errors = append(l.errors, Error{Pos: pos, Msg: fmt.Sprintf(format, a...)})
 }
 
 func (l *Lexer) NextToken() token.Token {
+	for {
+		l.skipWhitespaces()
+		pos := l.currentPosition()
+		var tok token.Token
+		if l.ch == '/' && (l.PeekChar() == '/' || l.PeekChar() == '*') {
+			tok = l.readComment()
+			if tok.Type == token.COMMENT && !l.EmitComments {
+				continue
+			}
+		} else {
+			tok = l.readToken()
+		}
+		tok.Pos = pos
+		tok.End = l.currentPosition()
+		return tok
+	}
+}
+
+// readComment reads a `// ...` comment up to the end of the line or a
+// `/* ... */` comment up to its closing delimiter.
+func (l *Lexer) readComment() token.Token {
+	pos := l.currentPosition()
+	start := l.position
+	if l.PeekChar() == '/' {
+		for l.ch != '\n' && l.ch != 0 {
+			l.ReadChar()
+		}
+		return token.Token{Type: token.COMMENT, Literal: l.input[start:l.position]}
+	}
+	l.ReadChar()
+	l.ReadChar()
+	for !(l.ch == '*' && l.PeekChar() == '/') {
+		if l.ch == 0 {
+			l.errors = append(l.errors, Error{Pos: pos, Msg: "unterminated comment", Unterminated: true})
+			return token.Token{Type: token.COMMENT, Literal: l.input[start:l.position]}
+		}
+		l.ReadChar()
+	}
+	l.ReadChar()
+	l.ReadChar()
+	return token.Token{Type: token.COMMENT, Literal: l.input[start:l.position]}
+}
+
+func (l *Lexer) readToken() token.Token {
 	var tok token.Token
-	l.skipWhitespaces()
 	switch l.ch {
 	case '=':
 		if l.PeekChar() == '=' {
@@ -29,6 +110,8 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.COLON, l.ch)
 	case ',':
 		tok = newToken(token.COMMA, l.ch)
+	case '.':
+		tok = newToken(token.DOT, l.ch)
 	case '}':
 		tok = newToken(token.RBRACE, l.ch)
 	case '{':
@@ -42,17 +125,77 @@ func (l *Lexer) NextToken() token.Token {
 	case ')':
 		tok = newToken(token.RPAREN, l.ch)
 	case '+':
-		tok = newToken(token.PLUS, l.ch)
+		if l.PeekChar() == '=' {
+			tok.Type = token.PLUS_ASSIGN
+			tok.Literal = "+="
+			l.ReadChar()
+		} else {
+			tok = newToken(token.PLUS, l.ch)
+		}
 	case '-':
-		tok = newToken(token.MINUS, l.ch)
+		if l.PeekChar() == '=' {
+			tok.Type = token.MINUS_ASSIGN
+			tok.Literal = "-="
+			l.ReadChar()
+		} else {
+			tok = newToken(token.MINUS, l.ch)
+		}
 	case '*':
-		tok = newToken(token.ASTERIX, l.ch)
+		if l.PeekChar() == '=' {
+			tok.Type = token.ASTERIX_ASSIGN
+			tok.Literal = "*="
+			l.ReadChar()
+		} else {
+			tok = newToken(token.ASTERIX, l.ch)
+		}
 	case '/':
-		tok = newToken(token.SLASH, l.ch)
+		if l.PeekChar() == '=' {
+			tok.Type = token.SLASH_ASSIGN
+			tok.Literal = "/="
+			l.ReadChar()
+		} else {
+			tok = newToken(token.SLASH, l.ch)
+		}
+	case '%':
+		if l.PeekChar() == '=' {
+			tok.Type = token.PERCENT_ASSIGN
+			tok.Literal = "%="
+			l.ReadChar()
+		} else {
+			tok = newToken(token.PERCENT, l.ch)
+		}
 	case '<':
-		tok = newToken(token.LT, l.ch)
+		if l.PeekChar() == '=' {
+			tok.Type = token.LTE
+			tok.Literal = "<="
+			l.ReadChar()
+		} else {
+			tok = newToken(token.LT, l.ch)
+		}
 	case '>':
-		tok = newToken(token.GT, l.ch)
+		if l.PeekChar() == '=' {
+			tok.Type = token.GTE
+			tok.Literal = ">="
+			l.ReadChar()
+		} else {
+			tok = newToken(token.GT, l.ch)
+		}
+	case '&':
+		if l.PeekChar() == '&' {
+			tok.Type = token.AND
+			tok.Literal = "&&"
+			l.ReadChar()
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
+	case '|':
+		if l.PeekChar() == '|' {
+			tok.Type = token.OR
+			tok.Literal = "||"
+			l.ReadChar()
+		} else {
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
 	case '!':
 		if l.PeekChar() == '=' {
 			tok.Type = token.NEQ
@@ -76,6 +219,9 @@ func (l *Lexer) NextToken() token.Token {
 		} else if IsNumber(l.ch) {
 			tok.Literal = l.ReadNumber()
 			tok.Type = token.INT
+			if strings.ContainsAny(tok.Literal, ".eE") {
+				tok.Type = token.FLOAT
+			}
 			return tok
 		} else {
 			tok = newToken(token.ILLEGAL, l.ch)
@@ -89,39 +235,122 @@ func (l *Lexer) skipWhitespaces() {
 		l.ReadChar()
 	}
 }
-func newToken(TokenType token.TokenType, ch byte) token.Token {
+func newToken(TokenType token.TokenType, ch rune) token.Token {
 	return token.Token{Type: TokenType, Literal: string(ch)}
 }
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	return NewFile("", input)
+}
+
+// NewFile creates a lexer whose token positions refer to filename.
+func NewFile(filename, input string) *Lexer {
+	l := &Lexer{input: input, filename: filename, line: 1}
 	l.ReadChar()
 	return l
 }
-func (l *Lexer) PeekChar() byte {
+
+func (l *Lexer) currentPosition() token.Position {
+	return token.Position{Filename: l.filename, Offset: l.position, Line: l.line, Column: l.column}
+}
+func (l *Lexer) PeekChar() rune {
 	if l.ReadPosition >= len(l.input) {
 		return 0
 	} else {
-		return l.input[l.ReadPosition]
+		r, _ := utf8.DecodeRuneInString(l.input[l.ReadPosition:])
+		return r
 	}
 }
+
+// ReadChar decodes the next UTF-8 character of the input. Offsets count
+// bytes while columns count characters.
 func (l *Lexer) ReadChar() {
+	if l.ReadPosition > len(l.input) {
+		return
+	}
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
+	width := 1
 	if l.ReadPosition >= len(l.input) {
 		l.ch = 0
 	} else {
-		l.ch = l.input[l.ReadPosition]
+		l.ch, width = utf8.DecodeRuneInString(l.input[l.ReadPosition:])
 	}
 	l.position = l.ReadPosition
-	l.ReadPosition += 1
+	l.ReadPosition += width
+	l.column += 1
 }
+
+// ReadString reads a string literal and returns its value with the escape
+// sequences replaced. It stops on the closing quote, which the caller
+// consumes, or at the end of the input, which is reported as an error.
 func (l *Lexer) ReadString() string {
-	position := l.position + 1
+	pos := l.currentPosition()
+	var out strings.Builder
 	for {
 		l.ReadChar()
-		if l.ch == '"' || l.ch == 0 {
-			break
+		switch l.ch {
+		case '"':
+			return out.String()
+		case 0:
+			l.errors = append(l.errors, Error{Pos: pos, Msg: "unterminated string", Unterminated: true})
+			return out.String()
+		case '\\':
+			l.readEscape(&out)
+		default:
+			out.WriteRune(l.ch)
 		}
 	}
-	return l.input[position:l.position]
+}
+
+// readEscape decodes the escape sequence starting at the current backslash.
+// An invalid sequence is reported and copied to out unchanged.
+func (l *Lexer) readEscape(out *strings.Builder) {
+	pos := l.currentPosition()
+	l.ReadChar()
+	switch l.ch {
+	case 'n':
+		out.WriteByte('\n')
+	case 't':
+		out.WriteByte('\t')
+	case '\\', '"':
+		out.WriteRune(l.ch)
+	case 'u':
+		l.readUnicodeEscape(pos, out)
+	case 0:
+		// The string is unterminated, which ReadString reports.
+	default:
+		l.addError(pos, "invalid escape sequence \\%c", l.ch)
+		out.WriteByte('\\')
+		out.WriteRune(l.ch)
+	}
+}
+
+// readUnicodeEscape reads the `{...}` part of a \u{...} escape, which holds
+// one to six hex digits naming a Unicode code point.
+func (l *Lexer) readUnicodeEscape(pos token.Position, out *strings.Builder) {
+	if l.PeekChar() != '{' {
+		l.addError(pos, "invalid unicode escape: missing {")
+		return
+	}
+	l.ReadChar()
+	start := l.position + 1
+	for isHexDigit(l.PeekChar()) {
+		l.ReadChar()
+	}
+	digits := l.input[start : l.position+1]
+	if l.PeekChar() != '}' || len(digits) == 0 || len(digits) > 6 {
+		l.addError(pos, "invalid unicode escape")
+		return
+	}
+	l.ReadChar()
+	code, _ := strconv.ParseUint(digits, 16, 32)
+	if !utf8.ValidRune(rune(code)) {
+		l.addError(pos, "invalid unicode code point U+%X", code)
+		return
+	}
+	out.WriteRune(rune(code))
 }
 func (l *Lexer) ReadIdent() string {
 	start_pos := l.position
@@ -130,16 +359,51 @@ func (l *Lexer) ReadIdent() string {
 	}
 	return l.input[start_pos:l.position]
 }
+
+// ReadNumber reads an integer or a floating point literal such as 3.14,
+// 1e9 or 2.5E-3. A dot only belongs to the number when a digit follows it.
 func (l *Lexer) ReadNumber() string {
 	start_pos := l.position
-	for IsNumber(l.ch) {
+	l.readDigits()
+	if l.ch == '.' && IsNumber(l.PeekChar()) {
 		l.ReadChar()
+		l.readDigits()
+	}
+	if l.ch == 'e' || l.ch == 'E' {
+		next := l.PeekChar()
+		if IsNumber(next) || ((next == '+' || next == '-') && IsNumber(l.peekCharAt(2))) {
+			l.ReadChar()
+			if l.ch == '+' || l.ch == '-' {
+				l.ReadChar()
+			}
+			l.readDigits()
+		}
 	}
 	return l.input[start_pos:l.position]
 }
-func IsNumber(ch byte) bool {
+func (l *Lexer) readDigits() {
+	for IsNumber(l.ch) {
+		l.ReadChar()
+	}
+}
+
+// peekCharAt returns the byte offset bytes after the current character. It
+// only looks for ASCII, so it does not need to decode runes.
+func (l *Lexer) peekCharAt(offset int) rune {
+	if l.position+offset >= len(l.input) {
+		return 0
+	}
+	return rune(l.input[l.position+offset])
+}
+func isHexDigit(ch rune) bool {
+	return IsNumber(ch) || (ch >= 'a' && ch <= 'f') || (ch >= 'A' && ch <= 'F')
+}
+func IsNumber(ch rune) bool {
 	return (ch >= '0' && ch <= '9')
 }
-func IsLetter(ch byte) bool {
-	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (ch == '_')
+
+// IsLetter reports whether ch may appear in an identifier: any Unicode
+// letter or an underscore.
+func IsLetter(ch rune) bool {
+	return unicode.IsLetter(ch) || ch == '_'
 }
@@ -1,6 +1,7 @@
 package lexer
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/smiksha1701/buggy/token"
@@ -13,7 +14,7 @@ let add = fn(x , y){
 	x + y;
 };
 let result = add(five, ten);
-!-/*5;
+!-/ *5;
 5 < 10 > 15;
 if 5 == 5{
 	return 0;
@@ -104,6 +105,39 @@ if 5 == 5{
 		{token.COLON, ":"},
 		{token.STRING, "bar"},
 		{token.RBRACE, "}"},
+		{token.SEMICOLON, ";"},
+		{token.EOF, ""},
+	}
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestNumberTokens(t *testing.T) {
+	input := `5 3.14 0.5 1e9 2.5E-3 6e+2 7.x 8e`
+	l := New(input)
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.INT, "5"},
+		{token.FLOAT, "3.14"},
+		{token.FLOAT, "0.5"},
+		{token.FLOAT, "1e9"},
+		{token.FLOAT, "2.5E-3"},
+		{token.FLOAT, "6e+2"},
+		{token.INT, "7"},
+		{token.DOT, "."},
+		{token.IDENT, "x"},
+		{token.INT, "8"},
+		{token.IDENT, "e"},
 		{token.EOF, ""},
 	}
 
@@ -117,3 +151,278 @@ if 5 == 5{
 		}
 	}
 }
+
+func TestAssignmentTokens(t *testing.T) {
+	input := `x = 1; x += 2; x -= 3; x *= 4; x /= 5; x + 1`
+	l := New(input)
+	tests := []token.TokenType{
+		token.IDENT, token.ASSIGN, token.INT, token.SEMICOLON,
+		token.IDENT, token.PLUS_ASSIGN, token.INT, token.SEMICOLON,
+		token.IDENT, token.MINUS_ASSIGN, token.INT, token.SEMICOLON,
+		token.IDENT, token.ASTERIX_ASSIGN, token.INT, token.SEMICOLON,
+		token.IDENT, token.SLASH_ASSIGN, token.INT, token.SEMICOLON,
+		token.IDENT, token.PLUS, token.INT, token.EOF,
+	}
+
+	for i, expected := range tests {
+		tok := l.NextToken()
+		if tok.Type != expected {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, expected, tok.Type)
+		}
+	}
+}
+
+func TestOperatorTokens(t *testing.T) {
+	input := `a <= b >= c && d || e % f; x %= 2; & |`
+	l := New(input)
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.IDENT, "a"},
+		{token.LTE, "<="},
+		{token.IDENT, "b"},
+		{token.GTE, ">="},
+		{token.IDENT, "c"},
+		{token.AND, "&&"},
+		{token.IDENT, "d"},
+		{token.OR, "||"},
+		{token.IDENT, "e"},
+		{token.PERCENT, "%"},
+		{token.IDENT, "f"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.PERCENT_ASSIGN, "%="},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.ILLEGAL, "&"},
+		{token.ILLEGAL, "|"},
+		{token.EOF, ""},
+	}
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q", i, tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestModuleTokens(t *testing.T) {
+	input := `import "lib.bg" as lib; export let x = lib.y;`
+	l := New(input)
+	tests := []token.TokenType{
+		token.IMPORT, token.STRING, token.AS, token.IDENT, token.SEMICOLON,
+		token.EXPORT, token.LET, token.IDENT, token.ASSIGN,
+		token.IDENT, token.DOT, token.IDENT, token.SEMICOLON, token.EOF,
+	}
+
+	for i, expected := range tests {
+		tok := l.NextToken()
+		if tok.Type != expected {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, expected, tok.Type)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `let x = 1; // one
+/* a block
+   comment */ x / 2 // trailing`
+	tests := []struct {
+		emit     bool
+		expected []token.Token
+	}{
+		{false, []token.Token{
+			{Type: token.LET, Literal: "let"},
+			{Type: token.IDENT, Literal: "x"},
+			{Type: token.ASSIGN, Literal: "="},
+			{Type: token.INT, Literal: "1"},
+			{Type: token.SEMICOLON, Literal: ";"},
+			{Type: token.IDENT, Literal: "x"},
+			{Type: token.SLASH, Literal: "/"},
+			{Type: token.INT, Literal: "2"},
+			{Type: token.EOF, Literal: ""},
+		}},
+		{true, []token.Token{
+			{Type: token.LET, Literal: "let"},
+			{Type: token.IDENT, Literal: "x"},
+			{Type: token.ASSIGN, Literal: "="},
+			{Type: token.INT, Literal: "1"},
+			{Type: token.SEMICOLON, Literal: ";"},
+			{Type: token.COMMENT, Literal: "// one"},
+			{Type: token.COMMENT, Literal: "/* a block\n   comment */"},
+			{Type: token.IDENT, Literal: "x"},
+			{Type: token.SLASH, Literal: "/"},
+			{Type: token.INT, Literal: "2"},
+			{Type: token.COMMENT, Literal: "// trailing"},
+			{Type: token.EOF, Literal: ""},
+		}},
+	}
+
+	for _, tt := range tests {
+		l := New(input)
+		l.EmitComments = tt.emit
+		for i, expected := range tt.expected {
+			tok := l.NextToken()
+			if tok.Type != expected.Type || tok.Literal != expected.Literal {
+				t.Fatalf("emit=%t tests[%d] - wrong token. expected=%q %q, got=%q %q",
+					tt.emit, i, expected.Type, expected.Literal, tok.Type, tok.Literal)
+			}
+		}
+	}
+}
+
+func TestCommentPositions(t *testing.T) {
+	l := New("x /* a\nb */ y")
+	l.EmitComments = true
+	l.NextToken()
+	comment := l.NextToken()
+	if comment.Pos.Line != 1 || comment.Pos.Column != 3 || comment.End.Line != 2 || comment.End.Column != 5 {
+		t.Errorf("wrong comment span. got=%s-%s", comment.Pos, comment.End)
+	}
+	y := l.NextToken()
+	if y.Pos.Line != 2 || y.Pos.Column != 6 {
+		t.Errorf("wrong position after comment. got=%s", y.Pos)
+	}
+}
+
+func TestUnterminatedComment(t *testing.T) {
+	l := New("x /* never closed")
+	l.NextToken()
+	if tok := l.NextToken(); tok.Type != token.EOF {
+		t.Fatalf("expected EOF. got=%q", tok.Type)
+	}
+	errors := l.Errors()
+	if len(errors) != 1 || errors[0].Error() != "1:3: unterminated comment" || !errors[0].Unterminated {
+		t.Fatalf("wrong errors. got=%v", errors)
+	}
+}
+
+func TestStringEscapes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"plain"`, "plain"},
+		{`"a\nb"`, "a\nb"},
+		{`"tab\there"`, "tab\there"},
+		{`"back\\slash"`, "back\\slash"},
+		{`"say \"hi\""`, "say \"hi\""},
+		{`"\u{41}\u{e9}\u{1F41B}"`, "A\u00e9\U0001F41B"},
+		{`""`, ""},
+	}
+	for _, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+		if tok.Type != token.STRING || tok.Literal != tt.expected {
+			t.Errorf("wrong token for %s. expected=%q, got=%q %q", tt.input, tt.expected, tok.Type, tok.Literal)
+		}
+		if len(l.Errors()) != 0 {
+			t.Errorf("unexpected errors for %s: %v", tt.input, l.Errors())
+		}
+		if tok := l.NextToken(); tok.Type != token.EOF {
+			t.Errorf("expected EOF after %s. got=%q", tt.input, tok.Type)
+		}
+	}
+}
+
+func TestStringErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"never closed`, "1:1: unterminated string"},
+		{`x "ends in escape\"`, "1:3: unterminated string"},
+		{`"bad \q escape"`, "1:6: invalid escape sequence \\q"},
+		{`"\u41"`, "1:2: invalid unicode escape: missing {"},
+		{`"\u{}"`, "1:2: invalid unicode escape"},
+		{`"\u{12345678}"`, "1:2: invalid unicode escape"},
+		{`"\u{zz}"`, "1:2: invalid unicode escape"},
+		{`"\u{D800}"`, "1:2: invalid unicode code point U+D800"},
+	}
+	for _, tt := range tests {
+		l := New(tt.input)
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		}
+		errors := l.Errors()
+		if len(errors) != 1 {
+			t.Errorf("expected 1 error for %s. got=%v", tt.input, errors)
+			continue
+		}
+		if errors[0].Error() != tt.expected {
+			t.Errorf("wrong error for %s. expected=%q, got=%q", tt.input, tt.expected, errors[0].Error())
+		}
+		if unterminated := strings.HasSuffix(tt.expected, "unterminated string"); errors[0].Unterminated != unterminated {
+			t.Errorf("wrong Unterminated for %s. expected=%t", tt.input, unterminated)
+		}
+	}
+}
+
+func TestUnicodeInput(t *testing.T) {
+	input := `let привет = "мир 🐛"; über_x + 名前`
+	l := New(input)
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedColumn  int
+	}{
+		{token.LET, "let", 1},
+		{token.IDENT, "привет", 5},
+		{token.ASSIGN, "=", 12},
+		{token.STRING, "мир 🐛", 14},
+		{token.SEMICOLON, ";", 21},
+		{token.IDENT, "über_x", 23},
+		{token.PLUS, "+", 30},
+		{token.IDENT, "名前", 32},
+		{token.EOF, "", 34},
+	}
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%q %q, got=%q %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+		if tok.Pos.Column != tt.expectedColumn {
+			t.Errorf("tests[%d] - column wrong. expected=%d, got=%d", i, tt.expectedColumn, tok.Pos.Column)
+		}
+	}
+}
+
+func TestTokenPositions(t *testing.T) {
+	input := `let x = 5;
+  "ab" + x`
+	l := NewFile("test.bg", input)
+	tests := []struct {
+		expectedType token.TokenType
+		expectedPos  string
+		expectedEnd  string
+	}{
+		{token.LET, "test.bg:1:1", "test.bg:1:4"},
+		{token.IDENT, "test.bg:1:5", "test.bg:1:6"},
+		{token.ASSIGN, "test.bg:1:7", "test.bg:1:8"},
+		{token.INT, "test.bg:1:9", "test.bg:1:10"},
+		{token.SEMICOLON, "test.bg:1:10", "test.bg:1:11"},
+		{token.STRING, "test.bg:2:3", "test.bg:2:7"},
+		{token.PLUS, "test.bg:2:8", "test.bg:2:9"},
+		{token.IDENT, "test.bg:2:10", "test.bg:2:11"},
+		{token.EOF, "test.bg:2:11", "test.bg:2:11"},
+	}
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+		if tok.Pos.String() != tt.expectedPos {
+			t.Errorf("tests[%d] - position wrong. expected=%q, got=%q", i, tt.expectedPos, tok.Pos)
+		}
+		if tok.End.String() != tt.expectedEnd {
+			t.Errorf("tests[%d] - end position wrong. expected=%q, got=%q", i, tt.expectedEnd, tok.End)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/smiksha1701/buggy/analysis"
+	"github.com/smiksha1701/buggy/lexer"
+	"github.com/smiksha1701/buggy/parser"
+)
+
+const lintUsage = "usage: buggy lint [-disable=rule,...] path/to/script.bg...\n"
+
+// lintCommand reports likely mistakes in Buggy source files. It fails if
+// any file has findings or does not parse.
+func lintCommand(args []string) int {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	flags.Usage = func() { io.WriteString(os.Stderr, lintUsage) }
+	disable := flags.String("disable", "", "comma-separated rules to leave out")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	paths := flags.Args()
+	if len(paths) == 0 {
+		io.WriteString(os.Stderr, lintUsage)
+		return 2
+	}
+	// Scripts get the `args` global from `buggy run`.
+	config := analysis.Config{Disabled: map[analysis.Rule]bool{}, Globals: []string{"args"}}
+	if *disable != "" {
+		for _, name := range strings.Split(*disable, ",") {
+			rule := analysis.Rule(strings.TrimSpace(name))
+			if !knownRule(rule) {
+				fmt.Fprintf(os.Stderr, "buggy: unknown lint rule %q\n", rule)
+				return 2
+			}
+			config.Disabled[rule] = true
+		}
+	}
+
+	status := 0
+	for _, path := range paths {
+		source, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "buggy: %s\n", err)
+			status = 1
+			continue
+		}
+		p := parser.New(lexer.NewFile(path, string(source)))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			for _, err := range p.Errors() {
+				fmt.Fprintln(os.Stderr, err)
+			}
+			status = 1
+			continue
+		}
+		for _, finding := range analysis.Check(program, config) {
+			fmt.Println(finding)
+			status = 1
+		}
+	}
+	return status
+}
+
+func knownRule(rule analysis.Rule) bool {
+	for _, known := range analysis.Rules {
+		if rule == known {
+			return true
+		}
+	}
+	return false
+}
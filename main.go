@@ -1,7 +1,9 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"os"
 
 	"github.com/smiksha1701/buggy/repl"
 )
@@ -12,7 +14,48 @@ Fill free to type in your commands
 `
 
 func main() {
+	os.Exit(buggy(os.Args[1:]))
+}
+
+// buggy runs the command line args and returns the exit status. Flags
+// before a command apply to it: -engine is passed on to run, the only
+// command that takes it.
+func buggy(args []string) int {
+	flags := flag.NewFlagSet("buggy", flag.ContinueOnError)
+	engine := flags.String("engine", repl.ENGINE_EVAL, "execution engine: eval or vm")
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	args = flags.Args()
+	if len(args) > 0 {
+		switch args[0] {
+		case "run":
+			if flags.NFlag() > 0 {
+				return runCommand(append([]string{"-engine=" + *engine}, args[1:]...))
+			}
+			return runCommand(args[1:])
+		case "fmt", "lint":
+			if flags.NFlag() > 0 {
+				fmt.Fprintf(os.Stderr, "buggy: -engine does not apply to %s\n", args[0])
+				return 2
+			}
+			if args[0] == "fmt" {
+				return fmtCommand(args[1:])
+			}
+			return lintCommand(args[1:])
+		}
+	}
+
+	if *engine != repl.ENGINE_EVAL && *engine != repl.ENGINE_VM {
+		fmt.Fprintf(os.Stderr, "buggy: unknown engine %q\n", *engine)
+		return 2
+	}
+
 	fmt.Print(Welcome)
 
-	repl.Start()
+	repl.Start(*engine)
+	return 0
 }
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// captureStderr runs f with standard error redirected to a file and returns
+// what f wrote there.
+func captureStderr(t *testing.T, f func()) string {
+	t.Helper()
+	file, err := os.Create(filepath.Join(t.TempDir(), "stderr"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	saved := os.Stderr
+	os.Stderr = file
+	f()
+	os.Stderr = saved
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// writeScripts writes files, named relative to a new directory, and returns
+// the directory.
+func writeScripts(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, source := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(source), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestRunImportingEntryFile(t *testing.T) {
+	dir := writeScripts(t, map[string]string{
+		"main.bg": `import "lib.bg" as lib; export let name = "main"; say(lib.x);`,
+		"lib.bg":  `import "main.bg" as m; export let x = 1;`,
+	})
+	main := filepath.Join(dir, "main.bg")
+	lib := filepath.Join(dir, "lib.bg")
+	for _, engine := range []string{"eval", "vm"} {
+		var code int
+		stderr := captureStderr(t, func() { code = runCommand([]string{"-engine=" + engine, main}) })
+		if code != 1 {
+			t.Errorf("%s: wrong exit code %d", engine, code)
+		}
+		expected := "ImportError: import cycle: " + main + " -> " + lib + " -> " + main
+		if !strings.HasPrefix(stderr, expected) {
+			t.Errorf("%s: wrong error. expected=%q, got=%q", engine, expected, stderr)
+		}
+	}
+}
+
+func TestRunCommand(t *testing.T) {
+	dir := writeScripts(t, map[string]string{
+		"ok.bg":     `let greet = fn(name) { "hi " + name }; greet("there");`,
+		"throw.bg":  `throw "bad " + args[0];`,
+		"syntax.bg": `let = 1;`,
+		"fn.bg":     `throw format("%v", fn(x) { x });`,
+	})
+	script := func(name string) string { return filepath.Join(dir, name) }
+	tests := []struct {
+		args   []string
+		code   int
+		stderr string
+	}{
+		{[]string{"run", script("ok.bg")}, 0, ""},
+		{[]string{"run", "-engine=vm", script("ok.bg")}, 0, ""},
+		{[]string{"run", script("throw.bg"), "input"}, 1, "Error: bad input\n    at <main> (" + script("throw.bg") + ":1:1)\n"},
+		{[]string{"run", "-engine=vm", script("throw.bg"), "input"}, 1, "Error: bad input\n    at <main> (" + script("throw.bg") + ":1:1)\n"},
+		{[]string{"run", script("syntax.bg")}, 1, script("syntax.bg") + ":1:5: expected identifier, found =\n"},
+		{[]string{"run", script("missing.bg")}, 1, "buggy: open " + script("missing.bg") + ": no such file or directory\n"},
+		{[]string{"run"}, 2, runUsage},
+		{[]string{"run", "-engine=bogus", script("ok.bg")}, 2, "buggy: unknown engine \"bogus\"\n"},
+		// The engines show functions differently, which tells which one ran.
+		{[]string{"run", script("fn.bg")}, 1, "Error: fn(x) {\n\tx\n}\n"},
+		{[]string{"run", "-engine=vm", script("fn.bg")}, 1, "Error: fn(x) {...}\n"},
+		// Flags before the command are passed on to run.
+		{[]string{"-engine=vm", "run", script("fn.bg")}, 1, "Error: fn(x) {...}\n"},
+		{[]string{"-engine=bogus", "run", script("ok.bg")}, 2, "buggy: unknown engine \"bogus\"\n"},
+		{[]string{"-engine=vm", "fmt", script("ok.bg")}, 2, "buggy: -engine does not apply to fmt\n"},
+		{[]string{"-engine=vm", "lint", script("ok.bg")}, 2, "buggy: -engine does not apply to lint\n"},
+	}
+	for _, tt := range tests {
+		var code int
+		stderr := captureStderr(t, func() { code = buggy(tt.args) })
+		if code != tt.code {
+			t.Errorf("buggy %q: wrong exit code. expected=%d, got=%d", tt.args, tt.code, code)
+		}
+		if !strings.HasPrefix(stderr, tt.stderr) || tt.stderr == "" && stderr != "" {
+			t.Errorf("buggy %q: wrong output. expected=%q, got=%q", tt.args, tt.stderr, stderr)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package object
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Limits bounds the resources a run of a program may use. A zero field
+// means no limit.
+type Limits struct {
+	// MaxSteps counts evaluated nodes in the evaluator and executed
+	// instructions in the vm, so the same limit allows the vm less code.
+	MaxSteps int64
+	// MaxCallDepth is how many calls of Buggy functions may be active at
+	// once. Tail calls do not add to it.
+	MaxCallDepth int
+	// MaxStringLength is the longest string, in bytes, and MaxArrayLength
+	// the longest array a program may build.
+	MaxStringLength int
+	MaxArrayLength  int
+	Timeout         time.Duration
+}
+
+// Budget keeps track of one run of a program against its Limits and the
+// context that may cancel it. Every method can be called on a nil *Budget,
+// which has no limits.
+type Budget struct {
+	limits   Limits
+	ctx      context.Context
+	deadline time.Time
+	steps    int64
+	depth    int
+	// stopped is the error Step first failed with. Every later step fails
+	// with it too, so a script can't go on by catching it.
+	stopped *Error
+}
+
+// NewBudget starts the clock for a run limited by limits that stops when
+// ctx is done. ctx may be nil.
+func NewBudget(ctx context.Context, limits Limits) *Budget {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	b := &Budget{limits: limits, ctx: ctx}
+	if limits.Timeout > 0 {
+		b.deadline = time.Now().Add(limits.Timeout)
+	}
+	return b
+}
+
+// checkInterval is how many steps pass between looks at the clock and the
+// context, which are too slow to make on every step.
+const checkInterval = 1024
+
+// Step counts one step of the run. It fails once the run has taken too many
+// steps, taken too long or been cancelled, and on every step after that.
+func (b *Budget) Step() *Error {
+	if b == nil {
+		return nil
+	}
+	if b.stopped == nil {
+		b.steps++
+		if b.limits.MaxSteps > 0 && b.steps > b.limits.MaxSteps {
+			b.stopped = &Error{Kind: STEP_LIMIT_ERROR, Message: fmt.Sprintf("step limit of %d exceeded", b.limits.MaxSteps)}
+		} else if b.steps%checkInterval == 0 {
+			b.stopped = b.checkTime()
+		}
+		if b.stopped == nil {
+			return nil
+		}
+	}
+	// Each failure gets an error of its own, which collects its own stack.
+	return &Error{Kind: b.stopped.Kind, Message: b.stopped.Message}
+}
+
+// Exhausted tells whether Step has stopped the run. The error it stopped
+// it with can't be caught for good: catch and finally blocks still run, but
+// their first step fails with it again.
+func (b *Budget) Exhausted() bool {
+	return b != nil && b.stopped != nil
+}
+
+func (b *Budget) checkTime() *Error {
+	if err := b.ctx.Err(); err != nil {
+		return &Error{Kind: CANCELLED_ERROR, Message: "execution cancelled: " + err.Error()}
+	}
+	if !b.deadline.IsZero() && time.Now().After(b.deadline) {
+		return &Error{Kind: TIMEOUT_ERROR, Message: fmt.Sprintf("time limit of %s exceeded", b.limits.Timeout)}
+	}
+	return nil
+}
+
+// Enter records the start of a function call and Leave its end, for an
+// interpreter that does not keep count of active calls itself.
+func (b *Budget) Enter() *Error {
+	if b == nil {
+		return nil
+	}
+	if err := b.CheckDepth(b.depth + 1); err != nil {
+		return err
+	}
+	b.depth++
+	return nil
+}
+
+func (b *Budget) Leave() {
+	if b != nil {
+		b.depth--
+	}
+}
+
+// CheckDepth fails if depth calls are too many to be active at once.
+func (b *Budget) CheckDepth(depth int) *Error {
+	if b == nil || b.limits.MaxCallDepth <= 0 || depth <= b.limits.MaxCallDepth {
+		return nil
+	}
+	return &Error{Kind: CALL_DEPTH_ERROR, Message: fmt.Sprintf("call depth limit of %d exceeded", b.limits.MaxCallDepth)}
+}
+
+// CheckString fails if a string of length bytes is too long to build.
+func (b *Budget) CheckString(length int64) *Error {
+	if b == nil || b.limits.MaxStringLength <= 0 || length <= int64(b.limits.MaxStringLength) {
+		return nil
+	}
+	return &Error{Kind: STRING_LIMIT_ERROR, Message: fmt.Sprintf("string of %d bytes exceeds the limit of %d", length, b.limits.MaxStringLength)}
+}
+
+// CheckArray fails if an array of length elements is too long to build.
+func (b *Budget) CheckArray(length int64) *Error {
+	if b == nil || b.limits.MaxArrayLength <= 0 || length <= int64(b.limits.MaxArrayLength) {
+		return nil
+	}
+	return &Error{Kind: ARRAY_LIMIT_ERROR, Message: fmt.Sprintf("array of %d elements exceeds the limit of %d", length, b.limits.MaxArrayLength)}
+}
+
+// CheckValue checks a string or an array that has already been built, such
+// as the result of a builtin.
+func (b *Budget) CheckValue(obj Object) *Error {
+	switch obj := obj.(type) {
+	case *String:
+		return b.CheckString(int64(len(obj.Value)))
+	case *Array:
+		return b.CheckArray(int64(len(obj.Elements)))
+	}
+	return nil
+}
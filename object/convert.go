@@ -0,0 +1,492 @@
+package object
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// FromGo converts a Go value to the object a Buggy program sees:
+//
+//   - nil and nil pointers become null
+//   - bools, integers, floats and strings become the matching objects
+//   - slices and arrays become arrays, nil slices empty ones
+//   - maps with integer, float, bool or string keys become hashes
+//   - structs become hashes of their exported fields, named by the field's
+//     `buggy` tag if it has one; fields tagged `buggy:"-"` are left out
+//   - functions become builtins, as made by NewBuiltin
+//
+// Objects are returned as they are and pointers are followed. FromGo fails
+// for other values, such as channels, for unsigned integers too large for a
+// Buggy integer and for values that contain themselves, through a pointer,
+// map or slice.
+func FromGo(value interface{}) (Object, error) {
+	return fromGoValue(reflect.ValueOf(value), map[visit]bool{})
+}
+
+var objectType = reflect.TypeOf((*Object)(nil)).Elem()
+
+// visit is a pointer, map or slice being converted. The type tells apart a
+// struct and its first field, and the length slices of the same array.
+type visit struct {
+	ptr uintptr
+	typ reflect.Type
+	len int
+}
+
+// fromGoValue converts v. path holds the pointers, maps and slices v is
+// inside of; meeting one of them again means the value contains itself.
+func fromGoValue(v reflect.Value, path map[visit]bool) (Object, error) {
+	if !v.IsValid() {
+		return NULL, nil
+	}
+	if v.Type().Implements(objectType) {
+		if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+			return NULL, nil
+		}
+		return v.Interface().(Object), nil
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice:
+		if v.IsNil() {
+			break
+		}
+		key := visit{ptr: v.Pointer(), typ: v.Type()}
+		if v.Kind() == reflect.Slice {
+			key.len = v.Len()
+		}
+		if path[key] {
+			return nil, fmt.Errorf("%s contains itself", v.Type())
+		}
+		path[key] = true
+		defer delete(path, key)
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return TRUE, nil
+		}
+		return FALSE, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Integer{Value: v.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return nil, fmt.Errorf("%d is too large for an integer", v.Uint())
+		}
+		return &Integer{Value: int64(v.Uint())}, nil
+	case reflect.Float32, reflect.Float64:
+		return &Float{Value: v.Float()}, nil
+	case reflect.String:
+		return &String{Value: v.String()}, nil
+	case reflect.Slice, reflect.Array:
+		elements := make([]Object, v.Len())
+		for i := range elements {
+			element, err := fromGoValue(v.Index(i), path)
+			if err != nil {
+				return nil, fmt.Errorf("element %d: %w", i, err)
+			}
+			elements[i] = element
+		}
+		return &Array{Elements: elements}, nil
+	case reflect.Map:
+		// Go maps have no order, so the keys are sorted to give the hash
+		// the same one every time.
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+		})
+		hash := NewHash()
+		for _, k := range keys {
+			key, err := fromGoValue(k, path)
+			if err != nil {
+				return nil, fmt.Errorf("key %v: %w", k, err)
+			}
+			hashable, ok := key.(Hashable)
+			if !ok {
+				return nil, fmt.Errorf("key %v: unusable as hash key: %s", k, key.Type())
+			}
+			value, err := fromGoValue(v.MapIndex(k), path)
+			if err != nil {
+				return nil, fmt.Errorf("key %v: %w", k, err)
+			}
+			hash.Set(hashable.HashKey(), HashPair{Key: key, Value: value})
+		}
+		return hash, nil
+	case reflect.Struct:
+		hash := NewHash()
+		for i := 0; i < v.NumField(); i++ {
+			name, ok := fieldName(v.Type().Field(i))
+			if !ok {
+				continue
+			}
+			value, err := fromGoValue(v.Field(i), path)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", v.Type().Field(i).Name, err)
+			}
+			setPair(hash, name, value)
+		}
+		return hash, nil
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return NULL, nil
+		}
+		return fromGoValue(v.Elem(), path)
+	case reflect.Func:
+		if v.IsNil() {
+			return NULL, nil
+		}
+		return NewBuiltin(v.Interface())
+	}
+	return nil, fmt.Errorf("cannot convert %s to an object", v.Type())
+}
+
+// fieldName returns the hash key of a struct field, or false if the field
+// is not converted.
+func fieldName(field reflect.StructField) (string, bool) {
+	if field.PkgPath != "" {
+		return "", false
+	}
+	tag := field.Tag.Get("buggy")
+	if tag == "-" {
+		return "", false
+	}
+	if name := strings.Split(tag, ",")[0]; name != "" {
+		return name, true
+	}
+	return field.Name, true
+}
+
+// ToGo converts an object to a plain Go value: int64, float64, bool,
+// string, nil for null, []interface{} for arrays and, for hashes,
+// map[string]interface{} if all their keys are strings and
+// map[interface{}]interface{} otherwise. Other objects, such as functions,
+// are returned as they are. Use ToGoValue to convert to a particular type.
+// ToGo fails for arrays and hashes that contain themselves.
+func ToGo(obj Object) (interface{}, error) {
+	return toGo(obj, map[Object]bool{})
+}
+
+// toGo converts obj. active holds the arrays and hashes obj is inside of;
+// meeting one of them again means the value contains itself.
+func toGo(obj Object, active map[Object]bool) (interface{}, error) {
+	switch obj := obj.(type) {
+	case *Integer:
+		return obj.Value, nil
+	case *Float:
+		return obj.Value, nil
+	case *Boolean:
+		return obj.Value, nil
+	case *String:
+		return obj.Value, nil
+	case *Null:
+		return nil, nil
+	case *Array:
+		if err := enterContainer(obj, active); err != nil {
+			return nil, err
+		}
+		defer delete(active, obj)
+		elements := make([]interface{}, len(obj.Elements))
+		for i, element := range obj.Elements {
+			value, err := toGo(element, active)
+			if err != nil {
+				return nil, fmt.Errorf("element %d: %w", i, err)
+			}
+			elements[i] = value
+		}
+		return elements, nil
+	case *Hash:
+		if err := enterContainer(obj, active); err != nil {
+			return nil, err
+		}
+		defer delete(active, obj)
+		stringKeys := true
+		for _, pair := range obj.Pairs {
+			if _, ok := pair.Key.(*String); !ok {
+				stringKeys = false
+				break
+			}
+		}
+		if stringKeys {
+			m := make(map[string]interface{}, len(obj.Pairs))
+			for _, pair := range obj.Pairs {
+				value, err := toGo(pair.Value, active)
+				if err != nil {
+					return nil, fmt.Errorf("key %s: %w", pair.Key.Inspect(), err)
+				}
+				m[pair.Key.(*String).Value] = value
+			}
+			return m, nil
+		}
+		m := make(map[interface{}]interface{}, len(obj.Pairs))
+		for _, pair := range obj.Pairs {
+			// Keys are hashable, so never arrays or hashes.
+			key, _ := toGo(pair.Key, active)
+			value, err := toGo(pair.Value, active)
+			if err != nil {
+				return nil, fmt.Errorf("key %s: %w", pair.Key.Inspect(), err)
+			}
+			m[key] = value
+		}
+		return m, nil
+	}
+	return obj, nil
+}
+
+// enterContainer adds the array or hash obj to active, unless it is there
+// already because obj contains itself.
+func enterContainer(obj Object, active map[Object]bool) error {
+	if active[obj] {
+		return fmt.Errorf("%s contains itself", strings.ToLower(string(obj.Type())))
+	}
+	active[obj] = true
+	return nil
+}
+
+// ToGoValue stores obj in the Go value target points to, converting it to
+// target's type the opposite way FromGo does. Hash keys missing from a
+// struct's fields are ignored and fields missing from the hash are left
+// alone.
+func ToGoValue(obj Object, target interface{}) error {
+	ptr := reflect.ValueOf(target)
+	if ptr.Kind() != reflect.Ptr || ptr.IsNil() {
+		return fmt.Errorf("ToGoValue needs a non-nil pointer, got %T", target)
+	}
+	v, err := toGoValue(obj, ptr.Type().Elem(), map[Object]bool{})
+	if err != nil {
+		return err
+	}
+	ptr.Elem().Set(v)
+	return nil
+}
+
+// toGoValue converts obj to t. active is as for toGo.
+func toGoValue(obj Object, t reflect.Type, active map[Object]bool) (reflect.Value, error) {
+	if obj == nil {
+		obj = NULL
+	}
+	if t.Kind() == reflect.Interface && t.NumMethod() == 0 {
+		value, err := toGo(obj, active)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if value == nil {
+			return reflect.Zero(t), nil
+		}
+		return reflect.ValueOf(value), nil
+	}
+	if reflect.TypeOf(obj).AssignableTo(t) {
+		return reflect.ValueOf(obj), nil
+	}
+	v := reflect.New(t).Elem()
+	if obj == NULL {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+			return v, nil
+		}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		if b, ok := obj.(*Boolean); ok {
+			v.SetBool(b.Value)
+			return v, nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i, ok := obj.(*Integer); ok {
+			if v.OverflowInt(i.Value) {
+				return v, fmt.Errorf("%d overflows %s", i.Value, t)
+			}
+			v.SetInt(i.Value)
+			return v, nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if i, ok := obj.(*Integer); ok {
+			if i.Value < 0 || v.OverflowUint(uint64(i.Value)) {
+				return v, fmt.Errorf("%d overflows %s", i.Value, t)
+			}
+			v.SetUint(uint64(i.Value))
+			return v, nil
+		}
+	case reflect.Float32, reflect.Float64:
+		switch n := obj.(type) {
+		case *Float:
+			v.SetFloat(n.Value)
+			return v, nil
+		case *Integer:
+			v.SetFloat(float64(n.Value))
+			return v, nil
+		}
+	case reflect.String:
+		if s, ok := obj.(*String); ok {
+			v.SetString(s.Value)
+			return v, nil
+		}
+	case reflect.Slice, reflect.Array:
+		arr, ok := obj.(*Array)
+		if !ok {
+			break
+		}
+		if t.Kind() == reflect.Slice {
+			v = reflect.MakeSlice(t, len(arr.Elements), len(arr.Elements))
+		} else if t.Len() != len(arr.Elements) {
+			return v, fmt.Errorf("cannot use an array of %d elements as %s", len(arr.Elements), t)
+		}
+		if err := enterContainer(arr, active); err != nil {
+			return v, err
+		}
+		defer delete(active, arr)
+		for i, element := range arr.Elements {
+			ev, err := toGoValue(element, t.Elem(), active)
+			if err != nil {
+				return v, fmt.Errorf("element %d: %w", i, err)
+			}
+			v.Index(i).Set(ev)
+		}
+		return v, nil
+	case reflect.Map:
+		hash, ok := obj.(*Hash)
+		if !ok {
+			break
+		}
+		if err := enterContainer(hash, active); err != nil {
+			return v, err
+		}
+		defer delete(active, hash)
+		v = reflect.MakeMapWithSize(t, len(hash.Pairs))
+		for _, pair := range hash.Pairs {
+			key, err := toGoValue(pair.Key, t.Key(), active)
+			if err != nil {
+				return v, fmt.Errorf("key %s: %w", pair.Key.Inspect(), err)
+			}
+			value, err := toGoValue(pair.Value, t.Elem(), active)
+			if err != nil {
+				return v, fmt.Errorf("key %s: %w", pair.Key.Inspect(), err)
+			}
+			v.SetMapIndex(key, value)
+		}
+		return v, nil
+	case reflect.Struct:
+		hash, ok := obj.(*Hash)
+		if !ok {
+			break
+		}
+		for i := 0; i < t.NumField(); i++ {
+			name, ok := fieldName(t.Field(i))
+			if !ok {
+				continue
+			}
+			pair, ok := hash.Pairs[(&String{Value: name}).HashKey()]
+			if !ok {
+				continue
+			}
+			fv, err := toGoValue(pair.Value, t.Field(i).Type, active)
+			if err != nil {
+				return v, fmt.Errorf("field %s: %w", t.Field(i).Name, err)
+			}
+			v.Field(i).Set(fv)
+		}
+		return v, nil
+	case reflect.Ptr:
+		ev, err := toGoValue(obj, t.Elem(), active)
+		if err != nil {
+			return v, err
+		}
+		v = reflect.New(t.Elem())
+		v.Elem().Set(ev)
+		return v, nil
+	}
+	return v, fmt.Errorf("cannot use %s as %s", obj.Type(), t)
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// NewBuiltin wraps fn, which must be a Go function, as a builtin. The
+// builtin checks that it gets as many arguments as fn has parameters and
+// converts each with ToGoValue, failing with an ArgumentError or a
+// TypeError if it can't. Parameters of type Object get the arguments as
+// they are.
+//
+// fn may return nothing, which the builtin returns as null, one value,
+// which is converted with FromGo, or a value and an error. A non-nil error
+// is returned as a RuntimeError.
+func NewBuiltin(fn interface{}) (*Builtin, error) {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return nil, fmt.Errorf("NewBuiltin needs a function, got %T", fn)
+	}
+	t := v.Type()
+	switch {
+	case t.NumOut() > 2:
+		return nil, fmt.Errorf("cannot wrap %s: too many results", t)
+	case t.NumOut() == 2 && t.Out(1) != errorType:
+		return nil, fmt.Errorf("cannot wrap %s: second result is not an error", t)
+	}
+	return &Builtin{Fn: func(args ...Object) Object {
+		in, err := builtinArgs(t, args)
+		if err != nil {
+			return err
+		}
+		var out []reflect.Value
+		if t.IsVariadic() {
+			out = v.CallSlice(in)
+		} else {
+			out = v.Call(in)
+		}
+		return builtinResult(t, out)
+	}}, nil
+}
+
+func builtinArgs(t reflect.Type, args []Object) ([]reflect.Value, *Error) {
+	fixed := t.NumIn()
+	if t.IsVariadic() {
+		fixed--
+		if len(args) < fixed {
+			return nil, &Error{Kind: ARGUMENT_ERROR,
+				Message: fmt.Sprintf("wrong number of arguments. got=%d, want>=%d", len(args), fixed)}
+		}
+	} else if len(args) != fixed {
+		return nil, &Error{Kind: ARGUMENT_ERROR,
+			Message: fmt.Sprintf("wrong number of arguments. got=%d, want=%d", len(args), fixed)}
+	}
+	in := make([]reflect.Value, t.NumIn())
+	for i := 0; i < fixed; i++ {
+		arg, err := toGoValue(args[i], t.In(i), map[Object]bool{})
+		if err != nil {
+			return nil, &Error{Kind: TYPE_ERROR, Message: fmt.Sprintf("argument %d: %s", i+1, err)}
+		}
+		in[i] = arg
+	}
+	if t.IsVariadic() {
+		rest := reflect.MakeSlice(t.In(fixed), len(args)-fixed, len(args)-fixed)
+		for i := fixed; i < len(args); i++ {
+			arg, err := toGoValue(args[i], t.In(fixed).Elem(), map[Object]bool{})
+			if err != nil {
+				return nil, &Error{Kind: TYPE_ERROR, Message: fmt.Sprintf("argument %d: %s", i+1, err)}
+			}
+			rest.Index(i - fixed).Set(arg)
+		}
+		in[fixed] = rest
+	}
+	return in, nil
+}
+
+func builtinResult(t reflect.Type, out []reflect.Value) Object {
+	if len(out) == 0 {
+		return NULL
+	}
+	last := out[len(out)-1]
+	if t.Out(len(out)-1) == errorType {
+		if !last.IsNil() {
+			return &Error{Kind: RUNTIME_ERROR, Message: last.Interface().(error).Error()}
+		}
+		if len(out) == 1 {
+			return NULL
+		}
+	}
+	obj, err := fromGoValue(out[0], map[visit]bool{})
+	if err != nil {
+		return &Error{Kind: TYPE_ERROR, Message: "result: " + err.Error()}
+	}
+	return obj
+}
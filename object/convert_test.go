@@ -0,0 +1,252 @@
+package object
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type order struct {
+	ID     int      `buggy:"id"`
+	Items  []string `buggy:"items"`
+	Paid   bool
+	Secret string `buggy:"-"`
+	note   string
+}
+
+func TestFromGo(t *testing.T) {
+	tests := []struct {
+		input    interface{}
+		expected string
+	}{
+		{nil, "null"},
+		{42, "42"},
+		{uint8(7), "7"},
+		{2.5, "2.5"},
+		{true, "true"},
+		{"hi", "hi"},
+		{[]int{1, 2}, "[1, 2]"},
+		{[]string(nil), "[]"},
+		{[2]bool{true, false}, "[true, false]"},
+		{map[string]int{"a": 1}, "{a: 1}"},
+		{(*order)(nil), "null"},
+		{&String{Value: "as is"}, "as is"},
+	}
+	for _, tt := range tests {
+		obj, err := FromGo(tt.input)
+		if err != nil {
+			t.Errorf("FromGo(%#v) failed: %s", tt.input, err)
+			continue
+		}
+		if obj.Inspect() != tt.expected {
+			t.Errorf("FromGo(%#v) = %s, want %s", tt.input, obj.Inspect(), tt.expected)
+		}
+	}
+
+	obj, err := FromGo(order{ID: 3, Items: []string{"tea"}, Paid: true, Secret: "x", note: "y"})
+	if err != nil {
+		t.Fatalf("FromGo failed: %s", err)
+	}
+	hash, ok := obj.(*Hash)
+	if !ok || len(hash.Pairs) != 3 {
+		t.Fatalf("FromGo(order) = %s", obj.Inspect())
+	}
+	for key, expected := range map[string]string{"id": "3", "items": "[tea]", "Paid": "true"} {
+		pair, ok := hash.Pairs[(&String{Value: key}).HashKey()]
+		if !ok || pair.Value.Inspect() != expected {
+			t.Errorf("FromGo(order)[%q] is wrong in %s", key, obj.Inspect())
+		}
+	}
+
+	if obj, _ := FromGo(false); obj != FALSE {
+		t.Errorf("FromGo(false) is not FALSE")
+	}
+	for _, input := range []interface{}{uint64(math.MaxUint64), make(chan int), map[string]interface{}{"c": make(chan int)}} {
+		if _, err := FromGo(input); err == nil {
+			t.Errorf("FromGo(%#v) did not fail", input)
+		}
+	}
+}
+
+func TestFromGoCycles(t *testing.T) {
+	type node struct {
+		Next *node
+	}
+	loop := &node{}
+	loop.Next = loop
+	m := map[string]interface{}{}
+	m["self"] = m
+	s := []interface{}{nil}
+	s[0] = s
+	for _, input := range []interface{}{loop, m, s} {
+		_, err := FromGo(input)
+		if err == nil || !strings.Contains(err.Error(), "contains itself") {
+			t.Errorf("FromGo(%T) with a cycle: got err=%v", input, err)
+		}
+	}
+
+	// A value reached twice without a cycle is converted both times.
+	shared := &node{}
+	obj, err := FromGo([]*node{shared, shared})
+	if err != nil {
+		t.Fatalf("FromGo with a shared value failed: %s", err)
+	}
+	if obj.Inspect() != "[{Next: null}, {Next: null}]" {
+		t.Errorf("wrong conversion of a shared value: %s", obj.Inspect())
+	}
+}
+
+func TestToGo(t *testing.T) {
+	obj := &Array{Elements: []Object{
+		&Integer{Value: 1}, &Float{Value: 1.5}, TRUE, &String{Value: "s"}, NULL,
+	}}
+	expected := []interface{}{int64(1), 1.5, true, "s", nil}
+	if got, err := ToGo(obj); err != nil || !reflect.DeepEqual(got, expected) {
+		t.Errorf("ToGo(%s) = %#v, want %#v", obj.Inspect(), got, expected)
+	}
+
+	hash := &Hash{Pairs: map[HashKey]HashPair{}}
+	setPair(hash, "a", &Integer{Value: 1})
+	if got, err := ToGo(hash); err != nil || !reflect.DeepEqual(got, map[string]interface{}{"a": int64(1)}) {
+		t.Errorf("ToGo(%s) = %#v", hash.Inspect(), got)
+	}
+	one := &Integer{Value: 1}
+	hash.Pairs[one.HashKey()] = HashPair{Key: one, Value: TRUE}
+	if got, err := ToGo(hash); err != nil || !reflect.DeepEqual(got, map[interface{}]interface{}{"a": int64(1), int64(1): true}) {
+		t.Errorf("ToGo(%s) = %#v", hash.Inspect(), got)
+	}
+}
+
+func TestToGoSelfContaining(t *testing.T) {
+	arr := &Array{Elements: []Object{&Integer{Value: 1}}}
+	arr.Elements = append(arr.Elements, arr)
+	if _, err := ToGo(arr); err == nil || err.Error() != "element 1: array contains itself" {
+		t.Errorf("wrong error for a self-containing array: %v", err)
+	}
+	var decoded []interface{}
+	if err := ToGoValue(arr, &decoded); err == nil {
+		t.Errorf("ToGoValue accepted a self-containing array")
+	}
+
+	hash := &Hash{Pairs: map[HashKey]HashPair{}}
+	setPair(hash, "self", hash)
+	if _, err := ToGo(hash); err == nil || err.Error() != "key self: hash contains itself" {
+		t.Errorf("wrong error for a self-containing hash: %v", err)
+	}
+	var m map[string]interface{}
+	if err := ToGoValue(hash, &m); err == nil {
+		t.Errorf("ToGoValue accepted a self-containing hash")
+	}
+
+	// A value shared by two elements is not a cycle.
+	shared := &Array{Elements: []Object{&Integer{Value: 1}}}
+	if _, err := ToGo(&Array{Elements: []Object{shared, shared}}); err != nil {
+		t.Errorf("ToGo rejected a shared value: %s", err)
+	}
+}
+
+func TestToGoValue(t *testing.T) {
+	original := order{ID: 3, Items: []string{"tea", "cake"}, Paid: true}
+	obj, err := FromGo(original)
+	if err != nil {
+		t.Fatalf("FromGo failed: %s", err)
+	}
+	var decoded order
+	if err := ToGoValue(obj, &decoded); err != nil {
+		t.Fatalf("ToGoValue failed: %s", err)
+	}
+	if !reflect.DeepEqual(decoded, original) {
+		t.Errorf("round trip gave %#v, want %#v", decoded, original)
+	}
+
+	var counts map[string]*int8
+	hash, _ := FromGo(map[string]interface{}{"a": 1, "b": nil})
+	if err := ToGoValue(hash, &counts); err != nil {
+		t.Fatalf("ToGoValue failed: %s", err)
+	}
+	if len(counts) != 2 || *counts["a"] != 1 || counts["b"] != nil {
+		t.Errorf("wrong map %#v", counts)
+	}
+
+	failures := []struct {
+		obj    Object
+		target interface{}
+	}{
+		{&Integer{Value: 300}, new(int8)},
+		{&Integer{Value: -1}, new(uint)},
+		{&String{Value: "1"}, new(int)},
+		{&Array{Elements: []Object{TRUE}}, new([]string)},
+		{&Array{Elements: []Object{}}, new([1]int)},
+		{NULL, new(int)},
+	}
+	for _, tt := range failures {
+		if err := ToGoValue(tt.obj, tt.target); err == nil {
+			t.Errorf("ToGoValue(%s, %T) did not fail", tt.obj.Inspect(), tt.target)
+		}
+	}
+}
+
+func TestNewBuiltin(t *testing.T) {
+	add, err := NewBuiltin(func(a, b int) int { return a + b })
+	if err != nil {
+		t.Fatalf("NewBuiltin failed: %s", err)
+	}
+	testBuiltinResult(t, add.Fn(&Integer{Value: 2}, &Integer{Value: 3}), "5")
+	testBuiltinError(t, add.Fn(&Integer{Value: 2}), ARGUMENT_ERROR)
+	testBuiltinError(t, add.Fn(&Integer{Value: 2}, &String{Value: "3"}), TYPE_ERROR)
+
+	join, _ := NewBuiltin(func(sep string, parts ...string) string {
+		out := ""
+		for i, part := range parts {
+			if i > 0 {
+				out += sep
+			}
+			out += part
+		}
+		return out
+	})
+	testBuiltinResult(t, join.Fn(&String{Value: "-"}, &String{Value: "a"}, &String{Value: "b"}), "a-b")
+	testBuiltinResult(t, join.Fn(&String{Value: "-"}), "")
+	testBuiltinError(t, join.Fn(), ARGUMENT_ERROR)
+
+	check, _ := NewBuiltin(func(o order) (bool, error) {
+		if len(o.Items) == 0 {
+			return false, errors.New("empty order")
+		}
+		return o.Paid, nil
+	})
+	paid, _ := FromGo(order{Items: []string{"tea"}, Paid: true})
+	testBuiltinResult(t, check.Fn(paid), "true")
+	empty, _ := FromGo(order{})
+	testBuiltinError(t, check.Fn(empty), RUNTIME_ERROR)
+
+	raw, _ := NewBuiltin(func(obj Object) {})
+	testBuiltinResult(t, raw.Fn(&Fn{}), "null")
+
+	for _, fn := range []interface{}{nil, 42, func() (int, int) { return 1, 2 }} {
+		if _, err := NewBuiltin(fn); err == nil {
+			t.Errorf("NewBuiltin(%T) did not fail", fn)
+		}
+	}
+}
+
+func testBuiltinResult(t *testing.T, obj Object, expected string) {
+	t.Helper()
+	if _, ok := obj.(*Error); ok || obj.Inspect() != expected {
+		t.Errorf("builtin returned %s, want %s", obj.Inspect(), expected)
+	}
+}
+
+func testBuiltinError(t *testing.T, obj Object, kind ErrorKind) {
+	t.Helper()
+	err, ok := obj.(*Error)
+	if !ok {
+		t.Errorf("builtin returned %s, want a %s", obj.Inspect(), kind)
+		return
+	}
+	if err.Kind != kind {
+		t.Errorf("builtin returned a %s (%s), want a %s", err.Kind, err.Message, kind)
+	}
+}
@@ -0,0 +1,73 @@
+package object
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Module is what `import` binds: the names a file exports, which are read
+// from the globals of the file whenever they are used, so that importers
+// see assignments made to them later on.
+type Module struct {
+	Path    string
+	Exports map[string]bool
+	Globals Bindings
+}
+
+// Bindings are the globals of a file: an *Environment in the evaluator and
+// a *Unit in the vm.
+type Bindings interface {
+	Get(name string) (Object, bool)
+}
+
+// Get returns the current value of the export name.
+func (m *Module) Get(name string) (Object, bool) {
+	if !m.Exports[name] {
+		return nil, false
+	}
+	return m.Globals.Get(name)
+}
+
+func (m *Module) Type() ObjectType { return MODULE_OBJ }
+
+func (m *Module) Inspect() string { return "module " + strconv.Quote(m.Path) }
+
+// Modules keeps the modules imported by the runs that share it, so that
+// each file runs once, and the chain of files being imported, to catch
+// files that import each other.
+type Modules struct {
+	loaded  map[string]*Module
+	loading []string
+}
+
+func NewModules() *Modules {
+	return &Modules{loaded: map[string]*Module{}}
+}
+
+// Get returns the module already imported from path.
+func (m *Modules) Get(path string) (*Module, bool) {
+	module, ok := m.loaded[path]
+	return module, ok
+}
+
+// Begin records that the file at path is about to run. It fails if the file
+// is already running, waiting for an import that led back to it.
+func (m *Modules) Begin(path string) *Error {
+	for i, loading := range m.loading {
+		if loading == path {
+			cycle := append(append([]string{}, m.loading[i:]...), path)
+			return &Error{Kind: IMPORT_ERROR, Message: "import cycle: " + strings.Join(cycle, " -> ")}
+		}
+	}
+	m.loading = append(m.loading, path)
+	return nil
+}
+
+// End records that the file Begin was last called for has run. The module
+// is kept for later imports unless it is nil, after an error.
+func (m *Modules) End(path string, module *Module) {
+	m.loading = m.loading[:len(m.loading)-1]
+	if module != nil {
+		m.loaded[path] = module
+	}
+}
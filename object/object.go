@@ -4,15 +4,21 @@ import (
 	"bytes"
 	"fmt"
 	"hash/fnv"
+	"math"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/smiksha1701/buggy/ast"
+	"github.com/smiksha1701/buggy/code"
+	"github.com/smiksha1701/buggy/token"
 )
 
 type ObjectType string
 
 const (
 	INTEGER_OBJ      = "INTEGER"
+	FLOAT_OBJ        = "FLOAT"
 	BOOLEAN_OBJ      = "BOOLEAN"
 	NULL_OBJ         = "NULL"
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
@@ -22,12 +28,38 @@ const (
 	BUILTIN_OBJ      = "BUILTIN"
 	ARRAY_OBJ        = "ARRAY"
 	HASH_OBJ         = "HASH"
+	BREAK_OBJ        = "BREAK"
+	CONTINUE_OBJ     = "CONTINUE"
+	ITERATOR_OBJ     = "ITERATOR"
+	MODULE_OBJ       = "MODULE"
+
+	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION"
 )
 
 type BuiltinFunction func(args ...Object) Object
 
+// Runtime is what the interpreter running a builtin offers it: a way to call
+// the functions it is given and the budget of the run.
+type Runtime interface {
+	// Call calls fn, a Buggy function or a builtin, with args.
+	Call(fn Object, args ...Object) Object
+	Budget() *Budget
+}
+
 type Builtin struct {
 	Fn BuiltinFunction
+	// RuntimeFn is used instead of Fn, if set, by builtins that call
+	// functions, such as `map`, or that check the budget before they
+	// allocate.
+	RuntimeFn func(rt Runtime, args ...Object) Object
+}
+
+// Call runs the builtin for an interpreter.
+func (b *Builtin) Call(rt Runtime, args ...Object) Object {
+	if b.RuntimeFn != nil {
+		return b.RuntimeFn(rt, args...)
+	}
+	return b.Fn(args...)
 }
 
 func (b *Builtin) Inspect() string { return "builtin function" }
@@ -55,6 +87,22 @@ func (i *Integer) Inspect() string { return fmt.Sprintf("%d", i.Value) }
 
 func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
 
+type Float struct {
+	Value float64
+}
+
+// Inspect always keeps a decimal point or an exponent so floats can't be
+// mistaken for integers when printed.
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eIN") {
+		s += ".0"
+	}
+	return s
+}
+
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+
 type Boolean struct {
 	Value bool
 }
@@ -66,6 +114,15 @@ func (b *Boolean) Type() ObjectType { return BOOLEAN_OBJ }
 type Null struct {
 }
 
+// NULL, TRUE and FALSE are the only null and boolean objects. The
+// interpreters tell them apart by identity, so code that makes objects for
+// them, such as FromGo, must use these.
+var (
+	NULL  = &Null{}
+	TRUE  = &Boolean{Value: true}
+	FALSE = &Boolean{Value: false}
+)
+
 func (n *Null) Inspect() string { return "null" }
 
 func (n *Null) Type() ObjectType { return NULL_OBJ }
@@ -78,29 +135,267 @@ func (rv *ReturnValue) Inspect() string { return rv.Value.Inspect() }
 
 func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
 
+// Break and Continue travel up from a break or continue statement to the
+// loop that handles them, the way ReturnValue travels up to a function.
+type Break struct{}
+
+func (b *Break) Inspect() string { return "break" }
+
+func (b *Break) Type() ObjectType { return BREAK_OBJ }
+
+type Continue struct{}
+
+func (c *Continue) Inspect() string { return "continue" }
+
+func (c *Continue) Type() ObjectType { return CONTINUE_OBJ }
+
+// ErrorKind classifies runtime errors, like ObjectType does for objects.
+type ErrorKind string
+
+const (
+	RUNTIME_ERROR        ErrorKind = "RuntimeError"
+	TYPE_ERROR           ErrorKind = "TypeError"
+	NAME_ERROR           ErrorKind = "NameError"
+	INDEX_ERROR          ErrorKind = "IndexError"
+	VALUE_ERROR          ErrorKind = "ValueError"
+	ARGUMENT_ERROR       ErrorKind = "ArgumentError"
+	ZERO_DIVISION_ERROR  ErrorKind = "ZeroDivisionError"
+	STACK_OVERFLOW_ERROR ErrorKind = "StackOverflowError"
+	IMPORT_ERROR         ErrorKind = "ImportError"
+	// THROWN_ERROR is the kind of errors thrown by scripts without one.
+	THROWN_ERROR ErrorKind = "Error"
+	// The kinds of errors that stop a run at one of its Limits.
+	STEP_LIMIT_ERROR   ErrorKind = "StepLimitError"
+	CALL_DEPTH_ERROR   ErrorKind = "CallDepthError"
+	STRING_LIMIT_ERROR ErrorKind = "StringLimitError"
+	ARRAY_LIMIT_ERROR  ErrorKind = "ArrayLimitError"
+	TIMEOUT_ERROR      ErrorKind = "TimeoutError"
+	CANCELLED_ERROR    ErrorKind = "CancelledError"
+)
+
+// StackFrame is a call of a Buggy function that was active when an error
+// was raised. Function is empty for anonymous functions and Pos is the
+// position of the call.
+type StackFrame struct {
+	Function string
+	Pos      token.Position
+	// Elided is the number of calls the frame stands for when it replaces
+	// a run of tail calls too long to keep, and 0 otherwise.
+	Elided int
+}
+
 type Error struct {
+	Kind    ErrorKind
 	Message string
+	// Pos is the position of the node that raised the error, if known.
+	Pos token.Position
+	// Stack lists the function calls the error unwound, innermost first.
+	Stack []StackFrame
+	// Value is the value given to throw, or nil for errors raised by the
+	// interpreter itself.
+	Value Object
 }
 
-func (e *Error) Inspect() string { return "ERROR: " + e.Message }
+// NewThrownError makes the error raised by `throw value`. A thrown hash may
+// set the error's "kind" and "message"; any other value is the message.
+func NewThrownError(value Object) *Error {
+	err := &Error{Kind: THROWN_ERROR, Message: value.Inspect(), Value: value}
+	switch value := value.(type) {
+	case *String:
+		err.Message = value.Value
+	case *Hash:
+		if kind, ok := hashString(value, "kind"); ok {
+			err.Kind = ErrorKind(kind)
+		}
+		if message, ok := hashString(value, "message"); ok {
+			err.Message = message
+		}
+	}
+	return err
+}
+
+func hashString(h *Hash, key string) (string, bool) {
+	pair, ok := h.Pairs[(&String{Value: key}).HashKey()]
+	if !ok {
+		return "", false
+	}
+	s, ok := pair.Value.(*String)
+	if !ok {
+		return "", false
+	}
+	return s.Value, true
+}
+
+// ToHash returns what a catch clause binds for the error: a hash with its
+// "kind", "message", "position" and "stack", the last being a list of
+// "function (position)" strings, innermost first. The other keys of a
+// thrown hash are kept and any other thrown value is under "value".
+func (e *Error) ToHash() *Hash {
+	h := NewHash()
+	if thrown, ok := e.Value.(*Hash); ok {
+		h = thrown.Copy()
+	} else if e.Value != nil {
+		setPair(h, "value", e.Value)
+	}
+	kind := e.Kind
+	if kind == "" {
+		kind = RUNTIME_ERROR
+	}
+	stack := &Array{Elements: []Object{}}
+	pos := e.Pos
+	for _, frame := range e.Stack {
+		line := functionName(frame.Function) + " (" + pos.String() + ")"
+		if frame.Elided > 0 {
+			line = fmt.Sprintf("(%d tail calls)", frame.Elided)
+		}
+		stack.Elements = append(stack.Elements, &String{Value: line})
+		pos = frame.Pos
+	}
+	setPair(h, "kind", &String{Value: string(kind)})
+	setPair(h, "message", &String{Value: e.Message})
+	setPair(h, "position", &String{Value: e.Pos.String()})
+	setPair(h, "stack", stack)
+	return h
+}
+
+func setPair(h *Hash, key string, value Object) {
+	k := &String{Value: key}
+	h.Set(k.HashKey(), HashPair{Key: k, Value: value})
+}
+
+func functionName(name string) string {
+	if name == "" {
+		return "<anonymous>"
+	}
+	return name
+}
+
+// Error lets Go code that runs Buggy programs return an *Error as an error.
+func (e *Error) Error() string {
+	kind := e.Kind
+	if kind == "" {
+		kind = RUNTIME_ERROR
+	}
+	if e.Pos.IsValid() {
+		return e.Pos.String() + ": " + string(kind) + ": " + e.Message
+	}
+	return string(kind) + ": " + e.Message
+}
+
+func (e *Error) Inspect() string {
+	if e.Pos.IsValid() {
+		return "ERROR: " + e.Pos.String() + ": " + e.Message
+	}
+	return "ERROR: " + e.Message
+}
+
+// Traceback formats the error the way the REPL and `buggy run` report it:
+// the kind and message followed by one line per active function, innermost
+// first, ending with the top level of the program.
+func (e *Error) Traceback() string {
+	var out bytes.Buffer
+	kind := e.Kind
+	if kind == "" {
+		kind = RUNTIME_ERROR
+	}
+	out.WriteString(string(kind) + ": " + e.Message + "\n")
+	pos := e.Pos
+	for i, frame := range e.Stack {
+		// Runaway recursion leaves thousands of identical frames, so
+		// only the ends of a long stack are shown.
+		if len(e.Stack) > 2*tracebackEnds && i >= tracebackEnds && i < len(e.Stack)-tracebackEnds {
+			if i == tracebackEnds {
+				fmt.Fprintf(&out, "    ... %d more calls ...\n", len(e.Stack)-2*tracebackEnds)
+			}
+			pos = frame.Pos
+			continue
+		}
+		if frame.Elided > 0 {
+			fmt.Fprintf(&out, "    ... %d tail calls ...\n", frame.Elided)
+			pos = frame.Pos
+			continue
+		}
+		writeTraceLine(&out, functionName(frame.Function), pos)
+		pos = frame.Pos
+	}
+	writeTraceLine(&out, "<main>", pos)
+	return out.String()
+}
+
+// tracebackEnds is how many frames Traceback shows at each end of a long
+// stack.
+const tracebackEnds = 10
+
+func writeTraceLine(out *bytes.Buffer, function string, pos token.Position) {
+	out.WriteString("    at " + function)
+	if pos.IsValid() {
+		out.WriteString(" (" + pos.String() + ")")
+	}
+	out.WriteString("\n")
+}
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
 
 func NewEnvironment() *Environment {
 	s := make(map[string]Object)
-	return &Environment{store: s, outer: nil}
+	return &Environment{store: s, outer: nil, run: &RunState{}}
 }
 
 type Environment struct {
 	store map[string]Object
 	outer *Environment
+	// run is shared by all the environments of a run, including those of
+	// the modules it imports, which are not enclosed by the importer's.
+	run *RunState
+}
+
+// RunState is what the code of a run shares across files: the budget of
+// the run, the modules imported so far and the builtins the host adds.
+type RunState struct {
+	budget  *Budget
+	modules *Modules
+	host    map[string]Object
+}
+
+// Budget returns the budget of the run using the environment, or nil.
+func (e *Environment) Budget() *Budget {
+	return e.run.budget
+}
+
+// SetBudget limits the runs that use the environment or any environment
+// enclosed by it, such as those of the functions defined there. A nil
+// budget removes the limits.
+func (e *Environment) SetBudget(b *Budget) {
+	e.run.budget = b
+}
+
+// Modules returns the modules imported by the runs that use the
+// environment.
+func (e *Environment) Modules() *Modules {
+	if e.run.modules == nil {
+		e.run.modules = NewModules()
+	}
+	return e.run.modules
+}
+
+// SetHost defines name for all the code of the runs that use the
+// environment, including the modules they import. Host names are found
+// after those the code defines, which can shadow them, and can't be
+// assigned to.
+func (e *Environment) SetHost(name string, val Object) {
+	if e.run.host == nil {
+		e.run.host = make(map[string]Object)
+	}
+	e.run.host[name] = val
 }
 
 func (e *Environment) Get(name string) (Object, bool) {
-	obj, ok := e.store[name]
-	if !ok && e.outer != nil {
-		obj, ok = e.outer.Get(name)
+	for env := e; env != nil; env = env.outer {
+		if obj, ok := env.store[name]; ok {
+			return obj, true
+		}
 	}
+	obj, ok := e.run.host[name]
 	return obj, ok
 }
 
@@ -109,10 +404,23 @@ func (e *Environment) Set(name string, val Object) Object {
 	return val
 }
 
+// Assign updates an existing binding of name in e or the closest enclosing
+// environment that has one. It reports false if name was never declared.
+func (e *Environment) Assign(name string, val Object) (Object, bool) {
+	for env := e; env != nil; env = env.outer {
+		if _, ok := env.store[name]; ok {
+			env.store[name] = val
+			return val, true
+		}
+	}
+	return nil, false
+}
+
 type Fn struct {
 	Parameters []*ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
+	Name       string
 }
 
 func (f *Fn) Type() ObjectType { return FN_OBJ }
@@ -134,10 +442,80 @@ func (f *Fn) Inspect() string {
 	return out.String()
 }
 
+// CompiledFunction is a function literal turned into bytecode by the
+// compiler. LocalNames holds the name of every local slot, parameters first.
+type CompiledFunction struct {
+	Instructions  code.Instructions
+	NumLocals     int
+	NumParameters int
+	LocalNames    []string
+	Name          string
+	SourceMap     code.SourceMap
+}
+
+func (cf *CompiledFunction) Type() ObjectType { return COMPILED_FUNCTION_OBJ }
+
+func (cf *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("compiled fn(%s)", strings.Join(cf.LocalNames[:cf.NumParameters], ", "))
+}
+
+// Scope holds the locals of one function call in the virtual machine. Like
+// Environment it links to the scope the function was defined in, so closures
+// share variables with the call that created them.
+type Scope struct {
+	Slots []Object
+	Names []string
+	Outer *Scope
+}
+
+// Closure is the virtual machine's counterpart of Fn. It reports the same
+// type as Fn, so Buggy code can't tell the two apart. Unit is the file the
+// closure was made in, whose constants and globals its instructions use
+// wherever it is called.
+type Closure struct {
+	Fn    *CompiledFunction
+	Scope *Scope
+	Unit  *Unit
+}
+
+// Unit holds what the instructions compiled from one file refer to by
+// index: its constants and, while it runs in the vm, its globals.
+// ScopedGlobals marks the globals of names bound in a block, such as a
+// catch parameter, which are not visible from outside it.
+type Unit struct {
+	Constants     []Object
+	Globals       []Object
+	GlobalNames   []string
+	ScopedGlobals map[int]bool
+}
+
+// Get returns the value of the global name, if it has one.
+func (u *Unit) Get(name string) (Object, bool) {
+	for i, global := range u.GlobalNames {
+		if global == name && u.Globals[i] != nil && !u.ScopedGlobals[i] {
+			return u.Globals[i], true
+		}
+	}
+	return nil, false
+}
+
+func (c *Closure) Type() ObjectType { return FN_OBJ }
+
+func (c *Closure) Inspect() string {
+	return fmt.Sprintf("fn(%s) {...}", strings.Join(c.Fn.LocalNames[:c.Fn.NumParameters], ", "))
+}
+
 func NewEnclosedEnv(outer *Environment) *Environment {
-	env := NewEnvironment()
-	env.outer = outer
-	return env
+	s := make(map[string]Object)
+	return &Environment{store: s, outer: outer, run: outer.run}
+}
+
+// NewModuleEnv returns the environment for a module imported by code in
+// importer. It shares the run of importer, with its budget, modules and
+// host names, but does not see any name importer's code defines.
+func NewModuleEnv(importer *Environment) *Environment {
+	s := make(map[string]Object)
+	return &Environment{store: s, run: importer.run}
 }
 
 type Array struct {
@@ -146,12 +524,23 @@ type Array struct {
 
 func (a *Array) Type() ObjectType { return ARRAY_OBJ }
 
-func (a *Array) Inspect() string {
+func (a *Array) Inspect() string { return a.inspect(map[Object]bool{}) }
+
+// inspect prints a. active holds the arrays and hashes being printed, so
+// that a container holding itself prints as [...] or {...} the second
+// time instead of recursing forever.
+func (a *Array) inspect(active map[Object]bool) string {
+	if active[a] {
+		return "[...]"
+	}
+	active[a] = true
+	defer delete(active, a)
+
 	var out bytes.Buffer
 
 	elements := []string{}
 	for _, e := range a.Elements {
-		elements = append(elements, e.Inspect())
+		elements = append(elements, inspectIn(e, active))
 	}
 	out.WriteString("[")
 	out.WriteString(strings.Join(elements, ", "))
@@ -180,6 +569,16 @@ func (i *Integer) HashKey() HashKey {
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
 
+// HashKey of a float is its IEEE 754 bit pattern, with -0.0 folded into 0.0.
+// Floats and integers are distinct keys, so 1.0 and 1 don't collide.
+func (f *Float) HashKey() HashKey {
+	value := f.Value
+	if value == 0 {
+		value = 0
+	}
+	return HashKey{Type: f.Type(), Value: math.Float64bits(value)}
+}
+
 func (s *String) HashKey() HashKey {
 	h := fnv.New64a()
 	h.Write([]byte(s.Value))
@@ -191,17 +590,103 @@ type HashPair struct {
 	Value Object
 }
 
+// Hash keeps its pairs in the order their keys were added, which is the
+// order Inspect, for loops and the builtins that list them use.
 type Hash struct {
+	// Pairs may be read directly, but must only be changed with Set and
+	// Delete, which keep track of the order.
 	Pairs map[HashKey]HashPair
+	keys  []HashKey
+}
+
+func NewHash() *Hash {
+	return &Hash{Pairs: map[HashKey]HashPair{}}
+}
+
+// Set stores pair under key. A key that is already in the hash keeps its
+// place.
+func (h *Hash) Set(key HashKey, pair HashPair) {
+	if h.Pairs == nil {
+		h.Pairs = map[HashKey]HashPair{}
+	}
+	if _, ok := h.Pairs[key]; !ok {
+		h.keys = append(h.keys, key)
+	}
+	h.Pairs[key] = pair
+}
+
+// Delete removes key from the hash and reports whether it was there.
+func (h *Hash) Delete(key HashKey) bool {
+	if _, ok := h.Pairs[key]; !ok {
+		return false
+	}
+	delete(h.Pairs, key)
+	for i, k := range h.keys {
+		if k == key {
+			h.keys = append(h.keys[:i:i], h.keys[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+// Copy returns a new hash with the same pairs in the same order.
+func (h *Hash) Copy() *Hash {
+	c := &Hash{Pairs: make(map[HashKey]HashPair, len(h.Pairs))}
+	for _, pair := range h.OrderedPairs() {
+		c.Set(pair.Key.(Hashable).HashKey(), pair)
+	}
+	return c
+}
+
+// OrderedPairs returns the pairs of the hash in the order their keys were
+// added.
+func (h *Hash) OrderedPairs() []HashPair {
+	pairs := make([]HashPair, 0, len(h.Pairs))
+	if len(h.keys) == len(h.Pairs) {
+		for _, k := range h.keys {
+			pairs = append(pairs, h.Pairs[k])
+		}
+		return pairs
+	}
+	// Pairs was changed directly, as by Go code that builds a hash
+	// literal, so the order is incomplete. Keys it doesn't know come last,
+	// sorted to keep the order the same from run to run.
+	seen := make(map[HashKey]bool, len(h.Pairs))
+	for _, k := range h.keys {
+		if pair, ok := h.Pairs[k]; ok && !seen[k] {
+			seen[k] = true
+			pairs = append(pairs, pair)
+		}
+	}
+	rest := make([]HashPair, 0, len(h.Pairs)-len(pairs))
+	for k, pair := range h.Pairs {
+		if !seen[k] {
+			rest = append(rest, pair)
+		}
+	}
+	sort.Slice(rest, func(i, j int) bool {
+		return rest[i].Key.Inspect() < rest[j].Key.Inspect()
+	})
+	return append(pairs, rest...)
 }
 
 func (h *Hash) Type() ObjectType { return HASH_OBJ }
-func (h *Hash) Inspect() string {
+func (h *Hash) Inspect() string  { return h.inspect(map[Object]bool{}) }
+
+// inspect prints h. active is as for Array.inspect.
+func (h *Hash) inspect(active map[Object]bool) string {
+	if active[h] {
+		return "{...}"
+	}
+	active[h] = true
+	defer delete(active, h)
+
 	var out bytes.Buffer
 
 	pairs := []string{}
-	for _, pair := range h.Pairs {
-		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect()))
+	for _, pair := range h.OrderedPairs() {
+		pairs = append(pairs, fmt.Sprintf("%s: %s", pair.Key.Inspect(), inspectIn(pair.Value, active)))
 	}
 	out.WriteString("{")
 	out.WriteString(strings.Join(pairs, ", "))
@@ -210,6 +695,80 @@ func (h *Hash) Inspect() string {
 	return out.String()
 }
 
+// inspectIn prints obj as an element of the containers in active.
+func inspectIn(obj Object, active map[Object]bool) string {
+	switch obj := obj.(type) {
+	case *Array:
+		return obj.inspect(active)
+	case *Hash:
+		return obj.inspect(active)
+	}
+	return obj.Inspect()
+}
+
 type Hashable interface {
 	HashKey() HashKey
 }
+
+// Iterator walks the items of an array, string or hash for a for loop. It is
+// internal to the interpreters and never reaches Buggy code.
+type Iterator struct {
+	next   func() (key, value Object, ok bool)
+	isHash bool
+}
+
+func (it *Iterator) Type() ObjectType { return ITERATOR_OBJ }
+func (it *Iterator) Inspect() string  { return "iterator" }
+
+// Next returns the index and element of arrays and strings, or the key and
+// value of hashes. ok is false once the iterable is exhausted.
+func (it *Iterator) Next() (key, value Object, ok bool) {
+	return it.next()
+}
+
+// NextItem returns what a for loop with a single name binds: the element of
+// an array or string, or the key of a hash.
+func (it *Iterator) NextItem() (Object, bool) {
+	key, value, ok := it.next()
+	if it.isHash {
+		return key, ok
+	}
+	return value, ok
+}
+
+// NewIterator returns an iterator over obj, or false if obj can't be
+// iterated. The items are fixed when the iterator is created.
+func NewIterator(obj Object) (*Iterator, bool) {
+	i := 0
+	switch obj := obj.(type) {
+	case *Array:
+		elements := obj.Elements
+		return &Iterator{next: func() (Object, Object, bool) {
+			if i >= len(elements) {
+				return nil, nil, false
+			}
+			i++
+			return &Integer{Value: int64(i - 1)}, elements[i-1], true
+		}}, true
+	case *String:
+		chars := []rune(obj.Value)
+		return &Iterator{next: func() (Object, Object, bool) {
+			if i >= len(chars) {
+				return nil, nil, false
+			}
+			i++
+			return &Integer{Value: int64(i - 1)}, &String{Value: string(chars[i-1])}, true
+		}}, true
+	case *Hash:
+		pairs := obj.OrderedPairs()
+		return &Iterator{next: func() (Object, Object, bool) {
+			if i >= len(pairs) {
+				return nil, nil, false
+			}
+			i++
+			return pairs[i-1].Key, pairs[i-1].Value, true
+		}, isHash: true}, true
+	default:
+		return nil, false
+	}
+}
@@ -1,6 +1,12 @@
 package object
 
-import "testing"
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/smiksha1701/buggy/token"
+)
 
 func TestStringHashKey(t *testing.T) {
 	hello1 := &String{Value: "Hello World"}
@@ -17,3 +23,183 @@ func TestStringHashKey(t *testing.T) {
 		t.Errorf("string with different content have same hash keys")
 	}
 }
+
+func TestFloatHashKey(t *testing.T) {
+	one1 := &Float{Value: 1.5}
+	one2 := &Float{Value: 1.5}
+	two := &Float{Value: 2.5}
+	if one1.HashKey() != one2.HashKey() {
+		t.Errorf("floats with same value have different hash keys")
+	}
+	if one1.HashKey() == two.HashKey() {
+		t.Errorf("floats with different value have same hash keys")
+	}
+	if (&Float{Value: 0}).HashKey() != (&Float{Value: math.Copysign(0, -1)}).HashKey() {
+		t.Errorf("positive and negative zero have different hash keys")
+	}
+	if (&Float{Value: 1}).HashKey() == (&Integer{Value: 1}).HashKey() {
+		t.Errorf("float and integer share a hash key")
+	}
+}
+
+func TestErrorTraceback(t *testing.T) {
+	err := &Error{
+		Kind:    TYPE_ERROR,
+		Message: "type mismatch: INTEGER + BOOLEAN",
+		Pos:     token.Position{Filename: "a.bg", Line: 2, Column: 5},
+		Stack: []StackFrame{
+			{Function: "add", Pos: token.Position{Filename: "a.bg", Line: 4, Column: 3}},
+			{Function: "", Pos: token.Position{Filename: "a.bg", Line: 6, Column: 1}},
+		},
+	}
+	expected := "TypeError: type mismatch: INTEGER + BOOLEAN\n" +
+		"    at add (a.bg:2:5)\n" +
+		"    at <anonymous> (a.bg:4:3)\n" +
+		"    at <main> (a.bg:6:1)\n"
+	if got := err.Traceback(); got != expected {
+		t.Errorf("wrong traceback.\nexpected=%q\ngot=%q", expected, got)
+	}
+
+	bare := &Error{Message: "oops"}
+	if got := bare.Traceback(); got != "RuntimeError: oops\n    at <main>\n" {
+		t.Errorf("wrong traceback without kind or position. got=%q", got)
+	}
+}
+
+func TestLongTracebackIsElided(t *testing.T) {
+	err := &Error{Kind: STACK_OVERFLOW_ERROR, Message: "stack overflow"}
+	for i := 0; i < 100; i++ {
+		err.Stack = append(err.Stack, StackFrame{Function: "loop", Pos: token.Position{Line: 1, Column: 20}})
+	}
+	lines := strings.Split(strings.TrimSuffix(err.Traceback(), "\n"), "\n")
+	if len(lines) != 1+2*tracebackEnds+1+1 {
+		t.Fatalf("wrong number of lines. got=%d", len(lines))
+	}
+	if lines[tracebackEnds+1] != "    ... 80 more calls ..." {
+		t.Errorf("wrong elision line. got=%q", lines[tracebackEnds+1])
+	}
+	if lines[len(lines)-1] != "    at <main> (1:20)" {
+		t.Errorf("wrong last line. got=%q", lines[len(lines)-1])
+	}
+}
+
+func TestTracebackOfElidedTailCalls(t *testing.T) {
+	err := &Error{
+		Kind:    THROWN_ERROR,
+		Message: "x",
+		Pos:     token.Position{Line: 1, Column: 31},
+		Stack: []StackFrame{
+			{Function: "f", Pos: token.Position{Line: 1, Column: 50}},
+			{Pos: token.Position{Line: 1, Column: 50}, Elided: 700},
+			{Function: "f", Pos: token.Position{Line: 2, Column: 9}},
+		},
+	}
+	expected := "Error: x\n" +
+		"    at f (1:31)\n" +
+		"    ... 700 tail calls ...\n" +
+		"    at f (1:50)\n" +
+		"    at <main> (2:9)\n"
+	if got := err.Traceback(); got != expected {
+		t.Errorf("wrong traceback.\nexpected=%q\ngot=%q", expected, got)
+	}
+	pair := err.ToHash().Pairs[(&String{Value: "stack"}).HashKey()]
+	if pair.Value == nil || pair.Value.Inspect() != "[f (1:31), (700 tail calls), f (1:50)]" {
+		t.Errorf("wrong stack in hash. got=%v", pair.Value)
+	}
+}
+
+func TestErrorToHash(t *testing.T) {
+	thrown := &Hash{Pairs: map[HashKey]HashPair{}}
+	setPair(thrown, "kind", &String{Value: "ValueError"})
+	setPair(thrown, "message", &String{Value: "bad row"})
+	setPair(thrown, "row", &Integer{Value: 3})
+	err := NewThrownError(thrown)
+	if err.Kind != "ValueError" || err.Message != "bad row" {
+		t.Fatalf("wrong kind or message. got=%q, %q", err.Kind, err.Message)
+	}
+	err.Pos = token.Position{Line: 2, Column: 5}
+	err.Stack = []StackFrame{{Function: "parse", Pos: token.Position{Line: 7, Column: 1}}}
+
+	expected := map[string]string{
+		"kind":     "ValueError",
+		"message":  "bad row",
+		"position": "2:5",
+		"stack":    "[parse (2:5)]",
+		"row":      "3",
+	}
+	h := err.ToHash()
+	if len(h.Pairs) != len(expected) {
+		t.Errorf("wrong number of pairs. got=%d", len(h.Pairs))
+	}
+	for key, value := range expected {
+		pair, ok := h.Pairs[(&String{Value: key}).HashKey()]
+		if !ok {
+			t.Errorf("no pair for %q", key)
+			continue
+		}
+		if pair.Value.Inspect() != value {
+			t.Errorf("wrong value for %q. expected=%q, got=%q", key, value, pair.Value.Inspect())
+		}
+	}
+
+	err = NewThrownError(&Integer{Value: 42})
+	if err.Kind != THROWN_ERROR || err.Message != "42" {
+		t.Errorf("wrong kind or message. got=%q, %q", err.Kind, err.Message)
+	}
+	if _, ok := err.ToHash().Pairs[(&String{Value: "value"}).HashKey()]; !ok {
+		t.Errorf("thrown value missing from hash")
+	}
+}
+
+func TestHashKeepsInsertionOrder(t *testing.T) {
+	hash := NewHash()
+	for _, key := range []string{"c", "a", "b"} {
+		s := &String{Value: key}
+		hash.Set(s.HashKey(), HashPair{Key: s, Value: TRUE})
+	}
+	a := &String{Value: "a"}
+	hash.Set(a.HashKey(), HashPair{Key: a, Value: FALSE})
+	if got := hash.Inspect(); got != "{c: true, a: false, b: true}" {
+		t.Errorf("wrong order after reassigning a key: %s", got)
+	}
+	copied := hash.Copy()
+	if !copied.Delete(a.HashKey()) || copied.Delete(a.HashKey()) {
+		t.Errorf("Delete reported the wrong result")
+	}
+	copied.Set(a.HashKey(), HashPair{Key: a, Value: NULL})
+	if got := copied.Inspect(); got != "{c: true, b: true, a: null}" {
+		t.Errorf("wrong order after deleting and adding a key: %s", got)
+	}
+	if got := hash.Inspect(); got != "{c: true, a: false, b: true}" {
+		t.Errorf("changing a copy changed the original: %s", got)
+	}
+	one := &Integer{Value: 1}
+	hash.Pairs[one.HashKey()] = HashPair{Key: one, Value: TRUE}
+	if got := hash.Inspect(); got != "{c: true, a: false, b: true, 1: true}" {
+		t.Errorf("wrong order for a pair stored directly: %s", got)
+	}
+}
+
+func TestBudgetStaysExhausted(t *testing.T) {
+	b := NewBudget(nil, Limits{MaxSteps: 2})
+	for i := 0; i < 2; i++ {
+		if err := b.Step(); err != nil {
+			t.Fatalf("step %d failed: %s", i+1, err)
+		}
+	}
+	if b.Exhausted() {
+		t.Fatalf("budget exhausted before the limit")
+	}
+	first := b.Step()
+	if first == nil || first.Kind != STEP_LIMIT_ERROR {
+		t.Fatalf("expected a step limit error. got=%v", first)
+	}
+	first.Stack = append(first.Stack, StackFrame{Function: "f"})
+	second := b.Step()
+	if second == nil || second.Kind != STEP_LIMIT_ERROR || len(second.Stack) != 0 {
+		t.Fatalf("expected a fresh step limit error. got=%+v", second)
+	}
+	if !b.Exhausted() {
+		t.Errorf("budget not exhausted after the limit")
+	}
+}
@@ -0,0 +1,83 @@
+package parser
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/smiksha1701/buggy/token"
+)
+
+// ErrorKind classifies a syntax error.
+type ErrorKind int
+
+const (
+	UnexpectedToken    ErrorKind = iota // a specific token was required
+	MissingExpression                   // no expression starts with the token found
+	InvalidLiteral                      // a number literal that does not fit its type
+	InvalidAssignment                   // the left side of = cannot be assigned to
+	MisplacedStatement                  // break or continue outside a loop, import or export in a block
+	LexicalError                        // reported by the lexer
+)
+
+var errorKindNames = map[ErrorKind]string{
+	UnexpectedToken:    "unexpected token",
+	MissingExpression:  "missing expression",
+	InvalidLiteral:     "invalid literal",
+	InvalidAssignment:  "invalid assignment",
+	MisplacedStatement: "misplaced statement",
+	LexicalError:       "lexical error",
+}
+
+func (k ErrorKind) String() string {
+	if name, ok := errorKindNames[k]; ok {
+		return name
+	}
+	return "syntax error"
+}
+
+// Error is a syntax error found while parsing. Expected and Found describe
+// the tokens involved for UnexpectedToken and MissingExpression errors and
+// are empty otherwise.
+type Error struct {
+	Kind     ErrorKind
+	Pos      token.Position
+	Expected string
+	Found    string
+	Msg      string
+}
+
+func (e *Error) Error() string {
+	return e.Pos.String() + ": " + e.Msg
+}
+
+// describeToken names a token the way error messages show what was found.
+func describeToken(tok token.Token) string {
+	switch tok.Type {
+	case token.EOF:
+		return "end of input"
+	case token.IDENT:
+		return "identifier " + tok.Literal
+	case token.INT, token.FLOAT:
+		return "number " + tok.Literal
+	case token.STRING:
+		return "string " + strconv.Quote(tok.Literal)
+	case token.ILLEGAL:
+		return "illegal character " + strconv.Quote(tok.Literal)
+	default:
+		return tok.Literal
+	}
+}
+
+// describeTokenType names a token type the way error messages show what was
+// expected.
+func describeTokenType(t token.TokenType) string {
+	switch t {
+	case token.IDENT:
+		return "identifier"
+	case token.EOF:
+		return "end of input"
+	default:
+		// Keyword types are upper case, the rest are their own literal.
+		return strings.ToLower(string(t))
+	}
+}
@@ -12,6 +12,8 @@ import (
 const (
 	_ int = iota
 	LOWEST
+	OR
+	AND
 	EQUALS
 	LESSGREATER
 	SUM
@@ -22,48 +24,74 @@ const (
 )
 
 var precedences = map[token.TokenType]int{
+	token.OR:       OR,
+	token.AND:      AND,
 	token.EQ:       EQUALS,
 	token.NEQ:      EQUALS,
 	token.LT:       LESSGREATER,
 	token.GT:       LESSGREATER,
+	token.LTE:      LESSGREATER,
+	token.GTE:      LESSGREATER,
 	token.PLUS:     SUM,
 	token.MINUS:    SUM,
 	token.LPAREN:   CALL,
 	token.SLASH:    PRODUCT,
 	token.ASTERIX:  PRODUCT,
+	token.PERCENT:  PRODUCT,
 	token.LBRACKET: INDEX,
+	token.DOT:      INDEX,
 }
 
-func (p *Parser) PeekPrecedence() int {
-	if p, ok := precedences[p.peekToken.Type]; ok {
+// Precedence returns how tightly the infix operator t binds its operands,
+// or LOWEST for tokens that are not infix operators.
+func Precedence(t token.TokenType) int {
+	if p, ok := precedences[t]; ok {
 		return p
 	}
 	return LOWEST
 }
+
+func (p *Parser) PeekPrecedence() int {
+	return Precedence(p.peekToken.Type)
+}
 func (p *Parser) CurPrecedence() int {
-	if p, ok := precedences[p.curToken.Type]; ok {
-		return p
-	}
-	return LOWEST
+	return Precedence(p.curToken.Type)
 }
 
 type Parser struct {
 	l                *lexer.Lexer
 	curToken         token.Token
 	peekToken        token.Token
-	errors           []string
+	errors           []*Error
 	prefixParsingFns map[token.TokenType]prefixParsingFn
 	infixParsingFns  map[token.TokenType]infixParsingFn
+	// loopDepth counts the loops around the current token within the
+	// current function, so break and continue can be checked.
+	loopDepth int
+	// blockDepth counts the blocks around the current token, so import and
+	// export can be kept to the top level.
+	blockDepth int
+	// lexerErrors counts the lexer errors already copied into errors.
+	lexerErrors int
+	// unterminated is set once the lexer has reported a string or comment
+	// that runs to the end of the input. Running out of input is then no
+	// news, so errors about it are not reported.
+	unterminated bool
+	// panicking is set by a syntax error and cleared once the parser has
+	// skipped to the next statement. Errors in between are not reported:
+	// they are usually consequences of the first one.
+	panicking bool
 }
 
 func New(l *lexer.Lexer) *Parser {
-	p := &Parser{l: l, errors: []string{}}
+	p := &Parser{l: l, errors: []*Error{}}
 	p.nextToken()
 	p.nextToken()
 	p.prefixParsingFns = make(map[token.TokenType]prefixParsingFn)
 	p.RegisterPrefix(token.STRING, p.parseString)
 	p.RegisterPrefix(token.IDENT, p.parseIdentifier)
 	p.RegisterPrefix(token.INT, p.parseIntegerLiteral)
+	p.RegisterPrefix(token.FLOAT, p.parseFloatLiteral)
 	p.RegisterPrefix(token.BANG, p.parsePrefixExpression)
 	p.RegisterPrefix(token.MINUS, p.parsePrefixExpression)
 	p.RegisterPrefix(token.TRUE, p.parseBoolean)
@@ -72,18 +100,25 @@ func New(l *lexer.Lexer) *Parser {
 	p.RegisterPrefix(token.LBRACKET, p.parseArray)
 	p.RegisterPrefix(token.LBRACE, p.parseHashLiteral)
 	p.RegisterPrefix(token.IF, p.parseIfExpression)
+	p.RegisterPrefix(token.TRY, p.parseTryExpression)
 	p.RegisterPrefix(token.FUNCTION, p.parseFunctionExpression)
 	p.infixParsingFns = make(map[token.TokenType]infixParsingFn)
 	p.RegisterInfix(token.PLUS, p.parseInfixExpression)
 	p.RegisterInfix(token.MINUS, p.parseInfixExpression)
 	p.RegisterInfix(token.LPAREN, p.parseCallFunction)
 	p.RegisterInfix(token.LBRACKET, p.parseIndexExpression)
+	p.RegisterInfix(token.DOT, p.parseMemberExpression)
 	p.RegisterInfix(token.ASTERIX, p.parseInfixExpression)
 	p.RegisterInfix(token.SLASH, p.parseInfixExpression)
 	p.RegisterInfix(token.EQ, p.parseInfixExpression)
 	p.RegisterInfix(token.NEQ, p.parseInfixExpression)
 	p.RegisterInfix(token.LT, p.parseInfixExpression)
 	p.RegisterInfix(token.GT, p.parseInfixExpression)
+	p.RegisterInfix(token.LTE, p.parseInfixExpression)
+	p.RegisterInfix(token.GTE, p.parseInfixExpression)
+	p.RegisterInfix(token.PERCENT, p.parseInfixExpression)
+	p.RegisterInfix(token.AND, p.parseInfixExpression)
+	p.RegisterInfix(token.OR, p.parseInfixExpression)
 	return p
 }
 
@@ -99,6 +134,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		p.nextToken()
 		value := p.parseExpression(LOWEST)
 		hash.Pairs[key] = value
+		hash.Keys = append(hash.Keys, key)
 		if !p.PeekTypeIs(token.RBRACE) && !p.ExpectedPeek(token.COMMA) {
 			return nil
 		}
@@ -173,7 +209,10 @@ func (p *Parser) parseFunctionExpression() ast.Expression {
 	if !p.ExpectedPeek(token.LBRACE) {
 		return nil
 	}
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
 	lit.Body = p.parseBlockStatement()
+	p.loopDepth = loopDepth
 
 	return lit
 }
@@ -200,16 +239,48 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 }
 
 func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
-	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+	tok := p.curToken
 	p.nextToken()
 
-	exp.Index = p.parseExpression(LOWEST)
+	var index ast.Expression
+	if !p.CurTokenIs(token.COLON) {
+		index = p.parseExpression(LOWEST)
+		if !p.PeekTypeIs(token.COLON) {
+			if !p.ExpectedPeek(token.RBRACKET) {
+				return nil
+			}
+			return &ast.IndexExpression{Token: tok, Left: left, Index: index}
+		}
+		p.nextToken()
+	}
+	return p.parseSliceExpression(tok, left, index)
+}
+
+// parseSliceExpression parses the rest of `left[low:high]` with the current
+// token on the colon.
+func (p *Parser) parseSliceExpression(tok token.Token, left, low ast.Expression) ast.Expression {
+	exp := &ast.SliceExpression{Token: tok, Left: left, Low: low}
+	if p.PeekTypeIs(token.RBRACKET) {
+		p.nextToken()
+		return exp
+	}
+	p.nextToken()
+	exp.High = p.parseExpression(LOWEST)
 	if !p.ExpectedPeek(token.RBRACKET) {
 		return nil
 	}
 	return exp
 }
 
+func (p *Parser) parseMemberExpression(left ast.Expression) ast.Expression {
+	exp := &ast.MemberExpression{Token: p.curToken, Left: left}
+	if !p.ExpectedPeek(token.IDENT) {
+		return nil
+	}
+	exp.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	return exp
+}
+
 func (p *Parser) parseCallFunction(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
 
@@ -220,16 +291,35 @@ func (p *Parser) parseCallFunction(function ast.Expression) ast.Expression {
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.curToken}
 	block.Statements = []ast.Statement{}
+	p.blockDepth++
+	defer func() { p.blockDepth-- }()
 
 	p.nextToken()
 
 	for !p.CurTokenIs(token.RBRACE) && !p.CurTokenIs(token.EOF) {
+		start := p.curToken.Pos
 		stmt := p.ParseStatement()
+		if p.panicking {
+			p.synchronize(start)
+			continue
+		}
 		if stmt != nil {
 			block.Statements = append(block.Statements, stmt)
 		}
 		p.nextToken()
 	}
+	if p.CurTokenIs(token.EOF) {
+		// Point at the brace left open rather than at the end of the
+		// input, which may be far away.
+		expected, found := describeTokenType(token.RBRACE), describeToken(p.curToken)
+		p.report(&Error{
+			Kind:     UnexpectedToken,
+			Pos:      block.Token.Pos,
+			Expected: expected,
+			Found:    found,
+			Msg:      "expected " + expected + ", found " + found,
+		})
+	}
 	return block
 }
 
@@ -249,30 +339,114 @@ func (p *Parser) parseString() ast.Expression {
 	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
 }
 
-func (p *Parser) Errors() []string {
+// Errors returns the syntax errors found so far, in the order of the input.
+func (p *Parser) Errors() []*Error {
 	return p.errors
 }
 
+func (p *Parser) addError(kind ErrorKind, pos token.Position, format string, a ...interface{}) {
+	p.report(&Error{Kind: kind, Pos: pos, Msg: fmt.Sprintf(format, a...)})
+}
+
+func (p *Parser) report(err *Error) {
+	if p.panicking {
+		return
+	}
+	p.panicking = true
+	if p.unterminated && err.Found == describeTokenType(token.EOF) {
+		return
+	}
+	p.errors = append(p.errors, err)
+}
+
 func (p *Parser) noPrefixFnError(t token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s found ", t)
-	p.errors = append(p.errors, msg)
+	found := describeToken(p.curToken)
+	p.report(&Error{
+		Kind:     MissingExpression,
+		Pos:      p.curToken.Pos,
+		Expected: "expression",
+		Found:    found,
+		Msg:      "expected expression, found " + found,
+	})
 }
 
 func (p *Parser) ErrorExpectedPeek(t token.TokenType) {
-	msg := fmt.Sprintf("expected peek type was = %s got = %s instead ", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	expected := describeTokenType(t)
+	found := describeToken(p.peekToken)
+	p.report(&Error{
+		Kind:     UnexpectedToken,
+		Pos:      p.peekToken.Pos,
+		Expected: expected,
+		Found:    found,
+		Msg:      "expected " + expected + ", found " + found,
+	})
+}
+
+// synchronize skips the rest of a statement that had a syntax error. It
+// stops after the semicolon that ends it, on a keyword that starts a new
+// statement or on the brace closing the enclosing block, not looking inside
+// nested brackets. It always moves past start, where the statement began.
+func (p *Parser) synchronize(start token.Position) {
+	p.panicking = false
+	depth := 0
+	for !p.CurTokenIs(token.EOF) {
+		moved := p.curToken.Pos.Offset != start.Offset
+		switch p.curToken.Type {
+		case token.LPAREN, token.LBRACKET, token.LBRACE:
+			depth++
+		case token.RPAREN, token.RBRACKET:
+			if depth > 0 {
+				depth--
+			}
+		case token.RBRACE:
+			if depth == 0 && moved {
+				return
+			}
+			if depth > 0 {
+				depth--
+			}
+		case token.SEMICOLON:
+			if depth == 0 {
+				p.nextToken()
+				return
+			}
+		case token.LET, token.RETURN, token.WHILE, token.FOR, token.BREAK, token.CONTINUE,
+			token.THROW, token.IMPORT, token.EXPORT:
+			if depth == 0 && moved {
+				return
+			}
+		}
+		p.nextToken()
+	}
 }
 
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken()
+	for p.peekToken.Type == token.COMMENT {
+		p.peekToken = p.l.NextToken()
+	}
+	// Lexer errors are independent of the parser state, so they are
+	// reported even while recovering from a syntax error.
+	for _, err := range p.l.Errors()[p.lexerErrors:] {
+		p.errors = append(p.errors, &Error{Kind: LexicalError, Pos: err.Pos, Msg: err.Msg})
+		if err.Unterminated {
+			p.unterminated = true
+		}
+	}
+	p.lexerErrors = len(p.l.Errors())
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
 	program := &ast.Program{}
 	program.Statements = []ast.Statement{}
 	for p.curToken.Type != token.EOF {
+		start := p.curToken.Pos
 		stmt := p.ParseStatement()
+		if p.panicking {
+			p.synchronize(start)
+			continue
+		}
 		if stmt != nil {
 			program.Statements = append(program.Statements, stmt)
 		}
@@ -287,16 +461,41 @@ func (p *Parser) ParseStatement() ast.Statement {
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.WHILE:
+		return p.parseWhileStatement()
+	case token.FOR:
+		return p.parseForStatement()
+	case token.BREAK, token.CONTINUE:
+		return p.parseLoopControlStatement()
+	case token.THROW:
+		return p.parseThrowStatement()
+	case token.IMPORT:
+		return p.parseImportStatement()
+	case token.EXPORT:
+		return p.parseExportStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
 }
 
-func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
+var assignOperators = map[token.TokenType]bool{
+	token.ASSIGN:         true,
+	token.PLUS_ASSIGN:    true,
+	token.MINUS_ASSIGN:   true,
+	token.ASTERIX_ASSIGN: true,
+	token.SLASH_ASSIGN:   true,
+	token.PERCENT_ASSIGN: true,
+}
+
+func (p *Parser) parseExpressionStatement() ast.Statement {
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
 
 	stmt.Expression = p.parseExpression(LOWEST)
 
+	if assignOperators[p.peekToken.Type] {
+		return p.parseAssignStatement(stmt.Expression)
+	}
+
 	if p.PeekTypeIs(token.SEMICOLON) {
 		p.nextToken()
 	}
@@ -333,6 +532,193 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return stmt
 }
 
+func (p *Parser) parseThrowStatement() *ast.ThrowStatement {
+	stmt := &ast.ThrowStatement{Token: p.curToken}
+	p.nextToken()
+	stmt.Value = p.parseExpression(LOWEST)
+
+	if p.PeekTypeIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+// parseTryExpression parses a try block followed by a catch clause, a
+// finally block or both.
+func (p *Parser) parseTryExpression() ast.Expression {
+	expr := &ast.TryExpression{Token: p.curToken}
+	if !p.ExpectedPeek(token.LBRACE) {
+		return nil
+	}
+	expr.Body = p.parseBlockStatement()
+	if p.PeekTypeIs(token.CATCH) {
+		p.nextToken()
+		if !p.ExpectedPeek(token.LPAREN) || !p.ExpectedPeek(token.IDENT) {
+			return nil
+		}
+		expr.CatchParam = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if !p.ExpectedPeek(token.RPAREN) || !p.ExpectedPeek(token.LBRACE) {
+			return nil
+		}
+		expr.Catch = p.parseBlockStatement()
+	}
+	if p.PeekTypeIs(token.FINALLY) {
+		p.nextToken()
+		if !p.ExpectedPeek(token.LBRACE) {
+			return nil
+		}
+		expr.Finally = p.parseBlockStatement()
+	}
+	if expr.Catch == nil && expr.Finally == nil {
+		found := describeToken(p.peekToken)
+		p.report(&Error{
+			Kind:     UnexpectedToken,
+			Pos:      p.peekToken.Pos,
+			Expected: "catch or finally",
+			Found:    found,
+			Msg:      "expected catch or finally, found " + found,
+		})
+		return nil
+	}
+	return expr
+}
+
+func (p *Parser) parseAssignStatement(target ast.Expression) ast.Statement {
+	p.nextToken()
+	stmt := &ast.AssignStatement{Token: p.curToken, Target: target, Operator: p.curToken.Literal}
+	switch target.(type) {
+	case *ast.Identifier, *ast.IndexExpression:
+	default:
+		if target != nil {
+			p.addError(InvalidAssignment, p.curToken.Pos, "cannot assign to %s", target.String())
+		}
+		return nil
+	}
+	p.nextToken()
+	stmt.Value = p.parseExpression(LOWEST)
+	if p.PeekTypeIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+func (p *Parser) parseWhileStatement() ast.Statement {
+	stmt := &ast.WhileStatement{Token: p.curToken}
+	if !p.ExpectedPeek(token.LPAREN) {
+		return nil
+	}
+	p.nextToken()
+	stmt.Condition = p.parseExpression(LOWEST)
+	if !p.ExpectedPeek(token.RPAREN) {
+		return nil
+	}
+	if !p.ExpectedPeek(token.LBRACE) {
+		return nil
+	}
+	stmt.Body = p.parseLoopBody()
+	if p.PeekTypeIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+func (p *Parser) parseForStatement() ast.Statement {
+	stmt := &ast.ForStatement{Token: p.curToken}
+	if !p.ExpectedPeek(token.LPAREN) {
+		return nil
+	}
+	if !p.ExpectedPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Value = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if p.PeekTypeIs(token.COMMA) {
+		p.nextToken()
+		if !p.ExpectedPeek(token.IDENT) {
+			return nil
+		}
+		stmt.Key = stmt.Value
+		stmt.Value = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
+	if !p.ExpectedPeek(token.IN) {
+		return nil
+	}
+	p.nextToken()
+	stmt.Iterable = p.parseExpression(LOWEST)
+	if !p.ExpectedPeek(token.RPAREN) {
+		return nil
+	}
+	if !p.ExpectedPeek(token.LBRACE) {
+		return nil
+	}
+	stmt.Body = p.parseLoopBody()
+	if p.PeekTypeIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+func (p *Parser) parseLoopBody() *ast.BlockStatement {
+	p.loopDepth++
+	body := p.parseBlockStatement()
+	p.loopDepth--
+	return body
+}
+
+func (p *Parser) parseLoopControlStatement() ast.Statement {
+	tok := p.curToken
+	if p.PeekTypeIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	if p.loopDepth == 0 {
+		p.addError(MisplacedStatement, tok.Pos, "%s outside loop", tok.Literal)
+		return nil
+	}
+	if tok.Type == token.BREAK {
+		return &ast.BreakStatement{Token: tok}
+	}
+	return &ast.ContinueStatement{Token: tok}
+}
+
+// parseImportStatement parses `import "path" as name`, which may only
+// appear at the top level of a file.
+func (p *Parser) parseImportStatement() ast.Statement {
+	stmt := &ast.ImportStatement{Token: p.curToken}
+	if !p.ExpectedPeek(token.STRING) {
+		return nil
+	}
+	stmt.Path = &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+	if !p.ExpectedPeek(token.AS) || !p.ExpectedPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if p.PeekTypeIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	if p.blockDepth > 0 {
+		p.addError(MisplacedStatement, stmt.Pos(), "import inside a block")
+		return nil
+	}
+	return stmt
+}
+
+// parseExportStatement parses `export let name = value`, which may only
+// appear at the top level of a file.
+func (p *Parser) parseExportStatement() ast.Statement {
+	tok := p.curToken
+	if !p.ExpectedPeek(token.LET) {
+		return nil
+	}
+	let := p.parseLetStatement()
+	if let == nil {
+		return nil
+	}
+	if p.blockDepth > 0 {
+		p.addError(MisplacedStatement, tok.Pos, "export inside a block")
+		return nil
+	}
+	return &ast.ExportStatement{Token: tok, Statement: let}
+}
+
 func (p *Parser) parseLetStatement() *ast.LetStatement {
 	stmt := &ast.LetStatement{Token: p.curToken}
 	if !p.ExpectedPeek(token.IDENT) {
@@ -345,6 +731,9 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	}
 	p.nextToken()
 	stmt.Value = p.parseExpression(LOWEST)
+	if fl, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+		fl.Name = stmt.Name.Value
+	}
 	if p.PeekTypeIs(token.SEMICOLON) {
 		p.nextToken()
 	}
@@ -387,8 +776,18 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	lit := &ast.IntegerLiteral{Token: p.curToken}
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.addError(InvalidLiteral, p.curToken.Pos, "could not parse %q as integer", p.curToken.Literal)
+		return nil
+	}
+	lit.Value = value
+	return lit
+}
+
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{Token: p.curToken}
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		p.addError(InvalidLiteral, p.curToken.Pos, "could not parse %q as float", p.curToken.Literal)
 		return nil
 	}
 	lit.Value = value
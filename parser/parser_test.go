@@ -71,7 +71,7 @@ func CheckParserErrors(p *Parser, t *testing.T) {
 	}
 	t.Errorf("Parser had %d errors", len(errors))
 	for _, e := range errors {
-		t.Errorf("parser error: %q", e)
+		t.Errorf("parser error: %q", e.Error())
 	}
 	t.FailNow()
 }
@@ -240,6 +240,32 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 
 }
+func TestFloatLiteralExpression(t *testing.T) {
+	input := "3.25;"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	CheckParserErrors(p, t)
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statements. got=%d", len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Expected ast.ExpressionStatement got=%T", program.Statements[0])
+	}
+	literal, ok := stmt.Expression.(*ast.FloatLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.FloatLiteral. got=%T", stmt.Expression)
+	}
+	if literal.Value != 3.25 {
+		t.Fatalf("literal.Value expected=3.25 got=%f", literal.Value)
+	}
+	if literal.TokenLiteral() != "3.25" {
+		t.Fatalf("literal.TokenLiteral() expected=3.25 got=%s", literal.TokenLiteral())
+	}
+}
+
 func TestStringLiteralExpression(t *testing.T) {
 	input := `"hello world";`
 
@@ -401,6 +427,26 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a * b[2], b[1], 2 * [1, 2][1])",
 			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
 		},
+		{
+			"a || b && c",
+			"(a || (b && c))",
+		},
+		{
+			"a && b || c && d",
+			"((a && b) || (c && d))",
+		},
+		{
+			"a <= b == c >= d && !e",
+			"(((a <= b) == (c >= d)) && (!e))",
+		},
+		{
+			"a + b % c * d",
+			"(a + ((b % c) * d))",
+		},
+		{
+			"-lib.add(1, 2) * lib.xs[0]",
+			"((-(lib.add)(1, 2)) * ((lib.xs)[0]))",
+		},
 	}
 	for _, tt := range tests {
 		l := lexer.New(tt.input)
@@ -695,3 +741,452 @@ func TestCallExpressionParsing(t *testing.T) {
 	testInfixExpression(t, exp.Arguments[2], "x", "+", "y")
 
 }
+
+func TestParserErrorPositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = ;", "1:9: expected expression, found ;"},
+		{"let = 5;", "1:5: expected identifier, found ="},
+		{"let x = 1;\nadd(1, 2;", "2:9: expected ), found ;"},
+		{"if (true) {\n  1", "1:11: expected }, found end of input"},
+		{"let f = fn(x) {\n  while (x) { x = x - 1 }\n", "1:15: expected }, found end of input"},
+		{"while (true) { if (x) { 1 }", "1:14: expected }, found end of input"},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("expected parser errors for %q", tt.input)
+			continue
+		}
+		if errors[0].Error() != tt.expected {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expected, errors[0].Error())
+		}
+	}
+}
+
+func TestWhileStatement(t *testing.T) {
+	input := `while (x < y) { x; break; }`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	CheckParserErrors(p, t)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statements. got=%d", len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.WhileStatement got=%T", program.Statements[0])
+	}
+	if !testInfixExpression(t, stmt.Condition, "x", "<", "y") {
+		return
+	}
+	if len(stmt.Body.Statements) != 2 {
+		t.Fatalf("body is not 2 statements. got=%d", len(stmt.Body.Statements))
+	}
+	if _, ok := stmt.Body.Statements[1].(*ast.BreakStatement); !ok {
+		t.Fatalf("Statements[1] is not ast.BreakStatement. got=%T", stmt.Body.Statements[1])
+	}
+}
+
+func TestThrowStatement(t *testing.T) {
+	l := lexer.New("throw err;")
+	p := New(l)
+	program := p.ParseProgram()
+	CheckParserErrors(p, t)
+
+	stmt, ok := program.Statements[0].(*ast.ThrowStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ThrowStatement got=%T", program.Statements[0])
+	}
+	if !testLiteralExpression(t, stmt.Value, "err") {
+		return
+	}
+}
+
+func TestImportAndExport(t *testing.T) {
+	l := lexer.New(`import "lib/math.bg" as math; export let x = math.pi;`)
+	p := New(l)
+	program := p.ParseProgram()
+	CheckParserErrors(p, t)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements does not contain 2 statements. got=%d", len(program.Statements))
+	}
+	imp, ok := program.Statements[0].(*ast.ImportStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ImportStatement got=%T", program.Statements[0])
+	}
+	if imp.Path.Value != "lib/math.bg" {
+		t.Errorf("wrong import path. got=%q", imp.Path.Value)
+	}
+	if !testIdentifier(t, imp.Name, "math") {
+		return
+	}
+	exp, ok := program.Statements[1].(*ast.ExportStatement)
+	if !ok {
+		t.Fatalf("program.Statements[1] is not ast.ExportStatement got=%T", program.Statements[1])
+	}
+	if !CheckLetStatement(t, exp.Statement, "x") {
+		return
+	}
+	member, ok := exp.Statement.Value.(*ast.MemberExpression)
+	if !ok {
+		t.Fatalf("exported value is not ast.MemberExpression got=%T", exp.Statement.Value)
+	}
+	if !testIdentifier(t, member.Left, "math") || !testIdentifier(t, member.Name, "pi") {
+		return
+	}
+	if program.String() != `import "lib/math.bg" as math;export let x = (math.pi);` {
+		t.Errorf("wrong String. got=%q", program.String())
+	}
+}
+
+func TestTryExpression(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedParam string
+		hasFinally    bool
+		expected      string
+	}{
+		{"try { x } catch (e) { y }", "e", false, "try x catch (e) y"},
+		{"try { x } finally { y }", "", true, "try x finally y"},
+		{"try { x } catch (err) { y } finally { z }", "err", true, "try x catch (err) y finally z"},
+		{"let v = try { x } catch (e) { 0 };", "e", false, "let v = try x catch (e) 0;"},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		CheckParserErrors(p, t)
+
+		if program.String() != tt.expected {
+			t.Errorf("wrong program. expected=%q, got=%q", tt.expected, program.String())
+		}
+		var expr ast.Expression
+		switch stmt := program.Statements[0].(type) {
+		case *ast.ExpressionStatement:
+			expr = stmt.Expression
+		case *ast.LetStatement:
+			expr = stmt.Value
+		}
+		try, ok := expr.(*ast.TryExpression)
+		if !ok {
+			t.Fatalf("expression is not ast.TryExpression. got=%T", expr)
+		}
+		if tt.expectedParam == "" && (try.CatchParam != nil || try.Catch != nil) {
+			t.Errorf("unexpected catch clause. got=%s", try.CatchParam)
+		}
+		if tt.expectedParam != "" && !testIdentifier(t, try.CatchParam, tt.expectedParam) {
+			return
+		}
+		if (try.Finally != nil) != tt.hasFinally {
+			t.Errorf("wrong finally block. expected=%t, got=%v", tt.hasFinally, try.Finally)
+		}
+	}
+}
+
+func TestForStatement(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedKey   string
+		expectedValue string
+	}{
+		{"for (x in xs) { continue; }", "", "x"},
+		{"for (k, v in hash) { k }", "k", "v"},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		CheckParserErrors(p, t)
+
+		stmt, ok := program.Statements[0].(*ast.ForStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ForStatement got=%T", program.Statements[0])
+		}
+		if tt.expectedKey == "" && stmt.Key != nil {
+			t.Errorf("stmt.Key is not nil. got=%s", stmt.Key)
+		}
+		if tt.expectedKey != "" && !testIdentifier(t, stmt.Key, tt.expectedKey) {
+			return
+		}
+		if !testIdentifier(t, stmt.Value, tt.expectedValue) {
+			return
+		}
+		if len(stmt.Body.Statements) != 1 {
+			t.Fatalf("body is not 1 statements. got=%d", len(stmt.Body.Statements))
+		}
+	}
+}
+
+func TestLoopControlOutsideLoop(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"break;", "1:1: break outside loop"},
+		{"if (x) { continue }", "1:10: continue outside loop"},
+		{"while (x) { fn() { break; } }", "1:20: break outside loop"},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) != 1 {
+			t.Errorf("expected 1 parser error for %q. got=%q", tt.input, errors)
+			continue
+		}
+		if errors[0].Error() != tt.expected {
+			t.Errorf("wrong error. expected=%q, got=%q", tt.expected, errors[0].Error())
+		}
+	}
+}
+
+func TestAssignStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		target   string
+		operator string
+		value    string
+	}{
+		{"x = 5;", "x", "=", "5"},
+		{"x += y * 2", "x", "+=", "(y * 2)"},
+		{"arr[1] -= 1;", "(arr[1])", "-=", "1"},
+		{`h["k"] = fn(x) { x };`, "(h[k])", "=", "fn(x) x"},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		CheckParserErrors(p, t)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statements. got=%d", len(program.Statements))
+		}
+		stmt, ok := program.Statements[0].(*ast.AssignStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.AssignStatement got=%T", program.Statements[0])
+		}
+		if stmt.Target.String() != tt.target {
+			t.Errorf("stmt.Target wrong. expected=%q, got=%q", tt.target, stmt.Target.String())
+		}
+		if stmt.Operator != tt.operator {
+			t.Errorf("stmt.Operator wrong. expected=%q, got=%q", tt.operator, stmt.Operator)
+		}
+		if stmt.Value.String() != tt.value {
+			t.Errorf("stmt.Value wrong. expected=%q, got=%q", tt.value, stmt.Value.String())
+		}
+	}
+}
+
+func TestInvalidAssignmentTarget(t *testing.T) {
+	l := lexer.New("f() = 1;")
+	p := New(l)
+	p.ParseProgram()
+	errors := p.Errors()
+	if len(errors) != 1 {
+		t.Fatalf("expected 1 parser error. got=%q", errors)
+	}
+	if errors[0].Error() != "1:5: cannot assign to f()" {
+		t.Errorf("wrong error. got=%q", errors[0].Error())
+	}
+}
+
+func TestParsingWithComments(t *testing.T) {
+	input := `// leading
+let x = /* inline */ 5; // trailing
+x /* between */ + 1`
+	l := lexer.New(input)
+	l.EmitComments = true
+	p := New(l)
+	program := p.ParseProgram()
+	CheckParserErrors(p, t)
+
+	if actual := program.String(); actual != "let x = 5;(x + 1)" {
+		t.Errorf("wrong program. got=%q", actual)
+	}
+}
+
+func TestLexerErrors(t *testing.T) {
+	l := lexer.New("let a = \"x\\q\";\nlet b = \"open")
+	p := New(l)
+	program := p.ParseProgram()
+
+	expected := []string{
+		"1:11: invalid escape sequence \\q",
+		"2:9: unterminated string",
+	}
+	errors := p.Errors()
+	if len(errors) != len(expected) {
+		t.Fatalf("wrong number of errors. expected=%q, got=%q", expected, errors)
+	}
+	for i, msg := range expected {
+		if errors[i].Error() != msg {
+			t.Errorf("errors[%d] wrong. expected=%q, got=%q", i, msg, errors[i].Error())
+		}
+	}
+	if len(program.Statements) != 2 {
+		t.Errorf("expected parsing to continue. got %d statements", len(program.Statements))
+	}
+
+	// Whatever is left open when a string or comment swallows the rest of
+	// the input is not reported again.
+	for _, input := range []string{`say("abc`, "if (x) { say(\"abc", "let x = [1, /* two"} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if len(p.Errors()) != 1 || p.Errors()[0].Kind != LexicalError {
+			t.Errorf("expected only the lexer error for %q. got=%q", input, p.Errors())
+		}
+	}
+}
+
+func TestParsingSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"xs[1:3]", "(xs[1:3])"},
+		{"xs[:n - 1]", "(xs[:(n - 1)])"},
+		{"xs[i:]", "(xs[i:])"},
+		{"xs[:]", "(xs[:])"},
+		{"xs[1:2][0]", "((xs[1:2])[0])"},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		CheckParserErrors(p, t)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		if tt.input != "xs[1:2][0]" {
+			if _, ok := stmt.Expression.(*ast.SliceExpression); !ok {
+				t.Fatalf("exp is not ast.SliceExpression. got=%T", stmt.Expression)
+			}
+		}
+		if actual := program.String(); actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	tests := []struct {
+		input      string
+		expected   []string
+		statements string
+	}{
+		{
+			"let x = ;\nlet y = 2;\nlet = 3;\nx + y",
+			[]string{
+				"1:9: expected expression, found ;",
+				"3:5: expected identifier, found =",
+			},
+			"let y = 2;(x + y)",
+		},
+		{
+			"let f = fn(a) {\n  let b = a +;\n  return b\n};\nlet ok = 1;",
+			[]string{"2:14: expected expression, found ;"},
+			"let f = fn(a) return b;;let ok = 1;",
+		},
+		{
+			"let a = [1, 2;\nlet b = 3;\n}\nlet c = 4;",
+			[]string{
+				"1:14: expected ], found ;",
+				"3:1: expected expression, found }",
+			},
+			"let b = 3;let c = 4;",
+		},
+		{
+			"if (a) { let = 1; b } else { c + }\nd;",
+			[]string{
+				"1:14: expected identifier, found =",
+				"1:34: expected expression, found }",
+			},
+			"ifa belse d",
+		},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) != len(tt.expected) {
+			t.Errorf("wrong number of errors for %q. expected=%q, got=%q", tt.input, tt.expected, errors)
+			continue
+		}
+		for i, msg := range tt.expected {
+			if errors[i].Error() != msg {
+				t.Errorf("errors[%d] wrong. expected=%q, got=%q", i, msg, errors[i].Error())
+			}
+		}
+		if actual := program.String(); actual != tt.statements {
+			t.Errorf("wrong statements after recovery. expected=%q, got=%q", tt.statements, actual)
+		}
+	}
+}
+
+func TestStructuredErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		kind     ErrorKind
+		expected string
+		found    string
+	}{
+		{"add(1 2)", UnexpectedToken, ")", "number 2"},
+		{"let x = ", MissingExpression, "expression", "end of input"},
+		{"for (x of xs) {}", UnexpectedToken, "in", "identifier of"},
+		{`if "a" {}`, UnexpectedToken, "(", `string "a"`},
+		{"let x = 99999999999999999999;", InvalidLiteral, "", ""},
+		{"f() += 1;", InvalidAssignment, "", ""},
+		{"continue;", MisplacedStatement, "", ""},
+		{`fn() { import "a.bg" as a }`, MisplacedStatement, "", ""},
+		{"if (x) { export let y = 1 }", MisplacedStatement, "", ""},
+		{`import "a.bg";`, UnexpectedToken, "as", ";"},
+		{"import lib as lib", UnexpectedToken, "string", "identifier lib"},
+		{"lib.1", UnexpectedToken, "identifier", "number 1"},
+		{`"open`, LexicalError, "", ""},
+		{"try { 1 };", UnexpectedToken, "catch or finally", ";"},
+		{"try { 1 } catch { 2 }", UnexpectedToken, "(", "{"},
+	}
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("expected parser errors for %q", tt.input)
+			continue
+		}
+		err := errors[0]
+		if err.Kind != tt.kind {
+			t.Errorf("wrong kind for %q. expected=%s, got=%s", tt.input, tt.kind, err.Kind)
+		}
+		if err.Expected != tt.expected || err.Found != tt.found {
+			t.Errorf("wrong expected/found for %q. expected=%q/%q, got=%q/%q",
+				tt.input, tt.expected, tt.found, err.Expected, err.Found)
+		}
+	}
+}
+
+func TestFunctionLiteralName(t *testing.T) {
+	l := lexer.New("let add = fn(a, b) { a + b }; fn() { 1 };")
+	p := New(l)
+	program := p.ParseProgram()
+	CheckParserErrors(p, t)
+
+	let := program.Statements[0].(*ast.LetStatement)
+	if name := let.Value.(*ast.FunctionLiteral).Name; name != "add" {
+		t.Errorf("wrong function name. expected=%q, got=%q", "add", name)
+	}
+	anonymous := program.Statements[1].(*ast.ExpressionStatement).Expression.(*ast.FunctionLiteral)
+	if anonymous.Name != "" {
+		t.Errorf("anonymous function has name %q", anonymous.Name)
+	}
+}
@@ -4,16 +4,28 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"strings"
 
 	"io"
 
+	"github.com/smiksha1701/buggy/ast"
+	"github.com/smiksha1701/buggy/compiler"
 	"github.com/smiksha1701/buggy/evaluator"
 	"github.com/smiksha1701/buggy/lexer"
 	"github.com/smiksha1701/buggy/object"
 	"github.com/smiksha1701/buggy/parser"
+	"github.com/smiksha1701/buggy/token"
+	"github.com/smiksha1701/buggy/vm"
+)
+
+// Engines that can run the code typed into the REPL.
+const (
+	ENGINE_EVAL = "eval"
+	ENGINE_VM   = "vm"
 )
 
 const PROMPT = `>>`
+const CONTINUATION_PROMPT = `..`
 const Buggy = `     
    _|__|__|__|__|_ 
   /               \ 
@@ -23,35 +35,135 @@ const Buggy = `
    \_\_                              
 `
 
-func Start() {
-	scanner := bufio.NewScanner(os.Stdin)
-	env := object.NewEnvironment()
+func Start(engine string) {
+	startWith(os.Stdin, os.Stdout, engine)
+}
+
+// startWith runs the REPL on in and out. The programs it runs read and
+// write them too: `input` shares the REPL's reader, so a line it reads is
+// not also taken for code.
+func startWith(in io.Reader, out io.Writer, engine string) {
+	reader := bufio.NewReader(in)
+	run := newRunner(engine, reader, out)
+	var input strings.Builder
+	blank := false
 	for {
-		fmt.Print(PROMPT)
-		scanned := scanner.Scan()
-		if !scanned {
+		if input.Len() == 0 {
+			fmt.Fprint(out, PROMPT)
+		} else {
+			fmt.Fprint(out, CONTINUATION_PROMPT)
+		}
+		line, err := reader.ReadString('\n')
+		if err != nil && line == "" {
 			return
 		}
-		line := scanner.Text()
-		l := lexer.New(line)
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+		input.WriteString(line)
+		input.WriteString("\n")
+		// Two blank lines in a row give up on waiting for the rest of the
+		// input, so the parser can report what is wrong with it.
+		forced := blank && strings.TrimSpace(line) == ""
+		blank = strings.TrimSpace(line) == ""
+		if !forced && !isComplete(input.String()) {
+			continue
+		}
+		source := input.String()
+		input.Reset()
+		blank = false
+		if strings.TrimSpace(source) == "" {
+			continue
+		}
+		l := lexer.New(source)
 		p := parser.New(l)
 		program := p.ParseProgram()
 		if len(p.Errors()) != 0 {
-			printParserErrors(os.Stdout, p.Errors())
+			printParserErrors(out, p.Errors())
+			continue
+		}
+		evaluated := run(program)
+		if errObj, ok := evaluated.(*object.Error); ok {
+			io.WriteString(out, errObj.Traceback())
 			continue
 		}
-		evaluated := evaluator.Eval(program, env)
 		if evaluated != nil {
-			io.WriteString(os.Stdout, evaluated.Inspect())
-			io.WriteString(os.Stdout, "\n")
+			io.WriteString(out, evaluated.Inspect())
+			io.WriteString(out, "\n")
 		}
 	}
 }
-func printParserErrors(out io.Writer, errors []string) {
+
+// newRunner returns a function that runs programs one after another on the
+// chosen engine, keeping the variables defined by earlier ones. Their `say`
+// writes to out and their `input` reads from in.
+func newRunner(engine string, in *bufio.Reader, out io.Writer) func(*ast.Program) object.Object {
+	say, input := evaluator.Say(out), evaluator.Input(in, out)
+	if engine == ENGINE_VM {
+		symbolTable := compiler.NewSymbolTable()
+		constants := []object.Object{}
+		globals := vm.NewGlobalsStore()
+		return func(program *ast.Program) object.Object {
+			comp := compiler.NewWithState(symbolTable, constants)
+			if err := comp.Compile(program); err != nil {
+				return &object.Error{Message: err.Error()}
+			}
+			bytecode := comp.Bytecode()
+			constants = bytecode.Constants
+			machine := vm.NewWithGlobalsStore(bytecode, globals)
+			machine.SetHost("say", say)
+			machine.SetHost("input", input)
+			return machine.Run()
+		}
+	}
+	env := object.NewEnvironment()
+	env.SetHost("say", say)
+	env.SetHost("input", input)
+	return func(program *ast.Program) object.Object {
+		return evaluator.Eval(program, env)
+	}
+}
+
+// isComplete reports whether input can be handed to the parser, or whether
+// it ends in the middle of a block, a bracketed list, a string or an
+// expression that is still waiting for an operand.
+func isComplete(input string) bool {
+	l := lexer.New(input)
+	depth := 0
+	var last token.Token
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACKET, token.LBRACE:
+			depth++
+		case token.RPAREN, token.RBRACKET, token.RBRACE:
+			depth--
+		}
+		last = tok
+	}
+	if depth > 0 {
+		return false
+	}
+	for _, err := range l.Errors() {
+		if err.Unterminated {
+			return false
+		}
+	}
+	switch last.Type {
+	case token.ASSIGN, token.EQ, token.NEQ, token.BANG, token.PLUS, token.MINUS,
+		token.SLASH, token.ASTERIX, token.LT, token.GT, token.COMMA, token.COLON,
+		token.LET, token.RETURN, token.IF, token.ELSE, token.FUNCTION,
+		token.WHILE, token.FOR, token.IN, token.PLUS_ASSIGN, token.MINUS_ASSIGN,
+		token.ASTERIX_ASSIGN, token.SLASH_ASSIGN, token.PERCENT_ASSIGN, token.PERCENT,
+		token.LTE, token.GTE, token.AND, token.OR, token.THROW, token.TRY,
+		token.CATCH, token.FINALLY, token.DOT, token.IMPORT, token.EXPORT, token.AS:
+		return false
+	}
+	return true
+}
+
+func printParserErrors(out io.Writer, errors []*parser.Error) {
 	io.WriteString(out, Buggy)
 	io.WriteString(out, "Oh, my developer won't be happy to see that...\n")
 	io.WriteString(out, "  Here are some parser errors:\n")
-	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
+	for _, err := range errors {
+		io.WriteString(out, "\t"+err.Error()+"\n")
 	}
 }
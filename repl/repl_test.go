@@ -0,0 +1,72 @@
+package repl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestIsComplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"let x = 5;\n", true},
+		{"5 + 5\n", true},
+		{"let f = fn(x) {\n", false},
+		{"let f = fn(x) {\n  x * 2\n}\n", true},
+		{"add(1,\n", false},
+		{"[1, 2,\n3]\n", true},
+		{"{\"a\": [1, 2\n", false},
+		{"let x =\n", false},
+		{"5 +\n", false},
+		{"if (x) { 1 } else\n", false},
+		{"\"unterminated\n", false},
+		{"\"done\"\n", true},
+		{"\"escaped \\\"\n", false},
+		{"\"escaped \\\"\"\n", true},
+		{"}\n", true},
+		{"x += 1 // done\n", true},
+		{"/* still\n", false},
+		{"/* done */\n", true},
+		{"a &&\n", false},
+		{"try { f() } catch\n", false},
+		{"try { f() } catch (e) { 0 }\n", true},
+		{"import \"lib.bg\" as\n", false},
+		{"lib.\n", false},
+		{"lib.f()\n", true},
+	}
+	for _, tt := range tests {
+		if got := isComplete(tt.input); got != tt.expected {
+			t.Errorf("isComplete(%q) wrong. expected=%t, got=%t", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestForcedParse(t *testing.T) {
+	for _, engine := range []string{ENGINE_EVAL, ENGINE_VM} {
+		var out bytes.Buffer
+		startWith(strings.NewReader("if (true) {\n  42\n\n\n7\n"), &out, engine)
+		got := out.String()
+		if !strings.Contains(got, "1:11: expected }, found end of input") {
+			t.Errorf("%s: missing } not reported. got=%q", engine, got)
+		}
+		if strings.Contains(got, "42") {
+			t.Errorf("%s: unfinished block was run. got=%q", engine, got)
+		}
+		if !strings.HasSuffix(got, "7\n"+PROMPT) {
+			t.Errorf("%s: REPL did not go on after the error. got=%q", engine, got)
+		}
+	}
+}
+
+func TestInputSharesReader(t *testing.T) {
+	for _, engine := range []string{ENGINE_EVAL, ENGINE_VM} {
+		var out bytes.Buffer
+		startWith(strings.NewReader("let name = input(\"name? \")\nAda\nsay(\"hi \" + name)\n"), &out, engine)
+		expected := PROMPT + "name? " + PROMPT + "hi Ada\nnull\n" + PROMPT
+		if out.String() != expected {
+			t.Errorf("%s: wrong output. expected=%q, got=%q", engine, expected, out.String())
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/smiksha1701/buggy/ast"
+	"github.com/smiksha1701/buggy/compiler"
+	"github.com/smiksha1701/buggy/evaluator"
+	"github.com/smiksha1701/buggy/lexer"
+	"github.com/smiksha1701/buggy/object"
+	"github.com/smiksha1701/buggy/parser"
+	"github.com/smiksha1701/buggy/repl"
+	"github.com/smiksha1701/buggy/vm"
+)
+
+const runUsage = "usage: buggy run [-engine=eval|vm] path/to/script.bg [args...]\n"
+
+// runCommand evaluates a whole Buggy source file. Everything after the file
+// name is handed to the script as the `args` array of strings.
+func runCommand(args []string) int {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	flags.Usage = func() { io.WriteString(os.Stderr, runUsage) }
+	engine := flags.String("engine", repl.ENGINE_EVAL, "execution engine: eval or vm")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	args = flags.Args()
+	if len(args) < 1 {
+		io.WriteString(os.Stderr, runUsage)
+		return 2
+	}
+	path := args[0]
+	source, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "buggy: %s\n", err)
+		return 1
+	}
+
+	l := lexer.NewFile(path, string(source))
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		for _, err := range p.Errors() {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		return 1
+	}
+
+	var evaluated object.Object
+	switch *engine {
+	case repl.ENGINE_EVAL:
+		env := object.NewEnvironment()
+		env.Set("args", scriptArgs(args[1:]))
+		evaluated = runEntry(env.Modules(), path, func() object.Object {
+			return evaluator.Eval(program, env)
+		})
+	case repl.ENGINE_VM:
+		evaluated = runOnVM(path, program, scriptArgs(args[1:]))
+	default:
+		fmt.Fprintf(os.Stderr, "buggy: unknown engine %q\n", *engine)
+		return 2
+	}
+	if errObj, ok := evaluated.(*object.Error); ok {
+		fmt.Fprint(os.Stderr, errObj.Traceback())
+		return 1
+	}
+	return 0
+}
+
+func runOnVM(path string, program *ast.Program, scriptArgs *object.Array) object.Object {
+	symbolTable := compiler.NewSymbolTable()
+	globals := vm.NewGlobalsStore()
+	globals[symbolTable.Define("args").Index] = scriptArgs
+
+	comp := compiler.NewWithState(symbolTable, []object.Object{})
+	if err := comp.Compile(program); err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	machine := vm.NewWithGlobalsStore(comp.Bytecode(), globals)
+	return runEntry(machine.Modules(), path, machine.Run)
+}
+
+// runEntry runs the file at path with run. The file is in modules while it
+// runs, like a file being imported, so that a module importing it back is
+// reported as an import cycle instead of running it a second time.
+func runEntry(modules *object.Modules, path string, run func() object.Object) object.Object {
+	path = filepath.Clean(path)
+	if err := modules.Begin(path); err != nil {
+		return err
+	}
+	defer modules.End(path, nil)
+	return run()
+}
+
+func scriptArgs(args []string) *object.Array {
+	elements := make([]object.Object, len(args))
+	for i, arg := range args {
+		elements[i] = &object.String{Value: arg}
+	}
+	return &object.Array{Elements: elements}
+}
@@ -1,18 +1,51 @@
 package token
 
+import "fmt"
+
 type TokenType string
 
+// Position is a location in Buggy source code. Line and Column start at 1,
+// Offset is the byte offset from the beginning of the input.
+type Position struct {
+	Filename string
+	Offset   int
+	Line     int
+	Column   int
+}
+
+func (p Position) IsValid() bool { return p.Line > 0 }
+
+func (p Position) String() string {
+	s := p.Filename
+	if p.IsValid() {
+		if s != "" {
+			s += ":"
+		}
+		s += fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	if s == "" {
+		s = "-"
+	}
+	return s
+}
+
 type Token struct {
 	Type    TokenType
 	Literal string
+	// Pos is the position of the first character of the token and End the
+	// position right after the last one.
+	Pos Position
+	End Position
 }
 
 const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
+	COMMENT = "COMMENT"
 	// Identifiers + literals
 	IDENT  = "IDENT"
 	INT    = "INT"
+	FLOAT  = "FLOAT"
 	STRING = "STRING"
 	// Operators
 	ASSIGN  = "="
@@ -23,12 +56,24 @@ const (
 	MINUS   = "-"
 	SLASH   = "/"
 	ASTERIX = "*"
+	PERCENT = "%"
 	LT      = "<"
 	GT      = ">"
+	LTE     = "<="
+	GTE     = ">="
+	AND     = "&&"
+	OR      = "||"
+
+	PLUS_ASSIGN    = "+="
+	MINUS_ASSIGN   = "-="
+	ASTERIX_ASSIGN = "*="
+	SLASH_ASSIGN   = "/="
+	PERCENT_ASSIGN = "%="
 	// Delimiters
 	COMMA     = ","
 	COLON     = ":"
 	SEMICOLON = ";"
+	DOT       = "."
 	LBRACKET  = "["
 	RBRACKET  = "]"
 	LPAREN    = "("
@@ -43,16 +88,40 @@ const (
 	RETURN   = "RETURN"
 	FUNCTION = "FUNCTION"
 	LET      = "LET"
+	WHILE    = "WHILE"
+	FOR      = "FOR"
+	IN       = "IN"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
+	THROW    = "THROW"
+	TRY      = "TRY"
+	CATCH    = "CATCH"
+	FINALLY  = "FINALLY"
+	IMPORT   = "IMPORT"
+	EXPORT   = "EXPORT"
+	AS       = "AS"
 )
 
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
-	"true":   TRUE,
-	"false":  FALSE,
+	"fn":       FUNCTION,
+	"let":      LET,
+	"if":       IF,
+	"else":     ELSE,
+	"return":   RETURN,
+	"true":     TRUE,
+	"false":    FALSE,
+	"while":    WHILE,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
+	"throw":    THROW,
+	"try":      TRY,
+	"catch":    CATCH,
+	"finally":  FINALLY,
+	"import":   IMPORT,
+	"export":   EXPORT,
+	"as":       AS,
 }
 
 func ChecKeywords(tok string) TokenType {
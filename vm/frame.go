@@ -0,0 +1,29 @@
+package vm
+
+import (
+	"github.com/smiksha1701/buggy/code"
+	"github.com/smiksha1701/buggy/evaluator"
+	"github.com/smiksha1701/buggy/object"
+	"github.com/smiksha1701/buggy/token"
+)
+
+// Frame is one active function call. scope holds the call's locals and is
+// nil for the main program, whose variables are all globals. A frame that a
+// tail call took over records the functions it replaced in replaced, and
+// the position of the tail call in callPos.
+type Frame struct {
+	cl          *object.Closure
+	ip          int
+	scope       *object.Scope
+	basePointer int
+	replaced    *evaluator.TailFrames
+	callPos     token.Position
+}
+
+func NewFrame(cl *object.Closure, scope *object.Scope, basePointer int) *Frame {
+	return &Frame{cl: cl, ip: -1, scope: scope, basePointer: basePointer}
+}
+
+func (f *Frame) Instructions() code.Instructions {
+	return f.cl.Fn.Instructions
+}
@@ -0,0 +1,754 @@
+package vm
+
+import (
+	"fmt"
+
+	"github.com/smiksha1701/buggy/code"
+	"github.com/smiksha1701/buggy/compiler"
+	"github.com/smiksha1701/buggy/evaluator"
+	"github.com/smiksha1701/buggy/object"
+	"github.com/smiksha1701/buggy/token"
+)
+
+const (
+	StackSize   = 1 << 20
+	GlobalsSize = 65536
+	MaxFrames   = 1 << 16
+)
+
+var (
+	NULL  = evaluator.NULL
+	TRUE  = evaluator.TRUE
+	FALSE = evaluator.FALSE
+)
+
+type VM struct {
+	stack []object.Object
+	sp    int // points to the next free slot; the top of the stack is stack[sp-1]
+
+	frames      []*Frame
+	framesIndex int
+
+	// handlers are the error handlers installed by OpTry, innermost last.
+	handlers []handler
+
+	budget *object.Budget
+
+	// modules holds the modules imported by the program.
+	modules *object.Modules
+
+	// host holds the names SetHost defines.
+	host map[string]object.Object
+
+	// callBase is the number of frames below the function being run by
+	// Call, whose return ends run; it is 0 while running the program.
+	callBase int
+
+	lastPopped object.Object
+}
+
+// handler is where an error raised inside a try block continues: at ip in
+// the frame that was current when it was installed, with the stack cut
+// back to sp.
+type handler struct {
+	ip          int
+	catch       bool
+	framesIndex int
+	sp          int
+}
+
+func New(bytecode *compiler.Bytecode) *VM {
+	return NewWithGlobalsStore(bytecode, NewGlobalsStore())
+}
+
+// NewWithGlobalsStore creates a vm that shares its globals with earlier
+// runs, as the REPL does line by line.
+func NewWithGlobalsStore(bytecode *compiler.Bytecode, s []object.Object) *VM {
+	unit := &object.Unit{
+		Constants:     bytecode.Constants,
+		Globals:       s,
+		GlobalNames:   bytecode.GlobalNames,
+		ScopedGlobals: bytecode.ScopedGlobals,
+	}
+	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions, SourceMap: bytecode.SourceMap}
+	mainFrame := NewFrame(&object.Closure{Fn: mainFn, Unit: unit}, nil, 0)
+
+	frames := make([]*Frame, 1, 64)
+	frames[0] = mainFrame
+
+	return &VM{
+		stack:       make([]object.Object, 256),
+		sp:          0,
+		frames:      frames,
+		framesIndex: 1,
+		modules:     object.NewModules(),
+	}
+}
+
+// SetBudget limits the runs of the vm; nil removes the limits.
+func (vm *VM) SetBudget(b *object.Budget) {
+	vm.budget = b
+}
+
+func NewGlobalsStore() []object.Object {
+	return make([]object.Object, GlobalsSize)
+}
+
+// Run executes the bytecode and returns the value of the last expression
+// statement, the value of a top level return, or the *object.Error that
+// stopped the program. Like Eval it returns nil when there is no value.
+func (vm *VM) Run() object.Object {
+	return vm.execute(0, 0)
+}
+
+// execute runs until the program ends or the function called by Call
+// returns. Errors go to the handlers above the first handlers ones, and
+// an error none of them catches gets the frames above bottom in its stack.
+func (vm *VM) execute(handlers, bottom int) object.Object {
+	for {
+		result := vm.run()
+		errObj, ok := result.(*object.Error)
+		if !ok {
+			return result
+		}
+		if len(vm.handlers) == handlers {
+			vm.addStackTrace(errObj, bottom)
+			return errObj
+		}
+		vm.handle(errObj)
+	}
+}
+
+// Call calls fn, a closure or a builtin, with args and runs it to the end.
+// Builtins such as `map` use it to call the functions they are given, in
+// the middle of running the program.
+func (vm *VM) Call(fn object.Object, args ...object.Object) object.Object {
+	sp, framesIndex, callBase := vm.sp, vm.framesIndex, vm.callBase
+	defer func() { vm.callBase = callBase }()
+	vm.push(fn)
+	for _, arg := range args {
+		if err := vm.push(arg); isError(err) {
+			vm.sp = sp
+			return err
+		}
+	}
+	if err := vm.executeCall(len(args)); isError(err) {
+		vm.sp = sp
+		return err
+	}
+	if vm.framesIndex == framesIndex {
+		// A builtin has already left its result on the stack.
+		return vm.pop()
+	}
+	vm.callBase = framesIndex
+	result := vm.execute(len(vm.handlers), framesIndex-1)
+	if isError(result) {
+		vm.framesIndex, vm.sp = framesIndex, sp
+	}
+	return result
+}
+
+// SetHost defines name for all the code the vm runs, including the modules
+// it imports, like Environment.SetHost does for the evaluator. Host names
+// are found after globals, which can shadow them, and before builtins.
+func (vm *VM) SetHost(name string, val object.Object) {
+	if vm.host == nil {
+		vm.host = make(map[string]object.Object)
+	}
+	vm.host[name] = val
+}
+
+// Modules returns the modules imported by the vm's runs.
+func (vm *VM) Modules() *object.Modules {
+	return vm.modules
+}
+
+// Budget returns the budget of the vm's runs, or nil.
+func (vm *VM) Budget() *object.Budget {
+	return vm.budget
+}
+
+// handle unwinds the frames and the stack to the innermost handler and
+// continues there with the error, or the hash a catch clause binds, on the
+// stack.
+func (vm *VM) handle(err *object.Error) {
+	h := vm.handlers[len(vm.handlers)-1]
+	vm.handlers = vm.handlers[:len(vm.handlers)-1]
+	vm.addStackTrace(err, h.framesIndex-1)
+	vm.framesIndex = h.framesIndex
+	vm.sp = h.sp
+	var value object.Object = err
+	if h.catch {
+		value = err.ToHash()
+	}
+	// push would take the error for one to raise, so it only makes room.
+	vm.push(NULL)
+	vm.stack[vm.sp-1] = value
+	vm.currentFrame().ip = h.ip - 1
+}
+
+// addStackTrace records where err was raised and the function calls that
+// are unwound until the frame at index bottom, using the source maps of the
+// frames.
+func (vm *VM) addStackTrace(err *object.Error, bottom int) {
+	for i := vm.framesIndex - 1; i >= bottom; i-- {
+		frame := vm.frames[i]
+		if i == vm.framesIndex-1 && !err.Pos.IsValid() {
+			err.Pos = frame.cl.Fn.SourceMap.Lookup(frame.ip)
+		}
+		if i > bottom {
+			err.Stack = append(err.Stack, object.StackFrame{
+				Function: frame.cl.Fn.Name,
+				Pos:      vm.callPos(i),
+			})
+			if frame.replaced != nil {
+				err.Stack = frame.replaced.AppendTo(err.Stack)
+			}
+		}
+	}
+}
+
+// callPos returns the position of the call that made the frame at index i.
+func (vm *VM) callPos(i int) token.Position {
+	if frame := vm.frames[i]; frame.replaced != nil {
+		return frame.callPos
+	}
+	caller := vm.frames[i-1]
+	return caller.cl.Fn.SourceMap.Lookup(caller.ip)
+}
+
+func (vm *VM) run() object.Object {
+	for {
+		frame := vm.currentFrame()
+		ins := frame.Instructions()
+		frame.ip++
+		if frame.ip >= len(ins) {
+			return vm.lastPopped
+		}
+		ip := frame.ip
+		op := code.Opcode(ins[ip])
+		if vm.budget != nil {
+			if err := vm.budget.Step(); err != nil {
+				return err
+			}
+		}
+
+		var result object.Object
+
+		switch op {
+		case code.OpConstant:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			result = vm.push(frame.cl.Unit.Constants[constIndex])
+
+		case code.OpPop:
+			vm.lastPopped = vm.pop()
+
+		case code.OpTrue:
+			result = vm.push(TRUE)
+
+		case code.OpFalse:
+			result = vm.push(FALSE)
+
+		case code.OpNull:
+			result = vm.push(NULL)
+
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod,
+			code.OpEqual, code.OpNotEqual, code.OpLessThan, code.OpGreaterThan,
+			code.OpLessEqual, code.OpGreaterEqual:
+			right := vm.pop()
+			left := vm.pop()
+			result = vm.push(vm.executeBinaryOperation(op, left, right))
+
+		case code.OpBang:
+			result = vm.push(evaluator.EvalPrefixExpression("!", vm.pop()))
+
+		case code.OpMinus:
+			result = vm.push(evaluator.EvalPrefixExpression("-", vm.pop()))
+
+		case code.OpJump:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip = pos - 1
+
+		case code.OpJumpNotTruthy:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+			if !evaluator.IsTruthy(vm.pop()) {
+				frame.ip = pos - 1
+			}
+
+		case code.OpSetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			frame.cl.Unit.Globals[globalIndex] = vm.pop()
+
+		case code.OpGetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			result = vm.push(vm.getGlobal(frame.cl.Unit, int(globalIndex)))
+
+		case code.OpSetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			frame.ip += 1
+			frame.scope.Slots[localIndex] = vm.pop()
+
+		case code.OpGetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			frame.ip += 1
+			result = vm.push(getSlot(frame.scope, int(localIndex)))
+
+		case code.OpGetOuter:
+			depth := code.ReadUint8(ins[ip+1:])
+			localIndex := code.ReadUint8(ins[ip+2:])
+			frame.ip += 2
+			scope := frame.scope
+			for i := 0; i < int(depth); i++ {
+				scope = scope.Outer
+			}
+			result = vm.push(getSlot(scope, int(localIndex)))
+
+		case code.OpAssignGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			globals := frame.cl.Unit.Globals
+			if globals[globalIndex] == nil {
+				return newError(object.NAME_ERROR, "cannot assign to undeclared identifier: %s", frame.cl.Unit.GlobalNames[globalIndex])
+			}
+			globals[globalIndex] = vm.pop()
+
+		case code.OpAssignLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			frame.ip += 1
+			result = assignSlot(frame.scope, int(localIndex), vm.pop())
+
+		case code.OpAssignOuter:
+			depth := code.ReadUint8(ins[ip+1:])
+			localIndex := code.ReadUint8(ins[ip+2:])
+			frame.ip += 2
+			scope := frame.scope
+			for i := 0; i < int(depth); i++ {
+				scope = scope.Outer
+			}
+			result = assignSlot(scope, int(localIndex), vm.pop())
+
+		case code.OpArray:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+			elements := make([]object.Object, numElements)
+			copy(elements, vm.stack[vm.sp-numElements:vm.sp])
+			vm.sp -= numElements
+			result = vm.push(&object.Array{Elements: elements})
+
+		case code.OpHash:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			frame.ip += 2
+			hash := vm.buildHash(vm.sp-numElements, vm.sp)
+			vm.sp -= numElements
+			result = vm.push(hash)
+
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+			result = vm.push(evaluator.EvalIndexExpression(left, index))
+
+		case code.OpSlice:
+			high := vm.pop()
+			low := vm.pop()
+			left := vm.pop()
+			result = vm.push(evaluator.EvalSliceExpression(left, low, high))
+
+		case code.OpSetIndex:
+			operator := code.Opcode(code.ReadUint8(ins[ip+1:]))
+			frame.ip += 1
+			value := vm.pop()
+			index := vm.pop()
+			left := vm.pop()
+			if operator != 0 {
+				current := evaluator.EvalIndexExpression(left, index)
+				if errObj, ok := current.(*object.Error); ok {
+					return errObj
+				}
+				value = vm.executeBinaryOperation(operator, current, value)
+				if errObj, ok := value.(*object.Error); ok {
+					return errObj
+				}
+			}
+			result = evaluator.EvalIndexAssignment(left, index, value)
+
+		case code.OpTry:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			catch := code.ReadUint8(ins[ip+3:]) == 1
+			frame.ip += 3
+			vm.handlers = append(vm.handlers, handler{ip: pos, catch: catch, framesIndex: vm.framesIndex, sp: vm.sp})
+
+		case code.OpEndTry:
+			vm.handlers = vm.handlers[:len(vm.handlers)-1]
+
+		case code.OpThrow:
+			value := vm.pop()
+			if errObj, ok := value.(*object.Error); ok {
+				return errObj
+			}
+			return object.NewThrownError(value)
+
+		case code.OpCall:
+			numArgs := int(code.ReadUint8(ins[ip+1:]))
+			frame.ip += 1
+			result = vm.executeCall(numArgs)
+
+		case code.OpTailCall:
+			numArgs := int(code.ReadUint8(ins[ip+1:]))
+			frame.ip += 1
+			result = vm.executeTailCall(numArgs)
+
+		case code.OpReturnValue, code.OpReturn:
+			returnValue := object.Object(NULL)
+			if op == code.OpReturnValue {
+				returnValue = vm.pop()
+			}
+			if vm.framesIndex == 1 {
+				return returnValue
+			}
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+			if vm.framesIndex == vm.callBase {
+				return returnValue
+			}
+			result = vm.push(returnValue)
+
+		case code.OpClosure:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			fn := frame.cl.Unit.Constants[constIndex].(*object.CompiledFunction)
+			result = vm.push(&object.Closure{Fn: fn, Scope: frame.scope, Unit: frame.cl.Unit})
+
+		case code.OpGetIter:
+			iterable := vm.pop()
+			iterator, ok := object.NewIterator(iterable)
+			if !ok {
+				return newError(object.TYPE_ERROR, "cannot iterate over %s", iterable.Type())
+			}
+			result = vm.push(iterator)
+
+		case code.OpIterNext:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			items := code.ReadUint8(ins[ip+3:])
+			frame.ip += 3
+			iterator := vm.stack[vm.sp-1].(*object.Iterator)
+			if items == 2 {
+				key, value, ok := iterator.Next()
+				if !ok {
+					frame.ip = pos - 1
+					break
+				}
+				vm.push(key)
+				result = vm.push(value)
+			} else {
+				item, ok := iterator.NextItem()
+				if !ok {
+					frame.ip = pos - 1
+					break
+				}
+				result = vm.push(item)
+			}
+
+		case code.OpImport:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			path := frame.cl.Unit.Constants[constIndex].(*object.String).Value
+			pos := frame.cl.Fn.SourceMap.Lookup(ip)
+			result = vm.push(vm.importModule(evaluator.ResolveImport(pos, path)))
+
+		case code.OpMember:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			frame.ip += 2
+			name := frame.cl.Unit.Constants[constIndex].(*object.String).Value
+			result = vm.push(evaluator.EvalMemberExpression(vm.pop(), name))
+
+		default:
+			return newError(object.RUNTIME_ERROR, "unknown opcode %d", op)
+		}
+
+		if errObj, ok := result.(*object.Error); ok {
+			return errObj
+		}
+	}
+}
+
+// push places obj on the stack and returns it, or returns the error that
+// stops the program when obj is an error or the stack is exhausted.
+func (vm *VM) push(obj object.Object) object.Object {
+	if errObj, ok := obj.(*object.Error); ok {
+		return errObj
+	}
+	if vm.sp >= len(vm.stack) {
+		if len(vm.stack) >= StackSize {
+			return newError(object.STACK_OVERFLOW_ERROR, "stack overflow")
+		}
+		stack := make([]object.Object, len(vm.stack)*2)
+		copy(stack, vm.stack)
+		vm.stack = stack
+	}
+	if obj == nil {
+		obj = NULL
+	}
+	vm.stack[vm.sp] = obj
+	vm.sp++
+	return obj
+}
+
+func (vm *VM) pop() object.Object {
+	o := vm.stack[vm.sp-1]
+	vm.sp--
+	return o
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) pushFrame(f *Frame) object.Object {
+	if vm.framesIndex >= MaxFrames {
+		return newError(object.STACK_OVERFLOW_ERROR, "stack overflow")
+	}
+	// The main program's frame is not a call.
+	if err := vm.budget.CheckDepth(vm.framesIndex); err != nil {
+		return err
+	}
+	if vm.framesIndex == len(vm.frames) {
+		vm.frames = append(vm.frames, f)
+	} else {
+		vm.frames[vm.framesIndex] = f
+	}
+	vm.framesIndex++
+	return nil
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+	return vm.frames[vm.framesIndex]
+}
+
+// getGlobal mirrors the evaluator's identifier lookup: a global that was
+// never assigned may still name a host name or a builtin.
+func (vm *VM) getGlobal(unit *object.Unit, index int) object.Object {
+	if value := unit.Globals[index]; value != nil {
+		return value
+	}
+	name := unit.GlobalNames[index]
+	if value, ok := vm.host[name]; ok {
+		return value
+	}
+	if builtin, ok := evaluator.LookupBuiltin(name); ok {
+		return builtin
+	}
+	return newError(object.NAME_ERROR, "identifier not found: "+name)
+}
+
+func getSlot(scope *object.Scope, index int) object.Object {
+	if value := scope.Slots[index]; value != nil {
+		return value
+	}
+	return newError(object.NAME_ERROR, "identifier not found: "+scope.Names[index])
+}
+
+func assignSlot(scope *object.Scope, index int, value object.Object) object.Object {
+	if scope.Slots[index] == nil {
+		return newError(object.NAME_ERROR, "cannot assign to undeclared identifier: %s", scope.Names[index])
+	}
+	scope.Slots[index] = value
+	return nil
+}
+
+var binaryOperators = map[code.Opcode]string{
+	code.OpAdd:          "+",
+	code.OpSub:          "-",
+	code.OpMul:          "*",
+	code.OpDiv:          "/",
+	code.OpMod:          "%",
+	code.OpEqual:        "==",
+	code.OpNotEqual:     "!=",
+	code.OpLessThan:     "<",
+	code.OpGreaterThan:  ">",
+	code.OpLessEqual:    "<=",
+	code.OpGreaterEqual: ">=",
+}
+
+func (vm *VM) executeBinaryOperation(op code.Opcode, left, right object.Object) object.Object {
+	// Integer arithmetic dominates loops, so it skips the generic path.
+	if l, ok := left.(*object.Integer); ok {
+		if r, ok := right.(*object.Integer); ok {
+			switch op {
+			case code.OpAdd:
+				return &object.Integer{Value: l.Value + r.Value}
+			case code.OpSub:
+				return &object.Integer{Value: l.Value - r.Value}
+			case code.OpMul:
+				return &object.Integer{Value: l.Value * r.Value}
+			case code.OpLessThan:
+				return nativeBoolToBooleanObject(l.Value < r.Value)
+			case code.OpGreaterThan:
+				return nativeBoolToBooleanObject(l.Value > r.Value)
+			case code.OpLessEqual:
+				return nativeBoolToBooleanObject(l.Value <= r.Value)
+			case code.OpGreaterEqual:
+				return nativeBoolToBooleanObject(l.Value >= r.Value)
+			case code.OpEqual:
+				return nativeBoolToBooleanObject(l.Value == r.Value)
+			case code.OpNotEqual:
+				return nativeBoolToBooleanObject(l.Value != r.Value)
+			}
+		}
+	}
+	if err := evaluator.CheckAllocation(vm.budget, binaryOperators[op], left, right); err != nil {
+		return err
+	}
+	return evaluator.EvalInfixExpression(binaryOperators[op], left, right)
+}
+
+func (vm *VM) buildHash(startIndex, endIndex int) object.Object {
+	hash := object.NewHash()
+
+	for i := startIndex; i < endIndex; i += 2 {
+		key := vm.stack[i]
+		value := vm.stack[i+1]
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return newError(object.TYPE_ERROR, "unusable as hash key: %s", key.Type())
+		}
+		hash.Set(hashKey.HashKey(), object.HashPair{Key: key, Value: value})
+	}
+	return hash
+}
+
+func (vm *VM) executeCall(numArgs int) object.Object {
+	callee := vm.stack[vm.sp-1-numArgs]
+	switch callee := callee.(type) {
+	case *object.Closure:
+		return vm.callClosure(callee, numArgs)
+	case *object.Builtin:
+		// Calls the builtin makes through Call push above the arguments,
+		// so they stay in place.
+		args := vm.stack[vm.sp-numArgs : vm.sp]
+		result := callee.Call(vm, args...)
+		if err := vm.budget.CheckValue(result); err != nil {
+			return err
+		}
+		vm.sp = vm.sp - numArgs - 1
+		return vm.push(result)
+	default:
+		return newError(object.TYPE_ERROR, "not a function: %s", callee.Type())
+	}
+}
+
+// importModule returns the module in the file at path. On its first import
+// the file is compiled into a unit of its own and its top level is called
+// like a function, so it shares the stack and budget of the program but has
+// globals of its own.
+func (vm *VM) importModule(path string) object.Object {
+	if module, ok := vm.modules.Get(path); ok {
+		return module
+	}
+	if err := vm.modules.Begin(path); err != nil {
+		return err
+	}
+	var module *object.Module
+	defer func() { vm.modules.End(path, module) }()
+
+	program, errObj := evaluator.ParseModule(path)
+	if errObj != nil {
+		return errObj
+	}
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		return newError(object.IMPORT_ERROR, "cannot import %s: %s", path, err)
+	}
+	bytecode := comp.Bytecode()
+	unit := &object.Unit{
+		Constants:     bytecode.Constants,
+		Globals:       make([]object.Object, len(bytecode.GlobalNames)),
+		GlobalNames:   bytecode.GlobalNames,
+		ScopedGlobals: bytecode.ScopedGlobals,
+	}
+	fn := &object.CompiledFunction{
+		Instructions: append(bytecode.Instructions, code.Make(code.OpReturn)...),
+		SourceMap:    bytecode.SourceMap,
+		Name:         evaluator.ModuleName(path),
+	}
+	// The value of the module's last expression is not the program's.
+	lastPopped := vm.lastPopped
+	result := vm.Call(&object.Closure{Fn: fn, Unit: unit})
+	vm.lastPopped = lastPopped
+	if isError(result) {
+		return result
+	}
+	module = &object.Module{Path: path, Exports: map[string]bool{}, Globals: unit}
+	for _, name := range evaluator.ExportNames(program) {
+		module.Exports[name] = true
+	}
+	return module
+}
+
+// executeTailCall makes a call whose value the current function returns.
+// A closure called with enough arguments takes over the current frame, as
+// the evaluator's tail calls do, so tail recursion runs in constant space.
+// The function it replaces is recorded for error stacks.
+func (vm *VM) executeTailCall(numArgs int) object.Object {
+	cl, ok := vm.stack[vm.sp-1-numArgs].(*object.Closure)
+	if !ok || numArgs < cl.Fn.NumParameters {
+		return vm.executeCall(numArgs)
+	}
+	frame := vm.currentFrame()
+	replaced := frame.replaced
+	if replaced == nil {
+		replaced = &evaluator.TailFrames{}
+	}
+	replaced.Push(object.StackFrame{Function: frame.cl.Fn.Name, Pos: vm.callPos(vm.framesIndex - 1)})
+	callPos := frame.cl.Fn.SourceMap.Lookup(frame.ip)
+
+	// The callee and its arguments move down to where the caller's were.
+	start := frame.basePointer - 1
+	copy(vm.stack[start:], vm.stack[vm.sp-1-numArgs:vm.sp])
+	vm.sp = start + 1 + numArgs
+	vm.framesIndex--
+	if err := vm.callClosure(cl, numArgs); err != nil {
+		vm.framesIndex++
+		return err
+	}
+	frame = vm.currentFrame()
+	frame.replaced, frame.callPos = replaced, callPos
+	return nil
+}
+
+func (vm *VM) callClosure(cl *object.Closure, numArgs int) object.Object {
+	fn := cl.Fn
+	if numArgs < fn.NumParameters {
+		return newError(object.ARGUMENT_ERROR, "wrong number of arguments: want=%d, got=%d", fn.NumParameters, numArgs)
+	}
+	scope := &object.Scope{
+		Slots: make([]object.Object, fn.NumLocals),
+		Names: fn.LocalNames,
+		Outer: cl.Scope,
+	}
+	basePointer := vm.sp - numArgs
+	copy(scope.Slots, vm.stack[basePointer:basePointer+fn.NumParameters])
+	vm.sp = basePointer
+	return vm.pushFrame(NewFrame(cl, scope, basePointer))
+}
+
+func nativeBoolToBooleanObject(input bool) *object.Boolean {
+	if input {
+		return TRUE
+	}
+	return FALSE
+}
+
+func isError(obj object.Object) bool {
+	_, ok := obj.(*object.Error)
+	return ok
+}
+
+func newError(kind object.ErrorKind, format string, a ...interface{}) *object.Error {
+	return &object.Error{Kind: kind, Message: fmt.Sprintf(format, a...)}
+}
@@ -0,0 +1,56 @@
+package vm_test
+
+import (
+	"testing"
+
+	"github.com/smiksha1701/buggy/compiler"
+	"github.com/smiksha1701/buggy/enginetest"
+	"github.com/smiksha1701/buggy/lexer"
+	"github.com/smiksha1701/buggy/object"
+	"github.com/smiksha1701/buggy/parser"
+	"github.com/smiksha1701/buggy/vm"
+)
+
+func TestEngine(t *testing.T) {
+	enginetest.Run(t, testEvalWithBudget)
+}
+
+func TestVMErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"let f = fn(a, b) { a }; f(1);", "wrong number of arguments: want=2, got=1"},
+		{"5();", "not a function: INTEGER"},
+		{"let f = fn() { if (false) { let y = 1 }; y }; f();", "identifier not found: y"},
+		{"let f = fn() { 1 + f() }; f();", "stack overflow"},
+	}
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func testEval(input string) object.Object {
+	return testEvalWithBudget(input, nil)
+}
+
+func testEvalWithBudget(input string, b *object.Budget) object.Object {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		return &object.Error{Message: err.Error()}
+	}
+	machine := vm.New(comp.Bytecode())
+	machine.SetBudget(b)
+	return machine.Run()
+}